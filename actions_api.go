@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// queryIDRe matches an Impala query ID, two hexadecimal halves separated by a colon
+var queryIDRe = regexp.MustCompile(`^[0-9a-fA-F]{1,16}:[0-9a-fA-F]{1,16}$`)
+
+// cancelQueryTimeout bounds a cancellation request to a coordinator
+const cancelQueryTimeout = 10 * time.Second
+
+// cancelQueryResponse represents the JSON response of Impala's /cancel_query page
+type cancelQueryResponse struct {
+	Contents string `json:"contents"`
+	Error    string `json:"error"`
+}
+
+// registerActionsAPI mounts the admin actions on the given mux, with the same bearer token as the targets API.
+// POST /actions/kill?target=<target>&query_id=<id> cancels a query through the /cancel_query page of the
+// coordinator running it; target is the impala_server label or the address of a scraped server, so that only
+// the web UIs the exporter already talks to can be reached.
+func registerActionsAPI(mux *http.ServeMux, exporter *Exporter, token string) {
+	mux.Handle("POST /actions/kill", requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, queryID := r.URL.Query().Get("target"), r.URL.Query().Get("query_id")
+		if !queryIDRe.MatchString(queryID) {
+			http.Error(w, fmt.Sprintf("invalid query_id %q", queryID), http.StatusBadRequest)
+			return
+		}
+		var target *Target
+		for _, t := range exporter.Targets() {
+			if t.Name == name || t.Address == name {
+				target = &t
+				break
+			}
+		}
+		if target == nil {
+			http.Error(w, fmt.Sprintf("unknown target %q", name), http.StatusNotFound)
+			return
+		}
+
+		slog.Info("Cancelling query", "target", target.Name, "query_id", queryID, "remote_addr", r.RemoteAddr)
+		ctx, cancel := context.WithTimeout(r.Context(), cancelQueryTimeout)
+		defer cancel()
+		resp, err := cancelQuery(ctx, target.Address, queryID)
+		if err != nil {
+			slog.Warn("Error cancelling query", "target", target.Name, "query_id", queryID, "err", err)
+			http.Error(w, fmt.Sprintf("cancelling query: %v", err), http.StatusBadGateway)
+			return
+		}
+		if resp.Error != "" {
+			http.Error(w, resp.Error, http.StatusConflict)
+			return
+		}
+		io.WriteString(w, resp.Contents+"\n")
+	})))
+}
+
+// cancelQuery asks the coordinator at address to cancel a query. The request is not retried, a second
+// cancellation would only report that the query is gone.
+func cancelQuery(ctx context.Context, address, queryID string) (cancelQueryResponse, error) {
+	var resp cancelQueryResponse
+	path := "/cancel_query?" + url.Values{"query_id": {queryID}, "json": {""}}.Encode()
+	err := fetchOnce(ctx, address, path, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&resp)
+	})
+	return resp, err
+}
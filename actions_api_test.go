@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKillAction(t *testing.T) {
+	var cancelled []string
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cancel_query" {
+			http.NotFound(w, r)
+			return
+		}
+		id := r.URL.Query().Get("query_id")
+		if id == "0:2" {
+			w.Write([]byte(`{"error": "Invalid or unknown query handle"}`))
+			return
+		}
+		cancelled = append(cancelled, id)
+		w.Write([]byte(`{"contents": "Query cancellation successful"}`))
+	}))
+	defer impala.Close()
+	address := strings.TrimPrefix(impala.URL, "http://")
+	exporter := NewExporter([]string{"coord=" + address}, ExporterOptions{})
+	mux := http.NewServeMux()
+	registerActionsAPI(mux, exporter, "secret")
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"no token", "/actions/kill?target=coord&query_id=a1b2:c3d4", "", http.StatusUnauthorized},
+		{"by name", "/actions/kill?target=coord&query_id=a1b2:c3d4", "secret", http.StatusOK},
+		{"by address", "/actions/kill?target=" + address + "&query_id=0:1", "secret", http.StatusOK},
+		{"unknown query", "/actions/kill?target=coord&query_id=0:2", "secret", http.StatusConflict},
+		{"unknown target", "/actions/kill?target=other:25000&query_id=0:1", "secret", http.StatusNotFound},
+		{"invalid query id", "/actions/kill?target=coord&query_id=x%26y", "secret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got status %d (%s), want %d", tt.name, rec.Code, strings.TrimSpace(rec.Body.String()), tt.want)
+		}
+	}
+	if got := strings.Join(cancelled, ","); got != "a1b2:c3d4,0:1" {
+		t.Errorf("cancelled %s, want a1b2:c3d4,0:1", got)
+	}
+}
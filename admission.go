@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AdmissionPool represents a single resource pool as rendered by Impala's /admission?json page
+type AdmissionPool struct {
+	PoolName    string  `json:"pool_name"`
+	NumRunning  float64 `json:"agg_num_running"`
+	NumQueued   float64 `json:"agg_num_queued"`
+	MaxRequests float64 `json:"max_requests"`
+	// MaxMem is the pool's memory limit across the cluster, -1 when unlimited
+	MaxMem ByteSize `json:"max_mem"`
+	// MemReserved is the memory reserved by the pool's queries across the cluster
+	MemReserved ByteSize `json:"agg_mem_reserved"`
+	// MemAdmitted is the memory admitted to the pool's running queries by this coordinator
+	MemAdmitted ByteSize `json:"local_mem_admitted"`
+}
+
+// AdmissionResponse represents the structure of the JSON response from Impala for /admission
+type AdmissionResponse struct {
+	ResourcePools []AdmissionPool `json:"resource_pools"`
+}
+
+// admissionUtilization returns the share of a pool's concurrency limit taken by running queries.
+// It reports false when the pool has no limit (max_requests of -1) or admits nothing (0).
+func admissionUtilization(pool AdmissionPool) (float64, bool) {
+	if pool.MaxRequests <= 0 {
+		return 0, false
+	}
+	return pool.NumRunning / pool.MaxRequests, true
+}
+
+// admissionCollector exports the resource pool state of /admission
+type admissionCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the admission metrics over to the provided channel
+func (c admissionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.admissionRunning
+	ch <- c.e.admissionQueued
+	ch <- c.e.admissionMaxRequests
+	ch <- c.e.admissionUtilization
+	ch <- c.e.admissionMemAdmitted
+	ch <- c.e.admissionMemReserved
+	ch <- c.e.admissionMaxMem
+}
+
+// Collect fetches the admission controller state of a server and sends it over to the provided channel
+func (c admissionCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	server := target.Name
+	var admission AdmissionResponse
+	if err := fetchJSON(ctx, target.Address, "/admission?json", &admission); err != nil {
+		return err
+	}
+
+	for _, pool := range admission.ResourcePools {
+		ch <- prometheus.MustNewConstMetric(e.admissionRunning, prometheus.GaugeValue, pool.NumRunning, server, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(e.admissionQueued, prometheus.GaugeValue, pool.NumQueued, server, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(e.admissionMaxRequests, prometheus.GaugeValue, pool.MaxRequests, server, pool.PoolName)
+		if ratio, ok := admissionUtilization(pool); ok {
+			ch <- prometheus.MustNewConstMetric(e.admissionUtilization, prometheus.GaugeValue, ratio, server, pool.PoolName)
+		}
+		ch <- prometheus.MustNewConstMetric(e.admissionMemAdmitted, prometheus.GaugeValue, float64(pool.MemAdmitted), server, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(e.admissionMemReserved, prometheus.GaugeValue, float64(pool.MemReserved), server, pool.PoolName)
+		ch <- prometheus.MustNewConstMetric(e.admissionMaxMem, prometheus.GaugeValue, float64(pool.MaxMem), server, pool.PoolName)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAdmissionUtilization(t *testing.T) {
+	tests := []struct {
+		name   string
+		pool   AdmissionPool
+		want   float64
+		wantOK bool
+	}{
+		{"half used", AdmissionPool{NumRunning: 5, MaxRequests: 10}, 0.5, true},
+		{"idle", AdmissionPool{NumRunning: 0, MaxRequests: 10}, 0, true},
+		{"full", AdmissionPool{NumRunning: 10, MaxRequests: 10}, 1, true},
+		{"unlimited", AdmissionPool{NumRunning: 5, MaxRequests: -1}, 0, false},
+		{"disabled", AdmissionPool{NumRunning: 0, MaxRequests: 0}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := admissionUtilization(tt.pool)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("admissionUtilization(%+v) = %v, %v, want %v, %v", tt.pool, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAdmissionResponseDecode(t *testing.T) {
+	body := `{"resource_pools": [{"pool_name": "root.default", "agg_num_running": 3, "agg_num_queued": 1, "max_requests": 20, "max_queued": 200}]}`
+	var admission AdmissionResponse
+	if err := json.Unmarshal([]byte(body), &admission); err != nil {
+		t.Fatalf("decoding admission JSON: %v", err)
+	}
+	want := AdmissionPool{PoolName: "root.default", NumRunning: 3, NumQueued: 1, MaxRequests: 20}
+	if len(admission.ResourcePools) != 1 || admission.ResourcePools[0] != want {
+		t.Errorf("decoded %+v, want [%+v]", admission.ResourcePools, want)
+	}
+}
+
+func TestAdmissionCollectorPoolMemory(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resource_pools": [
+			{"pool_name": "root.etl", "agg_num_running": 2, "agg_num_queued": 0, "max_requests": -1,
+			 "max_mem": 8589934592, "agg_mem_reserved": 2147483648, "local_mem_admitted": 1073741824},
+			{"pool_name": "root.adhoc", "agg_num_running": 0, "agg_num_queued": 0, "max_requests": -1,
+			 "max_mem": -1, "agg_mem_reserved": 0, "local_mem_admitted": 0}
+		]}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (admissionCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	want := map[string]float64{
+		`impala_admission_mem_admitted_bytes{pool="root.etl"}`:   1 << 30,
+		`impala_admission_mem_reserved_bytes{pool="root.etl"}`:   2 << 30,
+		`impala_admission_max_mem_bytes{pool="root.etl"}`:        8 << 30,
+		`impala_admission_mem_admitted_bytes{pool="root.adhoc"}`: 0,
+		`impala_admission_mem_reserved_bytes{pool="root.adhoc"}`: 0,
+		`impala_admission_max_mem_bytes{pool="root.adhoc"}`:      -1,
+	}
+	// The concurrency metrics are covered by TestAdmissionUtilization
+	maps.DeleteFunc(got, func(key string, _ float64) bool { return !strings.Contains(key, "_mem_") })
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
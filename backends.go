@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// backendsCollector exports the size of the cluster membership as seen by a coordinator on /backends
+type backendsCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_backends over to the provided channel
+func (c backendsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.backends
+}
+
+// Collect fetches the backends of a server and sends their number over to the provided channel
+func (c backendsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	var resp BackendsResponse
+	if err := fetchJSON(ctx, target.Address, "/backends?json", &resp); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.e.backends, prometheus.GaugeValue, float64(len(resp.Backends)), target.Name)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// targetBreaker is the circuit breaker state of a single server
+type targetBreaker struct {
+	failures  int
+	open      bool
+	nextProbe time.Time
+}
+
+// breakerSet holds a circuit breaker per server. A breaker opens after threshold consecutive failed scrapes of its
+// server, which is then only probed every probeInterval instead of on every scrape, until a probe succeeds.
+type breakerSet struct {
+	threshold     int
+	probeInterval time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*targetBreaker
+}
+
+// newBreakerSet creates the circuit breakers of the servers; a threshold of 0 disables them
+func newBreakerSet(threshold int, probeInterval time.Duration) *breakerSet {
+	return &breakerSet{threshold: threshold, probeInterval: probeInterval, breakers: make(map[string]*targetBreaker)}
+}
+
+// allow reports whether target may be scraped at now: always while its breaker is closed, and once per probe
+// interval while it is open
+func (b *breakerSet) allow(target string, now time.Time) bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker, ok := b.breakers[target]
+	if !ok || !breaker.open {
+		return true
+	}
+	if now.Before(breaker.nextProbe) {
+		return false
+	}
+	// Claim the probe, so that overlapping scrapes do not all probe the server
+	breaker.nextProbe = now.Add(b.probeInterval)
+	return true
+}
+
+// record updates the breaker of target with the outcome of a scrape
+func (b *breakerSet) record(target string, success bool, now time.Time) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker, ok := b.breakers[target]
+	if !ok {
+		breaker = &targetBreaker{}
+		b.breakers[target] = breaker
+	}
+	if success {
+		if breaker.open {
+			slog.Info("Server recovered, closing circuit", "target", target)
+		}
+		*breaker = targetBreaker{}
+		return
+	}
+	breaker.failures++
+	if breaker.failures >= b.threshold && !breaker.open {
+		slog.Warn("Server keeps failing, opening circuit", "target", target, "failures", breaker.failures, "probe_interval", b.probeInterval)
+		breaker.open = true
+		breaker.nextProbe = now.Add(b.probeInterval)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBreakerSet(t *testing.T) {
+	b := newBreakerSet(2, time.Minute)
+	now := time.Now()
+
+	b.record("coord", false, now)
+	if !b.allow("coord", now) {
+		t.Fatal("allow() = false after one failure, want the circuit closed")
+	}
+	b.record("coord", false, now)
+	if b.allow("coord", now.Add(time.Second)) {
+		t.Fatal("allow() = true after two failures, want the circuit open")
+	}
+	if !b.allow("coord", now.Add(time.Minute)) {
+		t.Fatal("allow() = false after the probe interval, want a probe")
+	}
+	if b.allow("coord", now.Add(time.Minute+time.Second)) {
+		t.Fatal("allow() = true during a probe, want a single probe per interval")
+	}
+	b.record("coord", true, now.Add(time.Minute+2*time.Second))
+	if !b.allow("coord", now.Add(time.Minute+3*time.Second)) {
+		t.Fatal("allow() = false after a successful probe, want the circuit closed")
+	}
+}
+
+func TestBreakerSetDisabled(t *testing.T) {
+	b := newBreakerSet(0, time.Minute)
+	now := time.Now()
+	for range 10 {
+		b.record("coord", false, now)
+	}
+	if !b.allow("coord", now) {
+		t.Error("allow() = false with the breakers disabled")
+	}
+}
+
+func TestCollectSkipsServerWithOpenCircuit(t *testing.T) {
+	var requests atomic.Int32
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer impala.Close()
+
+	e := NewExporter([]string{"coord=" + impala.Listener.Addr().String()}, ExporterOptions{BreakerThreshold: 1, BreakerProbeInterval: time.Hour})
+	collect := func() map[string]float64 {
+		return collectValues(t, e, func(ch chan<- prometheus.Metric) { e.Collect(ch) })
+	}
+
+	if got := collect()["impala_up"]; got != 0 {
+		t.Errorf("impala_up = %v after a failed scrape, want 0", got)
+	}
+	before := requests.Load()
+	if got := collect()["impala_up"]; got != 0 {
+		t.Errorf("impala_up = %v with the circuit open, want 0", got)
+	}
+	if requests.Load() != before {
+		t.Errorf("server was requested %d times with the circuit open, want 0", requests.Load()-before)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var impalaVersionRe = regexp.MustCompile(`version (\S+).*\(build ([0-9a-fA-F]+)\)`)
+
+// RootResponse represents the structure of the JSON response from Impala for the root page
+type RootResponse struct {
+	Version string `json:"version"`
+}
+
+// ParseImpalaVersion extracts the version and build hash from a version string such as
+// "impalad version 4.1.0-RELEASE RELEASE (build 0a1b2c3d)"
+func ParseImpalaVersion(version string) (string, string) {
+	matches := impalaVersionRe.FindStringSubmatch(version)
+	if matches == nil {
+		return "unknown", "unknown"
+	}
+	return matches[1], matches[2]
+}
+
+// buildInfoRefresh is how long a fetched version is reused; it only changes when the daemon restarts
+const buildInfoRefresh = 10 * time.Minute
+
+// cachedBuildInfo is the last version fetched from a server
+type cachedBuildInfo struct {
+	version   string
+	buildHash string
+	fetched   time.Time
+}
+
+// buildInfoCollector exports impala_build_info from the root page, served by every daemon role
+type buildInfoCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_build_info over to the provided channel
+func (c buildInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.buildInfo
+}
+
+// Collect sends the version of a server over to the provided channel,
+// fetching it from the root page only when the cached value is older than buildInfoRefresh.
+// A version cached before a failed refresh is still sent.
+func (c buildInfoCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	e.buildInfoMu.Lock()
+	info, ok := e.buildInfoCache[target.Address]
+	e.buildInfoMu.Unlock()
+
+	var err error
+	if !ok || time.Since(info.fetched) > buildInfoRefresh {
+		var version, buildHash string
+		if version, buildHash, err = fetchBuildInfo(ctx, target.Address); err == nil {
+			info = cachedBuildInfo{version: version, buildHash: buildHash, fetched: time.Now()}
+			ok = true
+			e.buildInfoMu.Lock()
+			e.buildInfoCache[target.Address] = info
+			e.buildInfoMu.Unlock()
+		}
+	}
+	if ok {
+		ch <- prometheus.MustNewConstMetric(e.buildInfo, prometheus.GaugeValue, 1, target.Name, info.version, info.buildHash)
+	}
+	return err
+}
+
+// fetchBuildInfo fetches the root page of the server at address and returns its version and build hash
+func fetchBuildInfo(ctx context.Context, address string) (string, string, error) {
+	var root RootResponse
+	if err := fetchJSON(ctx, address, "/?json", &root); err != nil {
+		return "", "", err
+	}
+
+	version, buildHash := ParseImpalaVersion(root.Version)
+	return version, buildHash, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseImpalaVersion(t *testing.T) {
+	tests := []struct {
+		input         string
+		wantVersion   string
+		wantBuildHash string
+	}{
+		{
+			"impalad version 4.1.0-RELEASE RELEASE (build 1d2c8b0e5a2cae0fd3ff3c9bb0c2d2a8d8f0a7c1)\nBuilt on Mon Jan  1 00:00:00 UTC 2024",
+			"4.1.0-RELEASE", "1d2c8b0e5a2cae0fd3ff3c9bb0c2d2a8d8f0a7c1",
+		},
+		{
+			"impalad version 3.4.0-cdh6.3.2 RELEASE (build 5e8b9a2b52a7c6d3c4d1a7e0b3f2c1d0e9f8a7b6)\nBuilt on Wed Nov  6 16:32:35 PST 2019",
+			"3.4.0-cdh6.3.2", "5e8b9a2b52a7c6d3c4d1a7e0b3f2c1d0e9f8a7b6",
+		},
+		{
+			"catalogd version 4.4.0-SNAPSHOT DEBUG (build abc123)",
+			"4.4.0-SNAPSHOT", "abc123",
+		},
+		{"", "unknown", "unknown"},
+		{"Impala web UI", "unknown", "unknown"},
+		{"impalad version 4.1.0\nBuilt on Mon Jan  1 00:00:00 UTC 2024 (build abc123)", "unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		version, buildHash := ParseImpalaVersion(tt.input)
+		if version != tt.wantVersion || buildHash != tt.wantBuildHash {
+			t.Errorf("ParseImpalaVersion(%q) = %q, %q, want %q, %q", tt.input, version, buildHash, tt.wantVersion, tt.wantBuildHash)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// canaryPasswordEnv holds the password of -canary.user unless -canary.password-file is set
+const canaryPasswordEnv = "IMPALA_EXPORTER_CANARY_PASSWORD"
+
+var (
+	canaryQueryFlag        = flag.String("canary.query", "select 1", "Query run over HiveServer2 by the canary collector")
+	canaryPortFlag         = flag.Int("canary.port", 21050, "HiveServer2 port of the coordinators, reached on the host of their web UI address")
+	canaryTimeoutFlag      = flag.Duration("canary.timeout", 5*time.Second, "Maximum duration of a canary query, including connecting and authenticating")
+	canaryAuthFlag         = flag.String("canary.auth", "nosasl", "Authentication of the canary connection: nosasl, or plain for SASL PLAIN as with LDAP authentication")
+	canaryUserFlag         = flag.String("canary.user", "", "User the canary query runs as")
+	canaryPasswordFileFlag = flag.String("canary.password-file", "", "Path of a file holding the password of -canary.user for -canary.auth=plain; when unset the password is read from $"+canaryPasswordEnv)
+)
+
+// canaryConfig is how the canary collector reaches the coordinators and what it runs
+type canaryConfig struct {
+	query   string
+	port    int
+	timeout time.Duration
+	auth    hs2Auth
+}
+
+// canaryConfigFromFlags returns the canary configuration set by the command line flags, which must have been parsed
+func canaryConfigFromFlags() (canaryConfig, error) {
+	config := canaryConfig{query: *canaryQueryFlag, port: *canaryPortFlag, timeout: *canaryTimeoutFlag}
+	switch *canaryAuthFlag {
+	case "nosasl":
+	case "plain":
+		password, _, err := readSecret(*canaryPasswordFileFlag, canaryPasswordEnv)
+		if err != nil {
+			return config, fmt.Errorf("reading canary password: %w", err)
+		}
+		config.auth = hs2Auth{mechanism: "PLAIN", user: *canaryUserFlag, password: password}
+	default:
+		return config, fmt.Errorf("invalid canary authentication %q, want nosasl or plain", *canaryAuthFlag)
+	}
+	config.auth.user = *canaryUserFlag
+	if config.query == "" {
+		return config, errors.New("the canary query is empty")
+	}
+	return config, nil
+}
+
+// canaryCollector runs a trivial query over the HiveServer2 port of a coordinator, proving that queries can be
+// run and not just that the web UI answers. Executors do not accept client connections, so the collector is
+// meant for coordinators.
+type canaryCollector struct {
+	e *Exporter
+}
+
+func (c canaryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.canarySuccess
+	ch <- c.e.canaryDuration
+}
+
+// Collect runs the canary query against target, sending whether it succeeded and, if so, how long it took
+func (c canaryCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	config := e.options.Canary
+	host, _, err := net.SplitHostPort(target.Address)
+	if err != nil {
+		return err
+	}
+	if config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err = runCanaryQuery(ctx, net.JoinHostPort(host, fmt.Sprint(config.port)), config)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(e.canarySuccess, prometheus.GaugeValue, 0, target.Name)
+		return fmt.Errorf("canary query: %w", err)
+	}
+	ch <- prometheus.MustNewConstMetric(e.canarySuccess, prometheus.GaugeValue, 1, target.Name)
+	ch <- prometheus.MustNewConstMetric(e.canaryDuration, prometheus.GaugeValue, time.Since(start).Seconds(), target.Name)
+	return nil
+}
+
+// runCanaryQuery connects to the HiveServer2 port at address and runs the canary query
+func runCanaryQuery(ctx context.Context, address string, config canaryConfig) error {
+	conn, err := dialHS2(ctx, address, config.auth)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.runQuery(config.query, config.auth)
+}
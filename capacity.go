@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteSizeRe matches a byte count as pretty-printed by Impala, e.g. "12.50 GB" or "0"
+var byteSizeRe = regexp.MustCompile(`^(-?[0-9.]+)\s*([KMGTP]?B)?$`)
+
+// byteSizeUnits maps Impala's binary byte units to their size
+var byteSizeUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+	"PB": 1 << 50,
+}
+
+// ByteSize is a number of bytes that Impala renders either as a JSON number or as a pretty-printed string
+type ByteSize int64
+
+// UnmarshalJSON accepts a number of bytes or a string such as "1.50 GB"
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid byte size %s", data)
+	}
+	size, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// parseByteSize parses a pretty-printed byte count such as "1.50 GB"
+func parseByteSize(s string) (ByteSize, error) {
+	matches := byteSizeRe.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	return ByteSize(value * byteSizeUnits[matches[2]]), nil
+}
+
+// BackendCapacity is a daemon along with its admission capacity as rendered by Impala's /backends?json page
+type BackendCapacity struct {
+	Backend
+	AdmitMemLimit  ByteSize `json:"admit_mem_limit"`
+	MemReserved    ByteSize `json:"mem_reserved"`
+	MemAdmitted    ByteSize `json:"mem_admitted"`
+	AdmissionSlots int      `json:"admission_slots"`
+}
+
+// BackendsCapacityResponse represents the capacity parts of the JSON response from Impala for /backends
+type BackendsCapacityResponse struct {
+	Backends []BackendCapacity `json:"backends"`
+}
+
+// MemzResponse represents the structure of the JSON response from Impala for /memz
+type MemzResponse struct {
+	MemLimit    ByteSize `json:"mem_limit"`
+	Consumption ByteSize `json:"consumption"`
+	// Detailed is the memory tracker breakdown, see parseMemTrackers
+	Detailed string `json:"detailed"`
+}
+
+// CapacityReport is the cluster capacity served on /api/v1/capacity
+type CapacityReport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Coordinator is the server the report was assembled from
+	Coordinator string           `json:"coordinator"`
+	Totals      CapacityTotals   `json:"totals"`
+	Process     ProcessCapacity  `json:"coordinator_process"`
+	Backends    []BackendSummary `json:"backends"`
+	Pools       []PoolCapacity   `json:"pools"`
+}
+
+// CapacityTotals sums the admission capacity of the executors of the cluster
+type CapacityTotals struct {
+	Executors        int   `json:"executors"`
+	MemLimitBytes    int64 `json:"mem_limit_bytes"`
+	MemReservedBytes int64 `json:"mem_reserved_bytes"`
+	MemAdmittedBytes int64 `json:"mem_admitted_bytes"`
+	MemHeadroomBytes int64 `json:"mem_headroom_bytes"`
+	AdmissionSlots   int   `json:"admission_slots"`
+	RunningQueries   int   `json:"running_queries"`
+	QueuedQueries    int   `json:"queued_queries"`
+}
+
+// ProcessCapacity is the memory of the coordinator process the report was assembled from
+type ProcessCapacity struct {
+	MemLimitBytes    int64 `json:"mem_limit_bytes"`
+	ConsumptionBytes int64 `json:"consumption_bytes"`
+}
+
+// BackendSummary is the admission capacity of a single daemon
+type BackendSummary struct {
+	Address          string `json:"address"`
+	IsCoordinator    bool   `json:"is_coordinator"`
+	IsExecutor       bool   `json:"is_executor"`
+	MemLimitBytes    int64  `json:"mem_limit_bytes"`
+	MemReservedBytes int64  `json:"mem_reserved_bytes"`
+	MemAdmittedBytes int64  `json:"mem_admitted_bytes"`
+	AdmissionSlots   int    `json:"admission_slots"`
+}
+
+// PoolCapacity is the admission state and headroom of a resource pool.
+// A limit or headroom of -1 means the pool has no limit of that kind.
+type PoolCapacity struct {
+	Name             string `json:"name"`
+	Running          int    `json:"running"`
+	Queued           int    `json:"queued"`
+	MaxRequests      int    `json:"max_requests"`
+	RequestsHeadroom int    `json:"requests_headroom"`
+	MaxMemBytes      int64  `json:"max_mem_bytes"`
+	MemReservedBytes int64  `json:"mem_reserved_bytes"`
+	MemHeadroomBytes int64  `json:"mem_headroom_bytes"`
+}
+
+// buildCapacityReport combines the backends, admission and memz pages of a coordinator into a capacity report
+func buildCapacityReport(coordinator string, backends BackendsCapacityResponse, admission AdmissionResponse, memz MemzResponse) CapacityReport {
+	report := CapacityReport{
+		GeneratedAt: time.Now().UTC(),
+		Coordinator: coordinator,
+		Process:     ProcessCapacity{MemLimitBytes: int64(memz.MemLimit), ConsumptionBytes: int64(memz.Consumption)},
+		Backends:    make([]BackendSummary, 0, len(backends.Backends)),
+		Pools:       make([]PoolCapacity, 0, len(admission.ResourcePools)),
+	}
+
+	for _, b := range backends.Backends {
+		report.Backends = append(report.Backends, BackendSummary{
+			Address:          b.Address,
+			IsCoordinator:    b.IsCoordinator,
+			IsExecutor:       b.IsExecutor,
+			MemLimitBytes:    int64(b.AdmitMemLimit),
+			MemReservedBytes: int64(b.MemReserved),
+			MemAdmittedBytes: int64(b.MemAdmitted),
+			AdmissionSlots:   b.AdmissionSlots,
+		})
+		// Queries are admitted against the executors, coordinators only run their coordinator fragments
+		if !b.IsExecutor {
+			continue
+		}
+		report.Totals.Executors++
+		report.Totals.MemLimitBytes += int64(b.AdmitMemLimit)
+		report.Totals.MemReservedBytes += int64(b.MemReserved)
+		report.Totals.MemAdmittedBytes += int64(b.MemAdmitted)
+		report.Totals.AdmissionSlots += b.AdmissionSlots
+	}
+	report.Totals.MemHeadroomBytes = max(report.Totals.MemLimitBytes-max(report.Totals.MemAdmittedBytes, report.Totals.MemReservedBytes), 0)
+
+	for _, pool := range admission.ResourcePools {
+		p := PoolCapacity{
+			Name:             pool.PoolName,
+			Running:          int(pool.NumRunning),
+			Queued:           int(pool.NumQueued),
+			MaxRequests:      int(pool.MaxRequests),
+			RequestsHeadroom: -1,
+			MaxMemBytes:      int64(pool.MaxMem),
+			MemReservedBytes: int64(pool.MemReserved),
+			MemHeadroomBytes: -1,
+		}
+		// A max_requests of -1 means no limit, 0 means the pool admits nothing
+		if p.MaxRequests >= 0 {
+			p.RequestsHeadroom = max(p.MaxRequests-p.Running, 0)
+		}
+		if p.MaxMemBytes >= 0 {
+			p.MemHeadroomBytes = max(p.MaxMemBytes-p.MemReservedBytes, 0)
+		}
+		report.Totals.RunningQueries += p.Running
+		report.Totals.QueuedQueries += p.Queued
+		report.Pools = append(report.Pools, p)
+	}
+	return report
+}
+
+// fetchCapacityReport assembles a capacity report from a coordinator's backends, admission and memz pages
+func fetchCapacityReport(ctx context.Context, target Target) (CapacityReport, error) {
+	var backends BackendsCapacityResponse
+	if err := fetchJSON(ctx, target.Address, "/backends?json", &backends); err != nil {
+		return CapacityReport{}, err
+	}
+	var admission AdmissionResponse
+	if err := fetchJSON(ctx, target.Address, "/admission?json", &admission); err != nil {
+		return CapacityReport{}, err
+	}
+	var memz MemzResponse
+	if err := fetchJSON(ctx, target.Address, "/memz?json", &memz); err != nil {
+		return CapacityReport{}, err
+	}
+	return buildCapacityReport(target.Name, backends, admission, memz), nil
+}
+
+// capacityHandler serves a capacity report generated on demand from the coordinator given by the target query
+// parameter, or from the first impalad target that answers
+func capacityHandler(exporter *Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("target")
+		var candidates []Target
+		for _, target := range exporter.Targets() {
+			if name == "" && (target.Role == "statestored" || target.Role == "catalogd") {
+				continue
+			}
+			if name == "" || target.Name == name {
+				candidates = append(candidates, target)
+			}
+		}
+		if len(candidates) == 0 {
+			http.Error(w, "no matching coordinator", http.StatusNotFound)
+			return
+		}
+
+		var errs []string
+		for _, target := range candidates {
+			report, err := fetchCapacityReport(r.Context(), target)
+			if err != nil {
+				slog.Warn("Error building capacity report", "target", target.Name, "err", err)
+				errs = append(errs, fmt.Sprintf("%s: %v", target.Name, err))
+				continue
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(report); err != nil {
+				slog.Error("Error writing capacity report", "err", err)
+			}
+			return
+		}
+		http.Error(w, "no coordinator answered: "+strings.Join(errs, "; "), http.StatusBadGateway)
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ByteSize
+	}{
+		{"0", 0},
+		{"512 B", 512},
+		{"1.50 KB", 1536},
+		{"10.00 GB", 10 << 30},
+		{"2.00 TB", 2 << 40},
+		{"-1.00 B", -1},
+	}
+	for _, tt := range tests {
+		if got, err := parseByteSize(tt.input); err != nil || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := parseByteSize("lots"); err == nil {
+		t.Error("parseByteSize(\"lots\") succeeded")
+	}
+}
+
+func TestCapacityHandler(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/backends":
+			w.Write([]byte(`{"backends": [
+				{"address": "coord:27000", "is_coordinator": true, "is_executor": false, "admit_mem_limit": "4.00 GB", "mem_reserved": "0", "mem_admitted": "0", "admission_slots": 8},
+				{"address": "exec1:27000", "is_coordinator": false, "is_executor": true, "admit_mem_limit": "10.00 GB", "mem_reserved": "2.00 GB", "mem_admitted": "3.00 GB", "admission_slots": 16},
+				{"address": "exec2:27000", "is_coordinator": false, "is_executor": true, "admit_mem_limit": 10737418240, "mem_reserved": 1073741824, "mem_admitted": 0, "admission_slots": 16}
+			]}`))
+		case "/admission":
+			w.Write([]byte(`{"resource_pools": [
+				{"pool_name": "root.etl", "agg_num_running": 3, "agg_num_queued": 2, "max_requests": 5, "max_mem": 8589934592, "agg_mem_reserved": 2147483648},
+				{"pool_name": "root.adhoc", "agg_num_running": 1, "agg_num_queued": 0, "max_requests": -1, "max_mem": -1, "agg_mem_reserved": 0}
+			]}`))
+		case "/memz":
+			w.Write([]byte(`{"mem_limit": "16.00 GB", "consumption": "1.25 GB"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer impala.Close()
+
+	exporter := NewExporter([]string{"coord-1=" + strings.TrimPrefix(impala.URL, "http://")}, ExporterOptions{})
+	rec := httptest.NewRecorder()
+	capacityHandler(exporter).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var report CapacityReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+
+	wantTotals := CapacityTotals{
+		Executors:        2,
+		MemLimitBytes:    20 << 30,
+		MemReservedBytes: 3 << 30,
+		MemAdmittedBytes: 3 << 30,
+		MemHeadroomBytes: 17 << 30,
+		AdmissionSlots:   32,
+		RunningQueries:   4,
+		QueuedQueries:    2,
+	}
+	if report.Coordinator != "coord-1" || report.Totals != wantTotals {
+		t.Errorf("report from %q with totals %+v, want coord-1 with %+v", report.Coordinator, report.Totals, wantTotals)
+	}
+	if want := (ProcessCapacity{MemLimitBytes: 16 << 30, ConsumptionBytes: 1342177280}); report.Process != want {
+		t.Errorf("coordinator process %+v, want %+v", report.Process, want)
+	}
+	wantPools := []PoolCapacity{
+		{Name: "root.etl", Running: 3, Queued: 2, MaxRequests: 5, RequestsHeadroom: 2, MaxMemBytes: 8 << 30, MemReservedBytes: 2 << 30, MemHeadroomBytes: 6 << 30},
+		{Name: "root.adhoc", Running: 1, MaxRequests: -1, RequestsHeadroom: -1, MaxMemBytes: -1, MemHeadroomBytes: -1},
+	}
+	if len(report.Pools) != len(wantPools) || report.Pools[0] != wantPools[0] || report.Pools[1] != wantPools[1] {
+		t.Errorf("pools %+v, want %+v", report.Pools, wantPools)
+	}
+
+	rec = httptest.NewRecorder()
+	capacityHandler(exporter).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity?target=other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown target: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
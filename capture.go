@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCapture, when set, saves the responses fetched from the Impala web UIs, see -debug.capture-dir
+var responseCapture *captureStore
+
+// captureStore saves the raw responses of the Impala web UIs to a directory, for support bundles: the last response
+// of an endpoint of a server goes to <dir>/<server address>/<endpoint>.json, which -impala.fixture-dir can serve
+// back, and the last keep responses to <endpoint>-<UTC timestamp>.json next to it.
+type captureStore struct {
+	mu   sync.Mutex
+	dir  string
+	keep int
+}
+
+// save writes the response body of path fetched from address, pruning the older responses of the endpoint. Errors
+// are logged only, capturing is a debugging aid that must not fail a scrape.
+func (s *captureStore) save(address, requestPath string, body []byte) {
+	endpoint, _, _ := strings.Cut(requestPath, "?")
+	name := path.Base(endpoint)
+	if name == "/" || name == "." {
+		return
+	}
+	dir := filepath.Join(s.dir, address)
+	stamp := time.Now().UTC().Format("20060102T150405.000000000Z")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(dir, name, stamp, body); err != nil {
+		slog.Warn("Error capturing response", "target", address, "endpoint", endpoint, "err", err)
+	}
+}
+
+func (s *captureStore) write(dir, name, stamp string, body []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"-"+stamp+".json"), body, 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), body, 0o600); err != nil {
+		return err
+	}
+	// The timestamps sort chronologically, the oldest beyond keep are removed
+	history, err := filepath.Glob(filepath.Join(dir, name+"-*.json"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(history)
+	for _, old := range history[:max(len(history)-s.keep, 0)] {
+		if err := os.Remove(old); err != nil {
+			return fmt.Errorf("pruning captured responses: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseCapture(t *testing.T) {
+	calls := 0
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"call": %d}`, calls)
+	}))
+	defer impala.Close()
+	address := strings.TrimPrefix(impala.URL, "http://")
+
+	dir := t.TempDir()
+	defer func(c *captureStore) { responseCapture = c }(responseCapture)
+	responseCapture = &captureStore{dir: dir, keep: 2}
+	for range 3 {
+		var v map[string]int
+		if err := fetchJSON(context.Background(), address, "/sessions?json", &v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := os.ReadFile(filepath.Join(dir, address, "sessions.json"))
+	if err != nil || string(latest) != `{"call": 3}` {
+		t.Errorf("latest capture = %q, %v, want the third response", latest, err)
+	}
+	history, _ := filepath.Glob(filepath.Join(dir, address, "sessions-*.json"))
+	if len(history) != 2 {
+		t.Fatalf("kept %d captured responses, want 2", len(history))
+	}
+	if oldest, _ := os.ReadFile(history[0]); string(oldest) != `{"call": 2}` {
+		t.Errorf("oldest kept capture = %q, want the second response", oldest)
+	}
+
+	// The capture directory can be served back as fixtures
+	defer func(rt http.RoundTripper) { httpClient.Transport = rt }(httpClient.Transport)
+	httpClient.Transport = fixtureTransport{dir: dir}
+	var v map[string]int
+	if err := fetchJSON(context.Background(), address, "/sessions?json", &v); err != nil || v["call"] != 3 {
+		t.Errorf("fetchJSON() from the captures = %v, %v, want the third response", v, err)
+	}
+}
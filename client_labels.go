@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// clientLabelModes are the renderings of the impala_client label selectable with -sessions.client-label:
+// the hostname as is, a hash of it, its domain, or nothing at all
+var clientLabelModes = []string{"keep", "hash", "domain", "drop"}
+
+// clientLabel renders the hostname of a client as the impala_client label according to mode.
+// The domain of an IP address is its /24 network for IPv4 and its /64 network for IPv6; a hostname without domain
+// and a dropped hostname are rendered as an empty label, which Prometheus treats as no label.
+func clientLabel(mode, hostname string) string {
+	switch mode {
+	case "hash":
+		sum := sha256.Sum256([]byte(hostname))
+		return hex.EncodeToString(sum[:8])
+	case "domain":
+		host := hostname
+		if h, _, err := net.SplitHostPort(hostname); err == nil {
+			host = h
+		}
+		if addr, err := netip.ParseAddr(host); err == nil {
+			bits := 24
+			if addr.Is6() && !addr.Is4In6() {
+				bits = 64
+			}
+			prefix, _ := addr.Unmap().Prefix(bits)
+			return prefix.String()
+		}
+		_, domain, _ := strings.Cut(host, ".")
+		return domain
+	case "drop":
+		return ""
+	}
+	return hostname
+}
+
+// relabelClients returns hosts with their hostname rendered according to mode, summing the clients that end up with
+// the same label, and totals, keyed by hostname, summed alike
+func relabelClients(mode string, hosts []ImpalaClientHost, totals map[string]float64) ([]ImpalaClientHost, map[string]float64) {
+	if mode == "" || mode == "keep" {
+		return hosts, totals
+	}
+	relabeled := make([]ImpalaClientHost, 0, len(hosts))
+	index := make(map[string]int, len(hosts))
+	relabeledTotals := make(map[string]float64, len(totals))
+	for _, host := range hosts {
+		label := clientLabel(mode, host.Hostname)
+		relabeledTotals[label] += totals[host.Hostname]
+		i, ok := index[label]
+		if !ok {
+			index[label] = len(relabeled)
+			relabeled = append(relabeled, ImpalaClientHost{Hostname: label})
+			i = len(relabeled) - 1
+		}
+		r := &relabeled[i]
+		r.TotalConnections += host.TotalConnections
+		r.TotalSessions += host.TotalSessions
+		r.TotalActiveSessions += host.TotalActiveSessions
+		r.TotalInactiveSessions += host.TotalInactiveSessions
+		r.InflightQueries += host.InflightQueries
+		r.TotalQueries += host.TotalQueries
+	}
+	return relabeled, relabeledTotals
+}
+
+// compileClientPattern compiles a -sessions.client-include or -sessions.client-exclude pattern, anchored at both
+// ends like a Prometheus label matcher; an empty pattern gives nil
+func compileClientPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// filterClients returns the hosts whose hostname matches include, when set, and does not match exclude, when set
+func filterClients(include, exclude *regexp.Regexp, hosts []ImpalaClientHost) []ImpalaClientHost {
+	if include == nil && exclude == nil {
+		return hosts
+	}
+	filtered := make([]ImpalaClientHost, 0, len(hosts))
+	for _, host := range hosts {
+		if include != nil && !include.MatchString(host.Hostname) || exclude != nil && exclude.MatchString(host.Hostname) {
+			continue
+		}
+		filtered = append(filtered, host)
+	}
+	return filtered
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestClientLabel(t *testing.T) {
+	tests := []struct {
+		mode, hostname, want string
+	}{
+		{"keep", "ws1.corp.example.com", "ws1.corp.example.com"},
+		{"domain", "ws1.corp.example.com", "corp.example.com"},
+		{"domain", "ws1", ""},
+		{"domain", "10.1.2.3", "10.1.2.0/24"},
+		{"domain", "10.1.2.3:51234", "10.1.2.0/24"},
+		{"domain", "[2001:db8::1]:51234", "2001:db8::/64"},
+		{"domain", "::ffff:10.1.2.3", "10.1.2.0/24"},
+		{"drop", "ws1.corp.example.com", ""},
+	}
+	for _, tt := range tests {
+		if got := clientLabel(tt.mode, tt.hostname); got != tt.want {
+			t.Errorf("clientLabel(%q, %q) = %q, want %q", tt.mode, tt.hostname, got, tt.want)
+		}
+	}
+	hashed := clientLabel("hash", "ws1.corp.example.com")
+	if len(hashed) != 16 || hashed != clientLabel("hash", "ws1.corp.example.com") || hashed == clientLabel("hash", "ws2.corp.example.com") {
+		t.Errorf("clientLabel(hash) = %q, want a stable 16 digit hash distinct per hostname", hashed)
+	}
+}
+
+func TestRelabelClients(t *testing.T) {
+	hosts := []ImpalaClientHost{
+		{Hostname: "ws1.a.example.com", TotalSessions: 2, TotalQueries: 10},
+		{Hostname: "ws2.b.example.com", TotalSessions: 1, TotalQueries: 4},
+		{Hostname: "ws3.a.example.com", TotalSessions: 3, InflightQueries: 1, TotalQueries: 5},
+	}
+	totals := map[string]float64{"ws1.a.example.com": 12, "ws2.b.example.com": 4, "ws3.a.example.com": 5}
+	gotHosts, gotTotals := relabelClients("domain", hosts, totals)
+	wantHosts := []ImpalaClientHost{
+		{Hostname: "a.example.com", TotalSessions: 5, InflightQueries: 1, TotalQueries: 15},
+		{Hostname: "b.example.com", TotalSessions: 1, TotalQueries: 4},
+	}
+	if !reflect.DeepEqual(gotHosts, wantHosts) {
+		t.Errorf("relabelClients() hosts = %+v, want %+v", gotHosts, wantHosts)
+	}
+	if want := map[string]float64{"a.example.com": 17, "b.example.com": 4}; !reflect.DeepEqual(gotTotals, want) {
+		t.Errorf("relabelClients() totals = %v, want %v", gotTotals, want)
+	}
+	if gotHosts, _ := relabelClients("keep", hosts, totals); !reflect.DeepEqual(gotHosts, hosts) {
+		t.Errorf("relabelClients(keep) changed the hosts: %+v", gotHosts)
+	}
+}
+
+func TestFilterClients(t *testing.T) {
+	hosts := []ImpalaClientHost{{Hostname: "ws1.corp"}, {Hostname: "spark-exec-17.corp"}, {Hostname: "etl.corp"}, {Hostname: "ws2.corp.evil"}}
+	tests := []struct {
+		include, exclude string
+		want             []string
+	}{
+		{"", "", []string{"ws1.corp", "spark-exec-17.corp", "etl.corp", "ws2.corp.evil"}},
+		{"", `spark-exec-\d+\..*`, []string{"ws1.corp", "etl.corp", "ws2.corp.evil"}},
+		// Patterns are anchored, .corp does not match ws2.corp.evil
+		{`.*\.corp`, "", []string{"ws1.corp", "spark-exec-17.corp", "etl.corp"}},
+		{`.*\.corp`, "spark-.*|etl.*", []string{"ws1.corp"}},
+	}
+	for _, tt := range tests {
+		include, err := compileClientPattern(tt.include)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exclude, err := compileClientPattern(tt.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, host := range filterClients(include, exclude, hosts) {
+			got = append(got, host.Hostname)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterClients(%q, %q) = %v, want %v", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+	if _, err := compileClientPattern("("); err == nil {
+		t.Error("compileClientPattern accepted an invalid pattern")
+	}
+}
+
+func TestCollectAggregateClients(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"client_hosts": [
+			{"hostname": "ws1", "total_connections": 2, "total_sessions": 3, "inflight_queries": 1, "total_queries": 10},
+			{"hostname": "ws2", "total_connections": 1, "total_sessions": 1, "total_queries": 4},
+			{"hostname": "spark-exec-1", "total_connections": 5, "total_sessions": 5, "total_queries": 50}
+		]}`))
+	}))
+	defer impala.Close()
+
+	exclude, _ := compileClientPattern("spark-exec-.*")
+	e := NewExporter(nil, ExporterOptions{AggregateClients: true, ClientExclude: exclude})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (sessionsCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	want := map[string]float64{
+		"impala_total_connections":       3,
+		"impala_total_sessions":          4,
+		"impala_total_active_sessions":   0,
+		"impala_total_inactive_sessions": 0,
+		"impala_inflight_queries":        1,
+		"impala_total_queries":           14,
+		"impala_client_queries_total":    14,
+		// Not per-client metrics, exported without sessions too
+		"impala_oldest_session_age_seconds": 0,
+		"impala_expired_sessions":           0,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	clientPortsFlag        = flag.String("ports.client-ports", "hs2=21050,beeswax=21000", "Comma-separated port_type=port client ports of the coordinators checked by the ports collector, reached on the host of their web UI address")
+	clientPortsTimeoutFlag = flag.Duration("ports.timeout", 2*time.Second, "Maximum duration of the check of a client port, including connecting and authenticating")
+)
+
+// clientPort is a Thrift client port of a coordinator
+type clientPort struct {
+	name string
+	port int
+}
+
+// clientPortsConfig is which client ports the ports collector checks and how
+type clientPortsConfig struct {
+	ports   []clientPort
+	timeout time.Duration
+	// auth is the authentication of the client ports, shared with the canary since Impala authenticates both
+	// HiveServer2 and Beeswax clients alike
+	auth hs2Auth
+}
+
+// clientPortsConfigFromFlags returns the configuration of the ports collector set by the command line flags, which
+// must have been parsed
+func clientPortsConfigFromFlags(auth hs2Auth) (clientPortsConfig, error) {
+	ports, err := parseClientPorts(*clientPortsFlag)
+	return clientPortsConfig{ports: ports, timeout: *clientPortsTimeoutFlag, auth: auth}, err
+}
+
+// parseClientPorts parses comma-separated port_type=port pairs
+func parseClientPorts(s string) ([]clientPort, error) {
+	var ports []clientPort
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		port, err := strconv.Atoi(value)
+		if !ok || name == "" || err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("expected port_type=port, got %q", pair)
+		}
+		ports = append(ports, clientPort{name: name, port: port})
+	}
+	return ports, nil
+}
+
+// clientPortsCollector checks that the Thrift client ports of a coordinator process requests, which may wedge
+// while the web UI stays healthy: a port is up when it accepts a connection, authenticates and answers a call
+type clientPortsCollector struct {
+	e *Exporter
+}
+
+func (c clientPortsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.clientPortUp
+}
+
+// Collect checks every client port of target, sending whether it is up. A port that is down is a measurement, not
+// a failure of the collector.
+func (c clientPortsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	config := e.options.ClientPorts
+	host, _, err := net.SplitHostPort(target.Address)
+	if err != nil {
+		return err
+	}
+	for _, port := range config.ports {
+		up := 1.0
+		if err := checkClientPort(ctx, net.JoinHostPort(host, strconv.Itoa(port.port)), config); err != nil {
+			slog.Debug("Client port is down", "target", target.Name, "port_type", port.name, "port", port.port, "err", err)
+			up = 0
+		}
+		ch <- prometheus.MustNewConstMetric(e.clientPortUp, prometheus.GaugeValue, up, target.Name, port.name)
+	}
+	return ctx.Err()
+}
+
+// checkClientPort connects to the Thrift port at address and pings it
+func checkClientPort(ctx context.Context, address string, config clientPortsConfig) error {
+	if config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.timeout)
+		defer cancel()
+	}
+	conn, err := dialHS2(ctx, address, config.auth)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.ping()
+}
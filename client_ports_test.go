@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseClientPorts(t *testing.T) {
+	got, err := parseClientPorts("hs2=21050, beeswax=21000")
+	want := []clientPort{{"hs2", 21050}, {"beeswax", 21000}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseClientPorts() = %v, %v, want %v", got, err, want)
+	}
+	for _, s := range []string{"", "hs2", "hs2=", "=21050", "hs2=port", "hs2=0", "hs2=65536"} {
+		if _, err := parseClientPorts(s); err == nil {
+			t.Errorf("parseClientPorts(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCheckClientPort(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		auth    hs2Auth
+		wantErr bool
+	}{
+		{"up", (&fakeHS2{}).start(t), hs2Auth{}, false},
+		{"up with sasl", (&fakeHS2{sasl: true, password: "pw"}).start(t), hs2Auth{mechanism: "PLAIN", user: "canary", password: "pw"}, false},
+		{"bad password", (&fakeHS2{sasl: true, password: "pw"}).start(t), hs2Auth{mechanism: "PLAIN", user: "canary", password: "wrong"}, true},
+		{"closed", closedAddress, hs2Auth{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientPort(context.Background(), tt.address, clientPortsConfig{timeout: 5 * time.Second, auth: tt.auth})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkClientPort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "sync"
+
+// clientQueryCounter turns the per-client total_queries reported by Impala, which restarts from zero with the
+// daemon, into counters that only ever increase while the exporter runs
+type clientQueryCounter struct {
+	mu sync.Mutex
+	// last holds the raw value and the counter of each client per server as of the previous scrape
+	last map[string]map[string]clientQueries
+}
+
+// clientQueries is the state of the query counter of a client
+type clientQueries struct {
+	raw   float64
+	total float64
+}
+
+// observe records the total_queries of every client of a server and returns the counter of each.
+// A raw value below the previous one means the daemon restarted, so the new value is added as is.
+// Clients no longer listed are forgotten; a client seen for the first time starts at its raw value.
+func (c *clientQueryCounter) observe(server string, hosts []ImpalaClientHost) map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		c.last = make(map[string]map[string]clientQueries)
+	}
+
+	previous := c.last[server]
+	current := make(map[string]clientQueries, len(hosts))
+	totals := make(map[string]float64, len(hosts))
+	for _, host := range hosts {
+		raw := float64(host.TotalQueries)
+		state := clientQueries{raw: raw, total: raw}
+		if p, ok := previous[host.Hostname]; ok {
+			if raw >= p.raw {
+				state.total = p.total + raw - p.raw
+			} else {
+				state.total = p.total + raw
+			}
+		}
+		current[host.Hostname] = state
+		totals[host.Hostname] = state.total
+	}
+	c.last[server] = current
+	return totals
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientQueryCounter(t *testing.T) {
+	var c clientQueryCounter
+	scrapes := []struct {
+		hosts []ImpalaClientHost
+		want  map[string]float64
+	}{
+		{[]ImpalaClientHost{{Hostname: "a", TotalQueries: 10}, {Hostname: "b", TotalQueries: 3}}, map[string]float64{"a": 10, "b": 3}},
+		{[]ImpalaClientHost{{Hostname: "a", TotalQueries: 15}, {Hostname: "b", TotalQueries: 3}}, map[string]float64{"a": 15, "b": 3}},
+		// The daemon restarted: a starts over from 2, b is gone
+		{[]ImpalaClientHost{{Hostname: "a", TotalQueries: 2}}, map[string]float64{"a": 17}},
+		{[]ImpalaClientHost{{Hostname: "a", TotalQueries: 4}, {Hostname: "b", TotalQueries: 1}}, map[string]float64{"a": 19, "b": 1}},
+	}
+	for i, s := range scrapes {
+		if got := c.observe("coord", s.hosts); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("scrape %d: observe() = %v, want %v", i, got, s.want)
+		}
+	}
+	if got := c.observe("other", []ImpalaClientHost{{Hostname: "a", TotalQueries: 1}}); got["a"] != 1 {
+		t.Errorf("counters of another server share state: %v", got)
+	}
+}
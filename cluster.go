@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var clusterNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Cluster is a named group of Impala servers served on its own metrics path
+type Cluster struct {
+	Name    string
+	Servers []string
+}
+
+// clusterFlags collects repeated -cluster name=server1,server2 flags
+type clusterFlags []Cluster
+
+func (c *clusterFlags) String() string {
+	parts := make([]string, 0, len(*c))
+	for _, cluster := range *c {
+		parts = append(parts, cluster.Name+"="+strings.Join(cluster.Servers, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *clusterFlags) Set(value string) error {
+	name, servers, ok := strings.Cut(value, "=")
+	if !ok || servers == "" {
+		return fmt.Errorf("expected name=server1,server2, got %q", value)
+	}
+	if !clusterNameRe.MatchString(name) {
+		return fmt.Errorf("invalid cluster name %q", name)
+	}
+	for _, cluster := range *c {
+		if cluster.Name == name {
+			return fmt.Errorf("duplicate cluster %q", name)
+		}
+	}
+	list := strings.Split(servers, ",")
+	for _, server := range list {
+		if strings.TrimSpace(server) == "" {
+			return fmt.Errorf("empty server address in cluster %q", name)
+		}
+	}
+	*c = append(*c, Cluster{Name: name, Servers: dedupeServers(list)})
+	return nil
+}
+
+// clusterHandler returns a metrics handler scraping only the servers of the given cluster, along with its Exporter.
+// Every cluster has its own registry, so scrape accounting is tracked independently per path.
+func clusterHandler(cluster Cluster, options ExporterOptions, labels map[string]string) (http.Handler, *Exporter) {
+	reg := prometheus.NewRegistry()
+	exporter := NewExporter(cluster.Servers, options)
+	exporter.SetClusters([]Cluster{cluster})
+	return metricsHandler(reg, reg, exporter, labels), exporter
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// clusterTotals are the aggregates of the servers of a cluster
+type clusterTotals struct {
+	inFlight, connections float64
+	// backends is the largest membership seen by a server of the cluster, -1 when none reported it
+	backends float64
+}
+
+// clusterAggregates accumulates the metrics of the servers scraped in a Collect into per-cluster totals
+type clusterAggregates struct {
+	e *Exporter
+	// clusterOf is the cluster of every server by name
+	clusterOf map[string]string
+	totals    map[string]*clusterTotals
+}
+
+// newClusterAggregates returns the aggregates of the clusters of targets, every one of them reported even when
+// none of its servers could be scraped
+func (e *Exporter) newClusterAggregates(targets []Target) *clusterAggregates {
+	a := &clusterAggregates{e: e, clusterOf: make(map[string]string, len(targets)), totals: make(map[string]*clusterTotals)}
+	for _, target := range targets {
+		a.clusterOf[target.Name] = target.Cluster
+		if _, ok := a.totals[target.Cluster]; !ok {
+			a.totals[target.Cluster] = &clusterTotals{backends: -1}
+		}
+	}
+	return a
+}
+
+// observe accounts for the metrics of server
+func (a *clusterAggregates) observe(server string, metrics []prometheus.Metric) {
+	totals, ok := a.totals[a.clusterOf[server]]
+	if !ok {
+		return
+	}
+	for _, m := range metrics {
+		meta, ok := a.e.descMeta[m.Desc()]
+		if !ok {
+			continue
+		}
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			continue
+		}
+		a.add(totals, meta.name, metric.GetGauge().GetValue())
+	}
+}
+
+// observeSnapshot accounts for the last complete scrape of server, served in place of an unfinished one
+func (a *clusterAggregates) observeSnapshot(server string, snapshot TargetSnapshot) {
+	totals, ok := a.totals[a.clusterOf[server]]
+	if !ok {
+		return
+	}
+	for _, sm := range snapshot.Metrics {
+		a.add(totals, sm.Name, sm.Value)
+	}
+}
+
+// add accounts for a sample of the metric called name in totals, ignoring those that are not aggregated
+func (a *clusterAggregates) add(totals *clusterTotals, name string, value float64) {
+	switch name {
+	case a.e.descMeta[a.e.inflightQueriesCount].name:
+		totals.inFlight += value
+	case a.e.descMeta[a.e.totalConnections].name:
+		totals.connections += value
+	case a.e.descMeta[a.e.backends].name:
+		totals.backends = max(totals.backends, value)
+	}
+}
+
+// collect sends the aggregates of every cluster over to the provided channel; servers outside any named cluster
+// are aggregated with an empty cluster label
+func (a *clusterAggregates) collect(ch chan<- prometheus.Metric) {
+	e := a.e
+	for cluster, totals := range a.totals {
+		ch <- prometheus.MustNewConstMetric(e.clusterInflight, prometheus.GaugeValue, totals.inFlight, cluster)
+		ch <- prometheus.MustNewConstMetric(e.clusterConnections, prometheus.GaugeValue, totals.connections, cluster)
+		if totals.backends >= 0 {
+			ch <- prometheus.MustNewConstMetric(e.clusterBackends, prometheus.GaugeValue, totals.backends, cluster)
+		}
+	}
+}
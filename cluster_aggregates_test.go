@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClusterAggregates(t *testing.T) {
+	newImpala := func(connections, inFlight, backends int) string {
+		impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sessions":
+				fmt.Fprintf(w, `{"client_hosts": [{"hostname": "ws1", "total_connections": %d}]}`, connections)
+			case "/queries":
+				w.Write([]byte(`{"in_flight_queries": [` + strings.TrimSuffix(strings.Repeat(`{"duration": "1s"},`, inFlight), ",") + `]}`))
+			case "/backends":
+				w.Write([]byte(`{"backends": [` + strings.TrimSuffix(strings.Repeat(`{"address": "b:27000"},`, backends), ",") + `]}`))
+			default:
+				w.Write([]byte(`{}`))
+			}
+		}))
+		t.Cleanup(impala.Close)
+		return strings.TrimPrefix(impala.URL, "http://")
+	}
+	prod1, prod2, dev := newImpala(3, 2, 4), newImpala(5, 1, 3), newImpala(1, 0, 2)
+
+	e := NewExporter([]string{prod1, prod2, dev}, ExporterOptions{
+		ClusterAggregates: true,
+		Collectors:        map[string]bool{"sessions": true, "queries": true, "backends": true},
+	})
+	e.SetClusters([]Cluster{{Name: "prod", Servers: []string{prod1, prod2}}})
+	got := collectValues(t, e, e.Collect)
+	want := map[string]float64{
+		`impala_cluster_inflight_queries{cluster="prod"}`: 3,
+		`impala_cluster_connections{cluster="prod"}`:      8,
+		`impala_cluster_backends{cluster="prod"}`:         4,
+		// Servers outside any named cluster are aggregated together
+		`impala_cluster_inflight_queries{cluster=""}`: 0,
+		`impala_cluster_connections{cluster=""}`:      1,
+		`impala_cluster_backends{cluster=""}`:         2,
+	}
+	for key, value := range want {
+		if v, ok := got[key]; !ok || v != value {
+			t.Errorf("%s = %v (present %t), want %v", key, v, ok, value)
+		}
+	}
+
+	// Without the backends collector the membership is unknown and left out
+	e = NewExporter([]string{prod1}, ExporterOptions{ClusterAggregates: true, Collectors: map[string]bool{"sessions": true}})
+	got = collectValues(t, e, e.Collect)
+	if _, ok := got[`impala_cluster_backends{cluster=""}`]; ok {
+		t.Errorf("impala_cluster_backends exported without the backends collector")
+	}
+	if got := got[`impala_cluster_connections{cluster=""}`]; got != 3 {
+		t.Errorf("impala_cluster_connections = %v, want 3", got)
+	}
+}
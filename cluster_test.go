@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterFlagsSet(t *testing.T) {
+	var clusters clusterFlags
+	if err := clusters.Set("prod=a:25000,b:25000,a:25000"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	if err := clusters.Set("dev-1=c:25000"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	want := clusterFlags{
+		{Name: "prod", Servers: []string{"a:25000", "b:25000"}},
+		{Name: "dev-1", Servers: []string{"c:25000"}},
+	}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Errorf("clusters = %+v, want %+v", clusters, want)
+	}
+}
+
+func TestClusterFlagsSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"duplicate name", "prod=d:25000"},
+		{"bad name", "prod/x=d:25000"},
+		{"empty name", "=d:25000"},
+		{"missing equals", "d:25000"},
+		{"no servers", "staging="},
+		{"empty entry", "staging=x:25000,,y:25000"},
+		{"trailing comma", "staging=x:25000,"},
+		{"blank entry", "staging=x:25000, "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := clusterFlags{{Name: "prod", Servers: []string{"a:25000"}}}
+			if err := clusters.Set(tt.value); err == nil {
+				t.Errorf("Set(%q) succeeded, want error", tt.value)
+			}
+			if len(clusters) != 1 {
+				t.Errorf("Set(%q) added a cluster despite failing: %+v", tt.value, clusters)
+			}
+		})
+	}
+}
+
+func TestDedupeServers(t *testing.T) {
+	got := dedupeServers([]string{"a:25000", "b:25000", " a:25000", "", "c:25000", "b:25000", " "})
+	want := []string{"a:25000", "b:25000", "c:25000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeServers = %q, want %q", got, want)
+	}
+}
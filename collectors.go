@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorDefault is a per-endpoint collector of a server scrape, switched by a -collector.<name> flag
+type collectorDefault struct {
+	name    string
+	enabled bool
+	help    string
+}
+
+// collectorDefaults lists the per-endpoint collectors of a server scrape
+var collectorDefaults = []collectorDefault{
+	{"buildinfo", true, "Export impala_build_info from the root page"},
+	{"rpcz", true, "Export KRPC service metrics from /rpcz"},
+	{"admission", true, "Export admission pool metrics from /admission"},
+	{"metrics", true, "Export client protocol, fragment instance, JVM, TCMalloc, data cache, spill and scratch metrics from the daemon metrics page /metrics"},
+	{"sessions", true, "Export per client and per user session metrics from /sessions"},
+	{"queries", true, "Export in-flight, slow and stuck query metrics from /queries"},
+	{"flags", false, "Export the startup flags of every daemon allowlisted by -flags.allowlist from /varz"},
+	{"logs", false, "Count the messages of each level in the log tail of every daemon served on /logs"},
+	{"memtrackers", false, "Export the consumption of the process memory tracker of every daemon and of its children from /memz"},
+	{"executorgroups", false, "Export the executors and admission slots of each executor group seen by the coordinators on /backends"},
+	{"scratch", false, "Export the scratch space limit of every impalad from the -scratch_dirs flag on /varz"},
+	{"statestore", false, "Export the subscribers of the statestore and the time since their last heartbeat from /subscribers"},
+	{"backends", false, "Export the number of backends in the cluster membership seen by each coordinator on /backends"},
+	{"role", false, "Detect from /varz whether each impalad is a coordinator, an executor or both, exporting impala_daemon_role_info and labeling the metrics of every server with its role"},
+	{"ports", false, "Check that the Thrift client ports of the coordinators (-ports.client-ports) answer requests"},
+	{"canary", false, "Run -canary.query over the HiveServer2 port of the coordinators and export its success and duration"},
+}
+
+var (
+	collectorDuration = prometheus.NewDesc(
+		"impala_exporter_collector_duration_seconds",
+		"Duration of the last run of a collector against an Impala server",
+		[]string{"collector", "impala_server"},
+		nil,
+	)
+	collectorSuccess = prometheus.NewDesc(
+		"impala_exporter_collector_success",
+		"Whether the last run of a collector against an Impala server succeeded",
+		[]string{"collector", "impala_server"},
+		nil,
+	)
+)
+
+// daemonRoles are the roles a collector can serve, as told apart by daemonRole
+var daemonRoles = []string{"impalad", "statestored", "catalogd"}
+
+// targetCollector scrapes one page of the Impala web UI of a server
+type targetCollector interface {
+	// Describe sends the descriptors of the metrics of the collector over to the provided channel
+	Describe(ch chan<- *prometheus.Desc)
+	// Collect fetches the page of target and sends its metrics over to the provided channel
+	Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error
+}
+
+// collectorEntry is a collector along with the servers it is run against
+type collectorEntry struct {
+	// name is the collector name of collectorDefaults
+	name      string
+	endpoint  string
+	collector targetCollector
+	// roles are the daemon roles serving the page of the collector
+	roles []string
+	// required are the roles whose scrape is only complete when the collector succeeded
+	required []string
+}
+
+// newCollectors returns the collectors of e in scrape order
+func newCollectors(e *Exporter) []collectorEntry {
+	impalad := []string{"impalad"}
+	return []collectorEntry{
+		{name: "buildinfo", endpoint: "/?json", collector: buildInfoCollector{e}, roles: daemonRoles},
+		{name: "rpcz", endpoint: "/rpcz?json", collector: rpczCollector{e}, roles: impalad},
+		{name: "admission", endpoint: "/admission?json", collector: admissionCollector{e}, roles: impalad},
+		// The statestore and catalog daemons serve none of the impalad pages besides their metrics
+		{name: "metrics", endpoint: "/metrics?json", collector: daemonMetricsCollector{e}, roles: daemonRoles, required: []string{"statestored", "catalogd"}},
+		{name: "sessions", endpoint: "/sessions?json", collector: sessionsCollector{e}, roles: impalad, required: impalad},
+		{name: "queries", endpoint: "/queries?json", collector: queriesCollector{e}, roles: impalad, required: impalad},
+		{name: "flags", endpoint: "/varz?json", collector: flagInfoCollector{e}, roles: daemonRoles},
+		{name: "logs", endpoint: "/logs?json", collector: logMessagesCollector{e}, roles: daemonRoles},
+		{name: "memtrackers", endpoint: "/memz?json", collector: memTrackersCollector{e}, roles: daemonRoles},
+		{name: "executorgroups", endpoint: "/backends?json", collector: executorGroupsCollector{e}, roles: impalad},
+		{name: "scratch", endpoint: "/varz?json", collector: scratchLimitCollector{e}, roles: impalad},
+		{name: "statestore", endpoint: "/subscribers?json", collector: statestoreCollector{e}, roles: []string{"statestored"}},
+		{name: "backends", endpoint: "/backends?json", collector: backendsCollector{e}, roles: impalad},
+		{name: "role", endpoint: "/varz?json", collector: roleCollector{e}, roles: impalad},
+		{name: "ports", endpoint: "client-ports", collector: clientPortsCollector{e}, roles: impalad},
+		{name: "canary", endpoint: "hs2", collector: canaryCollector{e}, roles: impalad},
+	}
+}
+
+// daemonRole returns the daemon role of target, taking servers of an unknown role for impalads
+func daemonRole(target Target) string {
+	if target.Role == "statestored" || target.Role == "catalogd" {
+		return target.Role
+	}
+	return "impalad"
+}
+
+var (
+	collectorFlags               = make(map[string]*bool)
+	collectorDisableDefaultsFlag = flag.Bool("collector.disable-defaults", false, "Disable every collector that is not explicitly enabled with its -collector.<name> flag")
+)
+
+func init() {
+	for _, c := range collectorDefaults {
+		state := "enabled"
+		if !c.enabled {
+			state = "disabled"
+		}
+		collectorFlags[c.name] = flag.Bool("collector."+c.name, c.enabled, c.help+" ("+state+" by default)")
+	}
+}
+
+// enabledCollectors returns the state of every collector as set by the command line flags, which must have been
+// parsed. With -collector.disable-defaults only the collectors whose flag was given explicitly keep their state.
+func enabledCollectors() map[string]bool {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		if name, ok := strings.CutPrefix(f.Name, "collector."); ok {
+			explicit[name] = true
+		}
+	})
+	enabled := make(map[string]bool, len(collectorFlags))
+	for name, on := range collectorFlags {
+		enabled[name] = *on && (!*collectorDisableDefaultsFlag || explicit[name])
+	}
+	return enabled
+}
+
+// collectorEnabled reports whether the named collector runs; the collectors enabled by default run when none were
+// configured
+func (e *Exporter) collectorEnabled(name string) bool {
+	if e.options.Collectors == nil {
+		i := slices.IndexFunc(collectorDefaults, func(c collectorDefault) bool { return c.name == name })
+		return i >= 0 && collectorDefaults[i].enabled
+	}
+	return e.options.Collectors[name]
+}
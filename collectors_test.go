@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectTargetSkipsDisabledCollectors(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{Collectors: map[string]bool{"queries": true, "sessions": false}})
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	complete := e.collectTarget(context.Background(), ch, newTarget(strings.TrimPrefix(impala.URL, "http://"), ""))
+	close(ch)
+	if !complete {
+		t.Errorf("collectTarget() = false, want a complete scrape")
+	}
+	if !slices.Equal(paths, []string{"/queries"}) {
+		t.Errorf("requested %q, want only /queries", paths)
+	}
+}
+
+func TestCollectorsMatchFlags(t *testing.T) {
+	var names []string
+	for _, c := range newCollectors(NewExporter(nil, ExporterOptions{})) {
+		names = append(names, c.name)
+	}
+	var flags []string
+	for _, c := range collectorDefaults {
+		flags = append(flags, c.name)
+	}
+	if !slices.Equal(names, flags) {
+		t.Errorf("collectors %q, want those of collectorDefaults %q", names, flags)
+	}
+}
+
+func TestCollectTargetReportsCollectorSuccess(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rpcz" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{})
+	if err := prometheus.NewPedanticRegistry().Register(e); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	var complete bool
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		complete = e.collectTarget(context.Background(), ch, target)
+	})
+	if !complete {
+		t.Errorf("collectTarget() = false, want a complete scrape despite the failed rpcz collector")
+	}
+	for _, c := range collectorDefaults {
+		// Collectors disabled by default do not run without explicit configuration
+		if !c.enabled {
+			if _, ok := got[`impala_exporter_collector_success{collector="`+c.name+`"}`]; ok {
+				t.Errorf("collector %s disabled by default ran", c.name)
+			}
+			continue
+		}
+		want := 1.0
+		if c.name == "rpcz" {
+			want = 0
+		}
+		key := `impala_exporter_collector_success{collector="` + c.name + `"}`
+		if value, ok := got[key]; !ok || value != want {
+			t.Errorf("%s = %v (present %v), want %v", key, value, ok, want)
+		}
+	}
+}
+
+func TestCollectTargetDaemonRunsOnlyItsCollectors(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	target.Role = "catalogd"
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	complete := e.collectTarget(context.Background(), ch, target)
+	close(ch)
+	if complete {
+		t.Errorf("collectTarget() = true, want an incomplete scrape when the daemon metrics failed")
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"/", "/metrics"}) {
+		t.Errorf("requested %q, want only / and /metrics", paths)
+	}
+}
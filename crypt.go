@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// encryptedMagic prefixes every file encrypted at rest, telling it apart from a plaintext file
+var encryptedMagic = []byte("IMPXENC1")
+
+// atRestCipher encrypts files written to disk (state, recordings, profiles) with AES-256-GCM.
+// A nil *atRestCipher leaves data in plaintext.
+type atRestCipher struct {
+	aead cipher.AEAD
+	// allowPlaintext accepts unencrypted files, to migrate files written before encryption was enabled
+	allowPlaintext bool
+}
+
+// loadAtRestCipher reads a 256-bit key from a file, given either as 32 raw bytes or hex/base64 encoded
+func loadAtRestCipher(path string) (*atRestCipher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newAtRestCipher(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// newAtRestCipher creates a cipher from a key as accepted by parseKey
+func newAtRestCipher(data []byte) (*atRestCipher, error) {
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &atRestCipher{aead: aead}, nil
+}
+
+// parseKey decodes a 32-byte key from raw, hex or base64 file contents
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("expected a 256-bit key as 32 raw bytes, 64 hex characters or base64")
+}
+
+// Seal encrypts plaintext, returning it unchanged when encryption is disabled
+func (c *atRestCipher) Seal(plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(bytes.Clone(encryptedMagic), nonce...)
+	return c.aead.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// Open decrypts data written by Seal. Once a key is configured, unencrypted data is rejected, since anyone able
+// to write the file could otherwise replace it; it is only returned as is, with a warning, while allowPlaintext is set.
+func (c *atRestCipher) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		if c == nil {
+			return data, nil
+		}
+		if !c.allowPlaintext {
+			return nil, errors.New("file is not encrypted although an encryption key is configured; start once with -state.allow-plaintext to encrypt it")
+		}
+		slog.Warn("Reading an unencrypted file although an encryption key is configured, it is encrypted when written")
+		return data, nil
+	}
+	if c == nil {
+		return nil, errors.New("file is encrypted but no encryption key is configured")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting file: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, key string) *atRestCipher {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadAtRestCipher(path)
+	if err != nil {
+		t.Fatalf("loadAtRestCipher: %v", err)
+	}
+	return c
+}
+
+func TestAtRestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, strings.Repeat("ab", 32)+"\n")
+	plaintext := []byte(`{"targets":["10.11.18.16:25000"]}`)
+
+	sealed, err := c.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("10.11.18.16")) {
+		t.Fatal("sealed data contains the plaintext")
+	}
+	opened, err := c.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, want %q", opened, plaintext)
+	}
+
+	other := newTestCipher(t, strings.Repeat("cd", 32))
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Open with the wrong key succeeded")
+	}
+	var disabled *atRestCipher
+	if _, err := disabled.Open(sealed); err == nil {
+		t.Error("Open without a key succeeded on encrypted data")
+	}
+}
+
+func TestAtRestCipherPlaintextPassthrough(t *testing.T) {
+	plaintext := []byte(`{"targets":[]}`)
+	var disabled *atRestCipher
+	sealed, err := disabled.Seal(plaintext)
+	if err != nil || !bytes.Equal(sealed, plaintext) {
+		t.Fatalf("Seal without a key = %q, %v", sealed, err)
+	}
+	opened, err := disabled.Open(plaintext)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open of plaintext without a key = %q, %v", opened, err)
+	}
+
+	c := newTestCipher(t, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if _, err := c.Open(plaintext); err == nil {
+		t.Error("Open of plaintext with a key succeeded")
+	}
+	c.allowPlaintext = true
+	opened, err = c.Open(plaintext)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open of plaintext while migrating = %q, %v", opened, err)
+	}
+}
+
+func TestParseKeyRejectsShortKeys(t *testing.T) {
+	for _, key := range []string{"", "short", strings.Repeat("ab", 16) + "\n"} {
+		if _, err := parseKey([]byte(key)); err == nil {
+			t.Errorf("parseKey(%q) succeeded", key)
+		}
+	}
+}
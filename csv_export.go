@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// scrapedData is the session and query data of the last scrape of a server, kept for /export/csv
+type scrapedData struct {
+	SessionsTime time.Time
+	Sessions     []ImpalaSession
+	QueriesTime  time.Time
+	InFlight     []InFlightQuery
+	Completed    []CompletedQuery
+}
+
+// scrapedDataStore holds the scraped data of every server, by name
+type scrapedDataStore struct {
+	mu      sync.Mutex
+	servers map[string]*scrapedData
+}
+
+// update applies set to the scraped data of server
+func (s *scrapedDataStore) update(server string, set func(*scrapedData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.servers == nil {
+		s.servers = make(map[string]*scrapedData)
+	}
+	data, ok := s.servers[server]
+	if !ok {
+		data = &scrapedData{}
+		s.servers[server] = data
+	}
+	set(data)
+}
+
+// get returns a copy of the scraped data of server, reporting false when none was kept
+func (s *scrapedDataStore) get(server string) (scrapedData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.servers[server]
+	if !ok {
+		return scrapedData{}, false
+	}
+	return *data, true
+}
+
+// csvExportHeaders are the columns of the CSV documents served by /export/csv, by the data they hold
+var csvExportHeaders = map[string][]string{
+	"sessions": {"impala_server", "scraped_at", "user", "network_address", "start_time", "last_accessed", "expired", "closed"},
+	"queries":  {"impala_server", "scraped_at", "status", "query_id", "user", "resource_pool", "state", "duration", "progress", "statement"},
+}
+
+// csvExportHandler serves the sessions or queries, with ?data=sessions or ?data=queries, kept from the last scrape
+// of a server given by ?target=, or of every server, as CSV. The statements are captured like by the slow query
+// log, see captureStatement.
+func csvExportHandler(exporter *Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		kind := query.Get("data")
+		if kind == "" {
+			kind = "queries"
+		}
+		header, ok := csvExportHeaders[kind]
+		if !ok {
+			http.Error(w, fmt.Sprintf("invalid data %q, expected sessions or queries", kind), http.StatusBadRequest)
+			return
+		}
+		name := query.Get("target")
+		var servers []string
+		for _, target := range exporter.Targets() {
+			if name == "" || target.Name == name {
+				servers = append(servers, target.Name)
+			}
+		}
+		if len(servers) == 0 {
+			http.Error(w, "no matching target", http.StatusNotFound)
+			return
+		}
+		slices.Sort(servers)
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "impala_"+kind+".csv"))
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, server := range servers {
+			data, ok := exporter.scrapedData.get(server)
+			if !ok {
+				continue
+			}
+			for _, record := range data.records(server, kind) {
+				cw.Write(record)
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			slog.Error("Error writing CSV export", "err", err)
+		}
+	})
+}
+
+// records returns the CSV records of the sessions or queries of the scraped data of server
+func (d scrapedData) records(server, kind string) [][]string {
+	var records [][]string
+	if kind == "sessions" {
+		scraped := d.SessionsTime.UTC().Format(time.RFC3339)
+		for _, s := range d.Sessions {
+			records = append(records, []string{server, scraped, s.User, s.NetworkAddress, s.StartTime, s.LastAccessed,
+				strconv.FormatBool(s.Expired), strconv.FormatBool(s.Closed)})
+		}
+		return records
+	}
+	scraped := d.QueriesTime.UTC().Format(time.RFC3339)
+	for _, q := range d.InFlight {
+		records = append(records, []string{server, scraped, "in_flight", q.QueryID, q.EffectiveUser, q.ResourcePool, q.State,
+			q.Duration, q.Progress, q.Stmt})
+	}
+	for _, q := range d.Completed {
+		records = append(records, []string{server, scraped, "completed", q.QueryID, q.EffectiveUser, q.ResourcePool, q.State,
+			q.Duration, "", q.Stmt})
+	}
+	return records
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCSVExport(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sessions":
+			w.Write([]byte(`{"sessions": [{"user": "etl", "network_address": "10.0.0.1:4242", "start_time": "2024-01-01 10:00:00", "last_accessed": "2024-01-01 10:05:00"}]}`))
+		case "/queries":
+			w.Write([]byte(`{
+				"in_flight_queries": [{"query_id": "a:1", "effective_user": "etl", "resource_pool": "root.etl", "state": "RUNNING", "stmt": "SELECT * FROM t WHERE name = 'bob'", "duration": "1m2s", "progress": "3 / 10 ( 30%)"}],
+				"completed_queries": [{"query_id": "b:2", "effective_user": "bi", "resource_pool": "root.bi", "state": "FINISHED", "stmt": "SELECT 1", "duration": "2s"}]
+			}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer impala.Close()
+	address := strings.TrimPrefix(impala.URL, "http://")
+
+	e := NewExporter([]string{"coord=" + address}, ExporterOptions{
+		Collectors:    map[string]bool{"sessions": true, "queries": true},
+		CSVExport:     true,
+		ScrubLiterals: true,
+	})
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	e.collectTarget(context.Background(), ch, newTarget("coord="+address, ""))
+	close(ch)
+
+	get := func(query string) (int, [][]string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		csvExportHandler(e).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export/csv"+query, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		return rec.Code, records
+	}
+
+	_, queries := get("?target=coord")
+	if len(queries) != 3 || strings.Join(queries[0], ",") != strings.Join(csvExportHeaders["queries"], ",") {
+		t.Fatalf("queries = %q, want the header and 2 queries", queries)
+	}
+	if got := queries[1]; got[0] != "coord" || got[2] != "in_flight" || got[3] != "a:1" || got[8] != "3 / 10 ( 30%)" || got[9] != "SELECT * FROM t WHERE name = ?" {
+		t.Errorf("in-flight query = %q", got)
+	}
+	if got := queries[2]; got[2] != "completed" || got[3] != "b:2" || got[4] != "bi" {
+		t.Errorf("completed query = %q", got)
+	}
+
+	_, sessions := get("?data=sessions")
+	if len(sessions) != 2 || sessions[1][2] != "etl" || sessions[1][3] != "10.0.0.1:4242" || sessions[1][6] != "false" {
+		t.Errorf("sessions = %q, want the header and the etl session", sessions)
+	}
+
+	if code, _ := get("?target=unknown"); code != http.StatusNotFound {
+		t.Errorf("GET of an unknown target = %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := get("?data=pools"); code != http.StatusBadRequest {
+		t.Errorf("GET of unknown data = %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DaemonMetric represents a single metric as rendered by Impala's /metrics?json page.
+// Value is a number for counters and gauges, but may be a string or an object for other kinds.
+type DaemonMetric struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MetricGroup represents a group of daemon metrics and its nested groups
+type MetricGroup struct {
+	Name        string         `json:"name"`
+	Metrics     []DaemonMetric `json:"metrics"`
+	ChildGroups []MetricGroup  `json:"child_groups"`
+}
+
+// DaemonMetricsResponse represents the structure of the JSON response from Impala for /metrics
+type DaemonMetricsResponse struct {
+	MetricGroup MetricGroup `json:"metric_group"`
+}
+
+// flattenMetrics returns the numeric metrics of a group and all its nested groups by name
+func flattenMetrics(group MetricGroup) map[string]float64 {
+	values := make(map[string]float64)
+	var walk func(g MetricGroup)
+	walk = func(g MetricGroup) {
+		for _, m := range g.Metrics {
+			var v float64
+			if err := json.Unmarshal(m.Value, &v); err == nil {
+				values[m.Name] = v
+			}
+		}
+		for _, child := range g.ChildGroups {
+			walk(child)
+		}
+	}
+	walk(group)
+	return values
+}
+
+// clientServerMetricRe matches the metrics of the client-facing Thrift servers,
+// e.g. impala.thrift-server.hiveserver2-http-frontend.total-kerberos-auth-failure
+var clientServerMetricRe = regexp.MustCompile(`^impala\.thrift-server\.([a-z0-9-]+)-frontend\.(.+)$`)
+
+// authFailureRe matches the per mechanism authentication failure counters of a Thrift server,
+// e.g. total-kerberos-auth-failure or total-jwt-token-auth-failure
+var authFailureRe = regexp.MustCompile(`^total-([a-z0-9-]+)-auth-failure$`)
+
+// daemonMetricsCollector exports metrics of the daemon metrics page /metrics, served by every daemon role
+type daemonMetricsCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the daemon metrics over to the provided channel
+func (c daemonMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.clientConnections
+	ch <- c.e.clientConnectionSetupTimeouts
+	ch <- c.e.clientAuthFailures
+	ch <- c.e.fragmentInstancesRunning
+	ch <- c.e.fragmentInstances
+	ch <- c.e.jvmHeapUsed
+	ch <- c.e.jvmHeapCommitted
+	ch <- c.e.jvmHeapMax
+	ch <- c.e.jvmGCCollections
+	ch <- c.e.jvmGCSeconds
+	ch <- c.e.tcmallocInUse
+	ch <- c.e.tcmallocPageheapFree
+	ch <- c.e.tcmallocPageheapUnmapped
+	ch <- c.e.tcmallocPhysical
+	ch <- c.e.dataCacheHitBytes
+	ch <- c.e.dataCacheMissBytes
+	ch <- c.e.dataCacheUsed
+	ch <- c.e.spilledBytes
+	ch <- c.e.scratchUsed
+}
+
+// Collect fetches the daemon metrics of a server and sends the ones exported by the exporter over to the provided channel
+func (c daemonMetricsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	var resp DaemonMetricsResponse
+	if err := fetchJSON(ctx, target.Address, "/metrics?json", &resp); err != nil {
+		return err
+	}
+	values := flattenMetrics(resp.MetricGroup)
+	c.e.collectClientProtocolMetrics(ch, target.Name, values)
+	c.e.collectFragmentMetrics(ch, target.Name, values)
+	c.e.collectJVMMetrics(ch, target.Name, values)
+	c.e.collectTCMallocMetrics(ch, target.Name, values)
+	c.e.collectDataCacheMetrics(ch, target.Name, values)
+	c.e.collectSpillMetrics(ch, target.Name, values)
+	c.e.collectScratchMetrics(ch, target.Name, values)
+	return nil
+}
+
+// collectClientProtocolMetrics sends the connection and authentication counters of the client-facing
+// Thrift servers (beeswax, hiveserver2, hiveserver2-http, ...) over to the provided channel, labeled by protocol
+func (e *Exporter) collectClientProtocolMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	for name, value := range values {
+		matches := clientServerMetricRe.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+		protocol, metric := matches[1], matches[2]
+		switch {
+		case metric == "total-connections":
+			ch <- prometheus.MustNewConstMetric(e.clientConnections, prometheus.CounterValue, value, server, protocol)
+		case metric == "timedout-cnxn-requests":
+			ch <- prometheus.MustNewConstMetric(e.clientConnectionSetupTimeouts, prometheus.CounterValue, value, server, protocol)
+		default:
+			if m := authFailureRe.FindStringSubmatch(metric); m != nil {
+				ch <- prometheus.MustNewConstMetric(e.clientAuthFailures, prometheus.CounterValue, value, server, protocol, m[1])
+			}
+		}
+	}
+}
+
+// collectFragmentMetrics sends the fragment instance metrics of an impalad over to the provided channel. Every
+// impalad executing queries reports them, so with the executors scraped too, e.g. through backends discovery, they
+// show how the execution is spread across the cluster.
+func (e *Exporter) collectFragmentMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	if value, ok := values["impala-server.num-fragments-in-flight"]; ok {
+		ch <- prometheus.MustNewConstMetric(e.fragmentInstancesRunning, prometheus.GaugeValue, value, server)
+	}
+	if value, ok := values["impala-server.num-fragments"]; ok {
+		ch <- prometheus.MustNewConstMetric(e.fragmentInstances, prometheus.CounterValue, value, server)
+	}
+}
+
+// collectJVMMetrics sends the heap and garbage collection metrics of the JVM embedded in impalad, running the
+// frontend, and in catalogd over to the provided channel, so that the JVM running out of heap can be told apart
+// from the daemon running out of memory. The statestore runs no JVM and reports none of them.
+func (e *Exporter) collectJVMMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	for _, m := range []struct {
+		name      string
+		desc      *prometheus.Desc
+		valueType prometheus.ValueType
+		scale     float64
+	}{
+		{"jvm.heap.current-usage-bytes", e.jvmHeapUsed, prometheus.GaugeValue, 1},
+		{"jvm.heap.committed-usage-bytes", e.jvmHeapCommitted, prometheus.GaugeValue, 1},
+		{"jvm.heap.max-usage-bytes", e.jvmHeapMax, prometheus.GaugeValue, 1},
+		{"jvm.gc_count", e.jvmGCCollections, prometheus.CounterValue, 1},
+		{"jvm.gc_time_millis", e.jvmGCSeconds, prometheus.CounterValue, 1e-3},
+	} {
+		if value, ok := values[m.name]; ok {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, value*m.scale, server)
+		}
+	}
+}
+
+// collectTCMallocMetrics sends the TCMalloc allocator metrics of a daemon over to the provided channel. Memory freed
+// by the daemon is kept by the allocator in its page heap until released to the OS, as unmapped bytes; comparing the
+// bytes in use with the physical bytes tells memory pressure from allocator retention.
+func (e *Exporter) collectTCMallocMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	for _, m := range []struct {
+		name string
+		desc *prometheus.Desc
+	}{
+		{"tcmalloc.bytes-in-use", e.tcmallocInUse},
+		{"tcmalloc.pageheap-free-bytes", e.tcmallocPageheapFree},
+		{"tcmalloc.pageheap-unmapped-bytes", e.tcmallocPageheapUnmapped},
+		{"tcmalloc.physical-bytes-reserved", e.tcmallocPhysical},
+	} {
+		if value, ok := values[m.name]; ok {
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, value, server)
+		}
+	}
+}
+
+// collectDataCacheMetrics sends the remote data cache metrics of an impalad started with -data_cache over to the
+// provided channel: the bytes read from the cache and those missing from it, and how much of it is used
+func (e *Exporter) collectDataCacheMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	for _, m := range []struct {
+		name      string
+		desc      *prometheus.Desc
+		valueType prometheus.ValueType
+	}{
+		{"impala-server.io-mgr.remote-data-cache-hit-bytes", e.dataCacheHitBytes, prometheus.CounterValue},
+		{"impala-server.io-mgr.remote-data-cache-miss-bytes", e.dataCacheMissBytes, prometheus.CounterValue},
+		{"impala-server.io-mgr.remote-data-cache-total-bytes", e.dataCacheUsed, prometheus.GaugeValue},
+	} {
+		if value, ok := values[m.name]; ok {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, value, server)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const daemonMetricsJSON = `{
+  "metric_group": {
+    "name": "impala",
+    "metrics": [
+      {"name": "impala.thrift-server.beeswax-frontend.total-connections", "value": 12, "kind": "COUNTER"},
+      {"name": "impala-server.version", "value": "impalad version 4.1.0", "kind": "PROPERTY"}
+    ],
+    "child_groups": [
+      {
+        "name": "thrift-server",
+        "metrics": [
+          {"name": "impala.thrift-server.hiveserver2-http-frontend.total-kerberos-auth-failure", "value": 3, "kind": "COUNTER"},
+          {"name": "impala.thrift-server.hiveserver2-frontend.timedout-cnxn-requests", "value": 1, "kind": "COUNTER"},
+          {"name": "impala.thrift-server.hiveserver2-frontend.connection-setup-time", "value": {"count": 4}, "kind": "HISTOGRAM"}
+        ],
+        "child_groups": []
+      }
+    ]
+  }
+}`
+
+func TestFlattenMetrics(t *testing.T) {
+	var resp DaemonMetricsResponse
+	if err := json.Unmarshal([]byte(daemonMetricsJSON), &resp); err != nil {
+		t.Fatalf("decoding daemon metrics: %v", err)
+	}
+	want := map[string]float64{
+		"impala.thrift-server.beeswax-frontend.total-connections":                    12,
+		"impala.thrift-server.hiveserver2-http-frontend.total-kerberos-auth-failure": 3,
+		"impala.thrift-server.hiveserver2-frontend.timedout-cnxn-requests":           1,
+	}
+	if got := flattenMetrics(resp.MetricGroup); !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenMetrics() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectClientProtocolMetrics(t *testing.T) {
+	values := map[string]float64{
+		"impala.thrift-server.beeswax-frontend.total-connections":                     12,
+		"impala.thrift-server.hiveserver2-frontend.total-connections":                 40,
+		"impala.thrift-server.hiveserver2-frontend.timedout-cnxn-requests":            1,
+		"impala.thrift-server.hiveserver2-http-frontend.total-kerberos-auth-failure":  3,
+		"impala.thrift-server.hiveserver2-http-frontend.total-jwt-token-auth-failure": 2,
+		"impala.thrift-server.hiveserver2-http-frontend.total-kerberos-auth-success":  90,
+		"impala.thrift-server.hiveserver2-frontend.connections-in-use":                5,
+		"impala.thrift-server.backend.total-connections":                              8,
+		"impala-server.num-queries":                                                   100,
+	}
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectClientProtocolMetrics(ch, "coord", values)
+	})
+	want := map[string]float64{
+		`impala_client_connections_total{protocol="beeswax"}`:                                  12,
+		`impala_client_connections_total{protocol="hiveserver2"}`:                              40,
+		`impala_client_connection_setup_timeouts_total{protocol="hiveserver2"}`:                1,
+		`impala_client_auth_failures_total{mechanism="kerberos",protocol="hiveserver2-http"}`:  3,
+		`impala_client_auth_failures_total{mechanism="jwt-token",protocol="hiveserver2-http"}`: 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectClientProtocolMetrics() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectFragmentMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectFragmentMetrics(ch, "executor", map[string]float64{
+			"impala-server.num-fragments-in-flight": 7,
+			"impala-server.num-fragments":           1200,
+			"impala-server.num-queries":             100,
+		})
+	})
+	want := map[string]float64{
+		"impala_fragment_instances_running": 7,
+		"impala_fragment_instances_total":   1200,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectFragmentMetrics() = %v, want %v", got, want)
+	}
+	// The statestore and catalog daemons report neither
+	if got := collectValues(t, e, func(ch chan<- prometheus.Metric) { e.collectFragmentMetrics(ch, "statestored", nil) }); len(got) != 0 {
+		t.Errorf("collectFragmentMetrics() without fragment metrics = %v, want none", got)
+	}
+}
+
+func TestCollectJVMMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectJVMMetrics(ch, "catalogd", map[string]float64{
+			"jvm.heap.current-usage-bytes":     3 << 30,
+			"jvm.heap.committed-usage-bytes":   4 << 30,
+			"jvm.heap.max-usage-bytes":         8 << 30,
+			"jvm.gc_count":                     42,
+			"jvm.gc_time_millis":               1500,
+			"jvm.non-heap.current-usage-bytes": 1 << 28,
+		})
+	})
+	want := map[string]float64{
+		"impala_jvm_heap_used_bytes":           3 << 30,
+		"impala_jvm_heap_committed_bytes":      4 << 30,
+		"impala_jvm_heap_max_bytes":            8 << 30,
+		"impala_jvm_gc_collections_total":      42,
+		"impala_jvm_gc_duration_seconds_total": 1.5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectJVMMetrics() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectTCMallocMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectTCMallocMetrics(ch, "impalad", map[string]float64{
+			"tcmalloc.bytes-in-use":            10 << 30,
+			"tcmalloc.pageheap-free-bytes":     2 << 30,
+			"tcmalloc.pageheap-unmapped-bytes": 5 << 30,
+			"tcmalloc.physical-bytes-reserved": 12 << 30,
+			"tcmalloc.total-bytes-reserved":    17 << 30,
+		})
+	})
+	want := map[string]float64{
+		"impala_tcmalloc_in_use_bytes":            10 << 30,
+		"impala_tcmalloc_pageheap_free_bytes":     2 << 30,
+		"impala_tcmalloc_pageheap_unmapped_bytes": 5 << 30,
+		"impala_tcmalloc_physical_bytes":          12 << 30,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectTCMallocMetrics() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectDataCacheMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectDataCacheMetrics(ch, "executor", map[string]float64{
+			"impala-server.io-mgr.remote-data-cache-hit-bytes":   300 << 20,
+			"impala-server.io-mgr.remote-data-cache-hit-count":   30,
+			"impala-server.io-mgr.remote-data-cache-miss-bytes":  100 << 20,
+			"impala-server.io-mgr.remote-data-cache-total-bytes": 1 << 30,
+		})
+	})
+	want := map[string]float64{
+		"impala_data_cache_hit_bytes_total":  300 << 20,
+		"impala_data_cache_miss_bytes_total": 100 << 20,
+		"impala_data_cache_used_bytes":       1 << 30,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectDataCacheMetrics() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of queries listed by /debug/queries by default and at most
+const (
+	defaultDebugQueries = 20
+	maxDebugQueries     = 1000
+)
+
+// debugQueriesTimeout bounds the fetching of the in-flight queries of the coordinators by /debug/queries
+const debugQueriesTimeout = 10 * time.Second
+
+// debugQuery is an in-flight query listed by /debug/queries
+type debugQuery struct {
+	ImpalaServer    string  `json:"impala_server"`
+	Coordinator     string  `json:"coordinator"`
+	QueryID         string  `json:"query_id"`
+	User            string  `json:"user"`
+	Pool            string  `json:"pool"`
+	State           string  `json:"state"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Statement       string  `json:"statement"`
+}
+
+// debugQueries is the document served by /debug/queries: the longest running queries, longest first, and the
+// coordinators whose queries could not be fetched
+type debugQueries struct {
+	Queries []debugQuery      `json:"queries"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+var debugQueriesTemplate = template.Must(template.New("queries").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Longest running queries</title></head>
+<body>
+<h1>Longest running queries</h1>
+<table border="1">
+<tr><th>Coordinator</th><th>Query ID</th><th>User</th><th>Pool</th><th>State</th><th>Duration (s)</th><th>Statement</th></tr>
+{{- range .Queries}}
+<tr><td>{{.ImpalaServer}}</td><td>{{.QueryID}}</td><td>{{.User}}</td><td>{{.Pool}}</td><td>{{.State}}</td><td>{{printf "%.1f" .DurationSeconds}}</td><td><code>{{.Statement}}</code></td></tr>
+{{- else}}
+<tr><td colspan="7">No query running</td></tr>
+{{- end}}
+</table>
+{{- range $server, $err := .Errors}}
+<p>{{$server}}: {{$err}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// debugQueriesHandler serves the longest running in-flight queries across the coordinators, or of a single one
+// with ?target=<impala_server label>, as JSON or as an HTML table with ?format=html. ?n= sets how many are
+// listed. Statements are captured like by the slow query log, see captureStatement.
+func debugQueriesHandler(exporter *Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := defaultDebugQueries
+		if n := query.Get("n"); n != "" {
+			var err error
+			if limit, err = strconv.Atoi(n); err != nil || limit <= 0 || limit > maxDebugQueries {
+				http.Error(w, fmt.Sprintf("invalid n %q, expected 1 to %d", n, maxDebugQueries), http.StatusBadRequest)
+				return
+			}
+		}
+		name := query.Get("target")
+		var targets []Target
+		for _, target := range exporter.Targets() {
+			if name == "" && (target.Role == "statestored" || target.Role == "catalogd") {
+				continue
+			}
+			if name == "" || target.Name == name {
+				targets = append(targets, target)
+			}
+		}
+		if len(targets) == 0 {
+			http.Error(w, "no matching coordinator", http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), debugQueriesTimeout)
+		defer cancel()
+		doc := exporter.longestRunningQueries(ctx, targets, limit)
+		if query.Get("format") == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := debugQueriesTemplate.Execute(w, doc); err != nil {
+				slog.Error("Error rendering queries page", "err", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			slog.Error("Error writing queries", "err", err)
+		}
+	})
+}
+
+// longestRunningQueries fetches the in-flight queries of the targets concurrently and returns the limit longest
+// running ones
+func (e *Exporter) longestRunningQueries(ctx context.Context, targets []Target, limit int) debugQueries {
+	var mu sync.Mutex
+	doc := debugQueries{Queries: []debugQuery{}}
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			longest := &longestQueries{limit: limit}
+			err := fetchDecode(ctx, target.Address, "/queries?json", func(r io.Reader) error {
+				longest.queries = nil
+				return decodeQueries(r, func(query InFlightQuery) {
+					if seconds, err := ParseDuration(query.Duration); err == nil {
+						longest.add(query, seconds)
+					}
+				}, func(CompletedQuery) {})
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if doc.Errors == nil {
+					doc.Errors = make(map[string]string)
+				}
+				doc.Errors[target.Name] = err.Error()
+				return
+			}
+			longest.truncate()
+			for _, q := range longest.queries {
+				doc.Queries = append(doc.Queries, debugQuery{
+					ImpalaServer:    target.Name,
+					Coordinator:     target.Address,
+					QueryID:         q.QueryID,
+					User:            q.EffectiveUser,
+					Pool:            q.ResourcePool,
+					State:           q.State,
+					DurationSeconds: q.seconds,
+					Statement:       statementSnippet(e.captureStatement(q.Stmt)),
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	slices.SortStableFunc(doc.Queries, func(a, b debugQuery) int {
+		return cmp.Or(cmp.Compare(b.DurationSeconds, a.DurationSeconds), strings.Compare(a.ImpalaServer, b.ImpalaServer))
+	})
+	if len(doc.Queries) > limit {
+		doc.Queries = doc.Queries[:limit]
+	}
+	return doc
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugQueriesHandler(t *testing.T) {
+	newImpala := func(durations ...string) string {
+		impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/queries" {
+				http.NotFound(w, r)
+				return
+			}
+			var queries []string
+			for i, d := range durations {
+				queries = append(queries, fmt.Sprintf(`{"query_id": "%s:%d", "effective_user": "alice", "resource_pool": "root.default", "state": "RUNNING", "duration": "%s", "stmt": "select * from t where id = 42"}`, d, i, d))
+			}
+			fmt.Fprintf(w, `{"in_flight_queries": [%s], "completed_queries": [{"query_id": "0:0", "duration": "1h"}]}`, strings.Join(queries, ","))
+		}))
+		t.Cleanup(impala.Close)
+		return strings.TrimPrefix(impala.URL, "http://")
+	}
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	exporter := NewExporter([]string{
+		"a=" + newImpala("10s", "3m", "1m"),
+		"b=" + newImpala("2m", "5s"),
+		"down=" + strings.TrimPrefix(down.URL, "http://"),
+	}, ExporterOptions{ScrubLiterals: true})
+	handler := debugQueriesHandler(exporter)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	ids := func(path string) string {
+		rec := get(path)
+		var doc debugQueries
+		if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		var ids []string
+		for _, q := range doc.Queries {
+			ids = append(ids, q.ImpalaServer+"/"+q.QueryID)
+			if q.Statement != "select * from t where id = ?" || q.User != "alice" {
+				t.Errorf("%s: unexpected query %+v", path, q)
+			}
+		}
+		if _, ok := doc.Errors["down"]; !ok && !strings.Contains(path, "target=") {
+			t.Errorf("%s: missing error of the unreachable coordinator: %v", path, doc.Errors)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if got, want := ids("/debug/queries"), "a/3m:1,b/2m:0,a/1m:2,a/10s:0,b/5s:1"; got != want {
+		t.Errorf("all queries = %s, want %s", got, want)
+	}
+	if got, want := ids("/debug/queries?n=2"), "a/3m:1,b/2m:0"; got != want {
+		t.Errorf("top 2 queries = %s, want %s", got, want)
+	}
+	if got, want := ids("/debug/queries?target=b"), "b/2m:0,b/5s:1"; got != want {
+		t.Errorf("queries of b = %s, want %s", got, want)
+	}
+	if rec := get("/debug/queries?format=html"); !strings.Contains(rec.Body.String(), "<td>3m:1</td>") {
+		t.Errorf("HTML page lacks the longest query:\n%s", rec.Body)
+	}
+	for path, want := range map[string]int{
+		"/debug/queries?n=0":          http.StatusBadRequest,
+		"/debug/queries?n=x":          http.StatusBadRequest,
+		"/debug/queries?target=other": http.StatusNotFound,
+	} {
+		if rec := get(path); rec.Code != want {
+			t.Errorf("%s: got status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Discoverer produces the current list of Impala servers from an external source
+type Discoverer interface {
+	// Name identifies the discovery backend in logs and metrics
+	Name() string
+	// Discover returns the servers currently known to the backend
+	Discover(ctx context.Context) ([]string, error)
+}
+
+// refreshIntervaler is implemented by discoverers that need a refresh interval other than -discovery.refresh-interval
+type refreshIntervaler interface {
+	RefreshInterval() time.Duration
+}
+
+// roleDiscoverer is implemented by discoverers that know the role of the servers they discover, such as
+// Cloudera Manager; Roles returns the role of each server of the last successful Discover by address
+type roleDiscoverer interface {
+	Roles() map[string]string
+}
+
+// DiscovererFactory creates a discoverer from its command line flags, returning a nil Discoverer when the backend is not enabled
+type DiscovererFactory func() (Discoverer, error)
+
+var (
+	discovererFactoriesMu sync.Mutex
+	discovererFactories   = make(map[string]DiscovererFactory)
+)
+
+// RegisterDiscoverer makes a discovery backend available under the given name.
+// Like RegisterSink, it is meant to be called from an init function that also defines the backend's flags.
+func RegisterDiscoverer(name string, factory DiscovererFactory) {
+	discovererFactoriesMu.Lock()
+	defer discovererFactoriesMu.Unlock()
+	if _, ok := discovererFactories[name]; ok {
+		panic(fmt.Sprintf("discoverer %q registered twice", name))
+	}
+	discovererFactories[name] = factory
+}
+
+// buildDiscoverers creates every registered discovery backend that is enabled by its flags
+func buildDiscoverers() ([]Discoverer, error) {
+	discovererFactoriesMu.Lock()
+	defer discovererFactoriesMu.Unlock()
+
+	names := make([]string, 0, len(discovererFactories))
+	for name := range discovererFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var discoverers []Discoverer
+	for _, name := range names {
+		d, err := discovererFactories[name]()
+		if err != nil {
+			return nil, fmt.Errorf("discovery backend %s: %w", name, err)
+		}
+		if d != nil {
+			discoverers = append(discoverers, d)
+		}
+	}
+	return discoverers, nil
+}
+
+var (
+	discoveryRefreshDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "impala_exporter_discovery_refresh_duration_seconds",
+			Help:    "Duration of discovery refreshes per backend",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"backend"},
+	)
+	discoveryRefreshFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "impala_exporter_discovery_refresh_failures_total",
+			Help: "Number of failed discovery refreshes per backend",
+		},
+		[]string{"backend"},
+	)
+	discoveryLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "impala_exporter_discovery_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful discovery refresh per backend",
+		},
+		[]string{"backend"},
+	)
+	discoveryTargets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "impala_exporter_discovery_targets",
+			Help: "Number of targets currently provided by each discovery backend",
+		},
+		[]string{"backend"},
+	)
+	discoveryTargetsAdded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "impala_exporter_discovery_targets_added_total",
+			Help: "Number of targets added by each discovery backend",
+		},
+		[]string{"backend"},
+	)
+	discoveryTargetsRemoved = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "impala_exporter_discovery_targets_removed_total",
+			Help: "Number of targets removed by each discovery backend",
+		},
+		[]string{"backend"},
+	)
+)
+
+// discoveryCollectors are the metrics describing the discovery subsystem
+var discoveryCollectors = []prometheus.Collector{
+	discoveryRefreshDuration,
+	discoveryRefreshFailures,
+	discoveryLastSuccess,
+	discoveryTargets,
+	discoveryTargetsAdded,
+	discoveryTargetsRemoved,
+}
+
+// discoveryManager periodically refreshes every discovery backend and hands the results to the Exporter
+type discoveryManager struct {
+	exporter *Exporter
+	tasks    []*supervisedTask
+
+	// current holds the servers in effect per backend, kept across restarts of a refresh loop
+	mu      sync.Mutex
+	current map[string][]string
+}
+
+// startDiscovery refreshes each discoverer immediately and then every interval, under supervision.
+// The servers of a backend are registered with the exporter as the source "discovery/<name>".
+func startDiscovery(sup *supervisor, discoverers []Discoverer, exporter *Exporter, interval time.Duration) *discoveryManager {
+	m := &discoveryManager{exporter: exporter, current: make(map[string][]string)}
+	for _, d := range discoverers {
+		interval := interval
+		if r, ok := d.(refreshIntervaler); ok {
+			interval = r.RefreshInterval()
+		}
+		m.tasks = append(m.tasks, sup.Go("discovery/"+d.Name(), staleAfter(interval), func(ctx context.Context, heartbeat func()) {
+			m.run(ctx, d, interval, heartbeat)
+		}))
+	}
+	return m
+}
+
+// Close stops all refresh loops
+func (m *discoveryManager) Close() {
+	for _, t := range m.tasks {
+		t.Stop()
+	}
+}
+
+// run refreshes a single backend until ctx is done
+func (m *discoveryManager) run(ctx context.Context, d Discoverer, interval time.Duration, heartbeat func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.mu.Lock()
+		previous := m.current[d.Name()]
+		m.mu.Unlock()
+		current := m.refresh(ctx, d, previous)
+		m.mu.Lock()
+		m.current[d.Name()] = current
+		m.mu.Unlock()
+		heartbeat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh queries a backend once, returning the servers now in effect for it.
+// On failure the previous servers are kept, so a backend outage does not empty the target list.
+func (m *discoveryManager) refresh(ctx context.Context, d Discoverer, previous []string) []string {
+	name := d.Name()
+	start := time.Now()
+	servers, err := d.Discover(ctx)
+	discoveryRefreshDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Error refreshing discovery backend", "backend", name, "err", err)
+			discoveryRefreshFailures.WithLabelValues(name).Inc()
+		}
+		return previous
+	}
+
+	servers = dedupeServers(servers)
+	added, removed := diffServers(previous, servers)
+	discoveryTargetsAdded.WithLabelValues(name).Add(float64(added))
+	discoveryTargetsRemoved.WithLabelValues(name).Add(float64(removed))
+	discoveryTargets.WithLabelValues(name).Set(float64(len(servers)))
+	discoveryLastSuccess.WithLabelValues(name).SetToCurrentTime()
+
+	m.exporter.SetSourceServers("discovery/"+name, servers)
+	if r, ok := d.(roleDiscoverer); ok {
+		m.exporter.SetSourceRoles("discovery/"+name, r.Roles())
+	}
+	return servers
+}
+
+// diffServers counts the servers present in next but not in previous, and vice versa
+func diffServers(previous, next []string) (added, removed int) {
+	for _, server := range next {
+		if !slices.Contains(previous, server) {
+			added++
+		}
+	}
+	for _, server := range previous {
+		if !slices.Contains(next, server) {
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	backendsCoordinatorsFlag = flag.String("impala.backends.coordinators", "", "Comma-separated coordinator web UI addresses whose /backends page lists the daemons to scrape; enables backends discovery")
+	backendsWebPortFlag      = flag.Int("impala.backends.web-port", 25000, "Web UI port of discovered backends that do not report their web server URL")
+)
+
+func init() {
+	RegisterDiscoverer("backends", func() (Discoverer, error) {
+		if *backendsCoordinatorsFlag == "" {
+			return nil, nil
+		}
+		return &backendsDiscoverer{coordinators: dedupeServers(strings.Split(*backendsCoordinatorsFlag, ",")), webPort: *backendsWebPortFlag}, nil
+	})
+}
+
+// Backend represents a single daemon as rendered by Impala's /backends?json page
+type Backend struct {
+	Address       string `json:"address"`
+	WebserverURL  string `json:"webserver_url"`
+	IsCoordinator bool   `json:"is_coordinator"`
+	IsExecutor    bool   `json:"is_executor"`
+}
+
+// BackendsResponse represents the structure of the JSON response from Impala for /backends
+type BackendsResponse struct {
+	Backends []Backend `json:"backends"`
+}
+
+// backendsDiscoverer expands a coordinator into every backend of its cluster
+type backendsDiscoverer struct {
+	coordinators []string
+	webPort      int
+}
+
+func (d *backendsDiscoverer) Name() string {
+	return "backends"
+}
+
+// Discover returns the web UI address of every backend listed by the first coordinator that answers
+func (d *backendsDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	var errs []error
+	for _, coordinator := range d.coordinators {
+		var resp BackendsResponse
+		if err := fetchJSON(ctx, coordinator, "/backends?json", &resp); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", coordinator, err))
+			continue
+		}
+		servers := make([]string, 0, len(resp.Backends))
+		for _, backend := range resp.Backends {
+			if server, ok := backendWebAddress(backend, d.webPort); ok {
+				servers = append(servers, server)
+			}
+		}
+		return servers, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// backendWebAddress returns the web UI address of a backend, from its web server URL when reported
+// and otherwise from the host of its backend address and the given port
+func backendWebAddress(backend Backend, webPort int) (string, bool) {
+	if backend.WebserverURL != "" {
+		if u, err := url.Parse(backend.WebserverURL); err == nil && u.Host != "" {
+			return u.Host, true
+		}
+	}
+	host, _, err := net.SplitHostPort(backend.Address)
+	if err != nil || host == "" {
+		return "", false
+	}
+	return net.JoinHostPort(host, strconv.Itoa(webPort)), true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBackendWebAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend Backend
+		want    string
+		wantOK  bool
+	}{
+		{"webserver url", Backend{Address: "node1:27000", WebserverURL: "http://node1.example.com:25000"}, "node1.example.com:25000", true},
+		{"backend address", Backend{Address: "node2:27000"}, "node2:25001", true},
+		{"invalid url falls back", Backend{Address: "node3:27000", WebserverURL: "::"}, "node3:25001", true},
+		{"no address", Backend{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := backendWebAddress(tt.backend, 25001)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("backendWebAddress(%+v) = %q, %v, want %q, %v", tt.backend, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBackendsDiscoverer(t *testing.T) {
+	coordinator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"backends": [
+			{"address": "coord:27000", "webserver_url": "http://coord:25000", "is_coordinator": true, "is_executor": false},
+			{"address": "exec-1:27000", "webserver_url": "http://exec-1:25000", "is_coordinator": false, "is_executor": true},
+			{"address": "exec-2:27000", "is_coordinator": false, "is_executor": true}
+		]}`))
+	}))
+	defer coordinator.Close()
+
+	// The first coordinator is unreachable, the second answers
+	d := &backendsDiscoverer{coordinators: []string{"127.0.0.1:1", strings.TrimPrefix(coordinator.URL, "http://")}, webPort: 25000}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	if want := []string{"coord:25000", "exec-1:25000", "exec-2:25000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+
+	d.coordinators = []string{"127.0.0.1:1"}
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() without a reachable coordinator succeeded, want error")
+	}
+}
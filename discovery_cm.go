@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	cmURLFlag          = flag.String("impala.cm.url", "", "Base URL of the Cloudera Manager API (e.g., https://cm.example.com:7183); enables Cloudera Manager discovery")
+	cmAPIVersionFlag   = flag.String("impala.cm.api-version", "v41", "Cloudera Manager API version")
+	cmClusterFlag      = flag.String("impala.cm.cluster", "", "Cloudera Manager cluster whose Impala roles are scraped, all clusters when unset")
+	cmUsernameFlag     = flag.String("impala.cm.username", "", "Cloudera Manager API user")
+	cmPasswordFileFlag = flag.String("impala.cm.password-file", "", "Path of a file holding the password of the Cloudera Manager API user; when unset the password is read from $"+cmPasswordEnv)
+	cmRolesFlag        = flag.String("impala.cm.roles", "IMPALAD", "Comma-separated Impala role types to scrape: IMPALAD, STATESTORE and/or CATALOGSERVER")
+)
+
+// cmRoleWebPorts maps each Impala role type to the configuration holding its web UI port, that port's default
+// and the matching target role
+var cmRoleWebPorts = map[string]struct {
+	config      string
+	defaultPort string
+	role        string
+}{
+	"IMPALAD":       {"impalad_webserver_port", "25000", "impalad"},
+	"STATESTORE":    {"statestore_webserver_port", "25010", "statestored"},
+	"CATALOGSERVER": {"catalogserver_webserver_port", "25020", "catalogd"},
+}
+
+func init() {
+	RegisterDiscoverer("cloudera-manager", func() (Discoverer, error) {
+		if *cmURLFlag == "" {
+			return nil, nil
+		}
+		roles := dedupeServers(strings.Split(*cmRolesFlag, ","))
+		for _, role := range roles {
+			if _, ok := cmRoleWebPorts[role]; !ok {
+				return nil, fmt.Errorf("unknown Impala role type %q", role)
+			}
+		}
+		password, _, err := readSecret(*cmPasswordFileFlag, cmPasswordEnv)
+		if err != nil {
+			return nil, fmt.Errorf("reading password file: %w", err)
+		}
+		return &cmDiscoverer{
+			apiURL:    strings.TrimSuffix(*cmURLFlag, "/") + "/api/" + *cmAPIVersionFlag,
+			cluster:   *cmClusterFlag,
+			roleTypes: roles,
+			username:  *cmUsernameFlag,
+			password:  password,
+			client:    &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	})
+}
+
+// cmList represents the item list wrapping most Cloudera Manager API responses
+type cmList[T any] struct {
+	Items []T `json:"items"`
+}
+
+// cmService represents the parts of a Cloudera Manager service used for discovery
+type cmService struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// cmRole represents the parts of a Cloudera Manager role used for discovery
+type cmRole struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	HostRef struct {
+		HostID string `json:"hostId"`
+	} `json:"hostRef"`
+	RoleConfigGroupRef struct {
+		RoleConfigGroupName string `json:"roleConfigGroupName"`
+	} `json:"roleConfigGroupRef"`
+}
+
+// cmRoleConfigGroup represents a role config group with the settings that differ from the defaults
+type cmRoleConfigGroup struct {
+	Name   string `json:"name"`
+	Config struct {
+		Items []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"items"`
+	} `json:"config"`
+}
+
+// cmHost represents the parts of a Cloudera Manager host used for discovery
+type cmHost struct {
+	HostID   string `json:"hostId"`
+	Hostname string `json:"hostname"`
+}
+
+// cmDiscoverer lists the hosts of Impala roles managed by Cloudera Manager
+type cmDiscoverer struct {
+	apiURL    string
+	cluster   string
+	roleTypes []string
+	username  string
+	password  string
+	client    *http.Client
+
+	// serverRoles holds the role of each server found by the last successful Discover
+	serverRoles map[string]string
+}
+
+func (d *cmDiscoverer) Name() string {
+	return "cloudera-manager"
+}
+
+// Roles returns the role of each server found by the last successful Discover, so that statestore and catalog
+// servers on custom ports are not scraped as impalad
+func (d *cmDiscoverer) Roles() map[string]string {
+	return d.serverRoles
+}
+
+// Discover returns the web UI address of every role of the configured types in the Impala services of the cluster(s).
+// The web UI port is read from the role's config group, falling back to the Impala default.
+func (d *cmDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	var hosts cmList[cmHost]
+	if err := d.get(ctx, "/hosts", &hosts); err != nil {
+		return nil, err
+	}
+	hostnames := make(map[string]string, len(hosts.Items))
+	for _, h := range hosts.Items {
+		hostnames[h.HostID] = h.Hostname
+	}
+
+	clusters := []string{d.cluster}
+	if d.cluster == "" {
+		var list cmList[struct {
+			Name string `json:"name"`
+		}]
+		if err := d.get(ctx, "/clusters", &list); err != nil {
+			return nil, err
+		}
+		clusters = clusters[:0]
+		for _, c := range list.Items {
+			clusters = append(clusters, c.Name)
+		}
+	}
+
+	var servers []string
+	serverRoles := make(map[string]string)
+	for _, cluster := range clusters {
+		clusterPath := "/clusters/" + url.PathEscape(cluster)
+		var services cmList[cmService]
+		if err := d.get(ctx, clusterPath+"/services", &services); err != nil {
+			return nil, err
+		}
+		for _, service := range services.Items {
+			if service.Type != "IMPALA" {
+				continue
+			}
+			servicePath := clusterPath + "/services/" + url.PathEscape(service.Name)
+			var roles cmList[cmRole]
+			if err := d.get(ctx, servicePath+"/roles", &roles); err != nil {
+				return nil, err
+			}
+			var groups cmList[cmRoleConfigGroup]
+			if err := d.get(ctx, servicePath+"/roleConfigGroups", &groups); err != nil {
+				return nil, err
+			}
+
+			for _, role := range roles.Items {
+				if !slices.Contains(d.roleTypes, role.Type) {
+					continue
+				}
+				hostname, ok := hostnames[role.HostRef.HostID]
+				if !ok {
+					continue
+				}
+				address := net.JoinHostPort(hostname, cmWebPort(role, groups.Items))
+				servers = append(servers, address)
+				serverRoles[address] = cmRoleWebPorts[role.Type].role
+			}
+		}
+	}
+	d.serverRoles = serverRoles
+	return servers, nil
+}
+
+// cmWebPort returns the web UI port of a role from its config group, or the default of its role type
+func cmWebPort(role cmRole, groups []cmRoleConfigGroup) string {
+	port := cmRoleWebPorts[role.Type]
+	for _, group := range groups {
+		if group.Name != role.RoleConfigGroupRef.RoleConfigGroupName {
+			continue
+		}
+		for _, item := range group.Config.Items {
+			if item.Name == port.config && item.Value != "" {
+				return item.Value
+			}
+		}
+	}
+	return port.defaultPort
+}
+
+// get requests path from the Cloudera Manager API and decodes the JSON response into v
+func (d *cmDiscoverer) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if d.username != "" {
+		req.SetBasicAuth(d.username, d.password)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting %s: unexpected status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
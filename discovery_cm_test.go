@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCMDiscoverer(t *testing.T) {
+	responses := map[string]string{
+		"/api/v41/hosts":                       `{"items": [{"hostId": "h1", "hostname": "node1.example.com"}, {"hostId": "h2", "hostname": "node2.example.com"}]}`,
+		"/api/v41/clusters":                    `{"items": [{"name": "Cluster 1"}]}`,
+		"/api/v41/clusters/Cluster 1/services": `{"items": [{"name": "hdfs", "type": "HDFS"}, {"name": "impala", "type": "IMPALA"}]}`,
+		"/api/v41/clusters/Cluster 1/services/impala/roles": `{"items": [
+			{"name": "impala-IMPALAD-1", "type": "IMPALAD", "hostRef": {"hostId": "h1"}, "roleConfigGroupRef": {"roleConfigGroupName": "impala-IMPALAD-BASE"}},
+			{"name": "impala-IMPALAD-2", "type": "IMPALAD", "hostRef": {"hostId": "h2"}, "roleConfigGroupRef": {"roleConfigGroupName": "impala-IMPALAD-custom"}},
+			{"name": "impala-STATESTORE-1", "type": "STATESTORE", "hostRef": {"hostId": "h1"}, "roleConfigGroupRef": {"roleConfigGroupName": "impala-STATESTORE-BASE"}}
+		]}`,
+		"/api/v41/clusters/Cluster 1/services/impala/roleConfigGroups": `{"items": [
+			{"name": "impala-IMPALAD-BASE", "config": {"items": []}},
+			{"name": "impala-IMPALAD-custom", "config": {"items": [{"name": "impalad_webserver_port", "value": "25001"}]}}
+		]}`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer api.Close()
+
+	d := &cmDiscoverer{apiURL: api.URL + "/api/v41", roleTypes: []string{"IMPALAD", "STATESTORE"}, username: "admin", password: "secret", client: api.Client()}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	want := []string{"node1.example.com:25000", "node2.example.com:25001", "node1.example.com:25010"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+
+	wantRoles := map[string]string{"node1.example.com:25000": "impalad", "node2.example.com:25001": "impalad", "node1.example.com:25010": "statestored"}
+	if roles := d.Roles(); !reflect.DeepEqual(roles, wantRoles) {
+		t.Errorf("Roles() = %v, want %v", roles, wantRoles)
+	}
+
+	d.password = "wrong"
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() with a wrong password succeeded, want error")
+	}
+}
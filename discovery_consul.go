@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	consulServiceFlag = flag.String("impala.consul.service", "", "Consul service name of the Impala daemons to scrape; enables Consul discovery")
+	consulAddressFlag = flag.String("impala.consul.address", "http://localhost:8500", "URL of the Consul HTTP API")
+	consulTagsFlag    = flag.String("impala.consul.tags", "", "Comma-separated tags a Consul service instance must all have to be scraped")
+)
+
+func init() {
+	RegisterDiscoverer("consul", func() (Discoverer, error) {
+		if *consulServiceFlag == "" {
+			return nil, nil
+		}
+		var tags []string
+		if *consulTagsFlag != "" {
+			tags = dedupeServers(strings.Split(*consulTagsFlag, ","))
+		}
+		return &consulDiscoverer{
+			address: strings.TrimSuffix(*consulAddressFlag, "/"),
+			service: *consulServiceFlag,
+			tags:    tags,
+			// CONSUL_HTTP_TOKEN is the variable the Consul CLI and API clients read their ACL token from
+			token:  os.Getenv("CONSUL_HTTP_TOKEN"),
+			client: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	})
+}
+
+// ConsulServiceEntry represents the parts of an entry of Consul's /v1/health/service response used for discovery
+type ConsulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// consulDiscoverer lists the healthy instances of a service from the Consul catalog
+type consulDiscoverer struct {
+	address string
+	service string
+	tags    []string
+	token   string
+	client  *http.Client
+}
+
+func (d *consulDiscoverer) Name() string {
+	return "consul"
+}
+
+// Discover returns the address of every instance of the service passing its health checks and carrying all tags
+func (d *consulDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	query := url.Values{"passing": {"true"}}
+	for _, tag := range d.tags {
+		query.Add("tag", tag)
+	}
+	u := fmt.Sprintf("%s/v1/health/service/%s?%s", d.address, url.PathEscape(d.service), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.token != "" {
+		req.Header.Set("X-Consul-Token", d.token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("querying service %s: unexpected status %s", d.service, resp.Status)
+	}
+
+	var entries []ConsulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decoding service %s: %w", d.service, err)
+	}
+	servers := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// The service address is optional and defaults to the address of its node
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		servers = append(servers, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return servers, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestConsulDiscoverer(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/v1/health/service/impalad" || query.Get("passing") != "true" || !reflect.DeepEqual(query["tag"], []string{"coordinator", "prod"}) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 25000}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.1.0.2", "Port": 25001}}
+		]`))
+	}))
+	defer api.Close()
+
+	d := &consulDiscoverer{address: api.URL, service: "impalad", tags: []string{"coordinator", "prod"}, client: api.Client()}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	if want := []string{"10.0.0.1:25000", "10.1.0.2:25001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+
+	d.service = "missing"
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() of an unknown service path succeeded, want error")
+	}
+}
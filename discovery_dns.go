@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var dnsSRVFlag = flag.String("impala.dns-srv", "", "Comma-separated DNS SRV names (e.g., _impala-webui._tcp.prod.example.com) resolved into Impala web UI targets every -discovery.refresh-interval")
+
+func init() {
+	RegisterDiscoverer("dns-srv", func() (Discoverer, error) {
+		if *dnsSRVFlag == "" {
+			return nil, nil
+		}
+		return &dnsSRVDiscoverer{names: dedupeServers(strings.Split(*dnsSRVFlag, ",")), lookupSRV: net.DefaultResolver.LookupSRV}, nil
+	})
+}
+
+// dnsSRVDiscoverer resolves DNS SRV records into host:port targets
+type dnsSRVDiscoverer struct {
+	names     []string
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func (d *dnsSRVDiscoverer) Name() string {
+	return "dns-srv"
+}
+
+// Discover resolves every configured name. It fails if any name cannot be resolved, so that
+// a transient DNS error keeps the previous targets rather than dropping those of the failing name.
+func (d *dnsSRVDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	var servers []string
+	for _, name := range d.names {
+		_, records, err := d.lookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", name, err)
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			servers = append(servers, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+	}
+	return servers, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestDNSSRVDiscoverer(t *testing.T) {
+	records := map[string][]*net.SRV{
+		"_impala-webui._tcp.prod.example.com": {
+			{Target: "coord-1.prod.example.com.", Port: 25000},
+			{Target: "coord-2.prod.example.com.", Port: 25000},
+		},
+		"_impala-webui._tcp.dr.example.com": {
+			{Target: "coord-1.dr.example.com.", Port: 25001},
+		},
+	}
+	lookup := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		srv, ok := records[name]
+		if !ok {
+			return "", nil, errors.New("no such host")
+		}
+		return name, srv, nil
+	}
+
+	d := &dnsSRVDiscoverer{names: []string{"_impala-webui._tcp.prod.example.com", "_impala-webui._tcp.dr.example.com"}, lookupSRV: lookup}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	want := []string{"coord-1.prod.example.com:25000", "coord-2.prod.example.com:25000", "coord-1.dr.example.com:25001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+
+	d.names = append(d.names, "_impala-webui._tcp.missing.example.com")
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() with an unresolvable name succeeded, want error")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// fileRefreshInterval is how often the targets file is checked for changes
+const fileRefreshInterval = 5 * time.Second
+
+var targetsFileFlag = flag.String("impala.targets-file", "", "Path of a Prometheus file_sd style JSON or YAML file listing the Impala servers to scrape; changes are picked up without restart")
+
+func init() {
+	RegisterDiscoverer("file", func() (Discoverer, error) {
+		if *targetsFileFlag == "" {
+			return nil, nil
+		}
+		return &fileDiscoverer{path: *targetsFileFlag}, nil
+	})
+}
+
+// TargetGroup is an entry of a file_sd style targets file. Labels are accepted for compatibility but not used.
+type TargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// fileDiscoverer reads the servers to scrape from a targets file, parsing it again only when it changed
+type fileDiscoverer struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	servers []string
+}
+
+func (d *fileDiscoverer) Name() string {
+	return "file"
+}
+
+// RefreshInterval overrides -discovery.refresh-interval, since checking a local file is cheap
+func (d *fileDiscoverer) RefreshInterval() time.Duration {
+	return fileRefreshInterval
+}
+
+// Discover returns the servers listed in the targets file. A file that cannot be read or parsed fails
+// the refresh, so that a half-written file does not drop the current targets.
+func (d *fileDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.servers != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return d.servers, nil
+	}
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := parseTargetsFile(d.path, data)
+	if err != nil {
+		return nil, err
+	}
+	d.modTime, d.size, d.servers = info.ModTime(), info.Size(), servers
+	return servers, nil
+}
+
+// parseTargetsFile parses a file_sd style list of target groups, as YAML when path ends in .yml or .yaml
+// and as JSON otherwise, and returns the targets of all groups
+func parseTargetsFile(path string, data []byte) ([]string, error) {
+	var groups []TargetGroup
+	var err error
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(data, &groups)
+	default:
+		err = json.Unmarshal(data, &groups)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	servers := []string{}
+	for _, group := range groups {
+		servers = append(servers, group.Targets...)
+	}
+	return servers, nil
+}
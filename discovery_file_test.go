@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTargetsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "json",
+			path: "targets.json",
+			data: `[{"targets": ["a:25000", "b:25000"], "labels": {"env": "prod"}}, {"targets": ["coord-3=c:25000"]}]`,
+			want: []string{"a:25000", "b:25000", "coord-3=c:25000"},
+		},
+		{
+			name: "yaml",
+			path: "targets.yml",
+			data: "- targets:\n  - a:25000\n  labels:\n    env: prod\n- targets: [b:25000]\n",
+			want: []string{"a:25000", "b:25000"},
+		},
+		{name: "empty list", path: "targets.json", data: `[]`, want: []string{}},
+		{name: "truncated", path: "targets.json", data: `[{"targets": ["a:25000"`, wantErr: true},
+		{name: "unknown yaml field", path: "targets.yaml", data: "- hosts: [a:25000]\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTargetsFile(tt.path, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTargetsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTargetsFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDiscovererPicksUpChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	write := func(data string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &fileDiscoverer{path: path}
+	now := time.Now()
+
+	write(`[{"targets": ["a:25000"]}]`, now)
+	if got, err := d.Discover(context.Background()); err != nil || !reflect.DeepEqual(got, []string{"a:25000"}) {
+		t.Fatalf("Discover() = %q, %v", got, err)
+	}
+
+	write(`[{"targets": ["a:25000", "b:25000"]}]`, now.Add(time.Second))
+	if got, err := d.Discover(context.Background()); err != nil || !reflect.DeepEqual(got, []string{"a:25000", "b:25000"}) {
+		t.Fatalf("Discover() after change = %q, %v", got, err)
+	}
+
+	write(`[{"targets": [`, now.Add(2*time.Second))
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() of a half-written file succeeded, want error")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	kubernetesSelectorFlag  = flag.String("impala.kubernetes.selector", "", "Label selector of the impalad pods to scrape (e.g., app=impala-coordinator); enables Kubernetes discovery using the in-cluster service account, which lists the pods every -discovery.refresh-interval rather than watching them")
+	kubernetesNamespaceFlag = flag.String("impala.kubernetes.namespace", "", "Namespace of the impalad pods, the exporter's own namespace when unset")
+	kubernetesPortFlag      = flag.Int("impala.kubernetes.port", 25000, "Web UI port of the discovered impalad pods")
+)
+
+func init() {
+	RegisterDiscoverer("kubernetes", func() (Discoverer, error) {
+		if *kubernetesSelectorFlag == "" {
+			return nil, nil
+		}
+		return newKubernetesDiscoverer(*kubernetesSelectorFlag, *kubernetesNamespaceFlag, *kubernetesPortFlag)
+	})
+}
+
+// PodList represents the parts of a Kubernetes pod list used for discovery
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// Pod represents the parts of a Kubernetes pod used for discovery
+type Pod struct {
+	Metadata struct {
+		Name              string  `json:"name"`
+		DeletionTimestamp *string `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Status struct {
+		Phase      string `json:"phase"`
+		PodIP      string `json:"podIP"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// ready reports whether the pod is running, not terminating and passes its readiness checks
+func (p Pod) ready() bool {
+	if p.Status.Phase != "Running" || p.Status.PodIP == "" || p.Metadata.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// kubernetesDiscoverer lists the pods matching a label selector through the Kubernetes API
+type kubernetesDiscoverer struct {
+	apiURL    string
+	namespace string
+	selector  string
+	port      int
+	// tokenFile is read on every refresh, since projected service account tokens are rotated
+	tokenFile string
+	client    *http.Client
+}
+
+// newKubernetesDiscoverer configures a discoverer from the in-cluster service account
+func newKubernetesDiscoverer(selector, namespace string, port int) (*kubernetesDiscoverer, error) {
+	host, apiPort := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || apiPort == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("reading service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in cluster CA")
+	}
+
+	return &kubernetesDiscoverer{
+		apiURL:    "https://" + net.JoinHostPort(host, apiPort),
+		namespace: namespace,
+		selector:  selector,
+		port:      port,
+		tokenFile: serviceAccountDir + "/token",
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+func (d *kubernetesDiscoverer) Name() string {
+	return "kubernetes"
+}
+
+// Discover returns the web UI address of every ready pod matching the selector.
+// Pods are listed on every refresh instead of watched, so changes are picked up within the refresh interval.
+func (d *kubernetesDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/pods?labelSelector=%s", d.apiURL, url.PathEscape(d.namespace), url.QueryEscape(d.selector))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := os.ReadFile(d.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing pods: unexpected status %s", resp.Status)
+	}
+
+	var pods PodList
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, fmt.Errorf("decoding pod list: %w", err)
+	}
+	var servers []string
+	for _, pod := range pods.Items {
+		if pod.ready() {
+			servers = append(servers, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(d.port)))
+		}
+	}
+	return servers, nil
+}
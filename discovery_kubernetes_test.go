@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const podListJSON = `{
+  "items": [
+    {"metadata": {"name": "coord-0"}, "status": {"phase": "Running", "podIP": "10.0.0.1", "conditions": [{"type": "Ready", "status": "True"}]}},
+    {"metadata": {"name": "coord-1"}, "status": {"phase": "Running", "podIP": "10.0.0.2", "conditions": [{"type": "Ready", "status": "False"}]}},
+    {"metadata": {"name": "coord-2"}, "status": {"phase": "Pending", "podIP": "", "conditions": []}},
+    {"metadata": {"name": "coord-3", "deletionTimestamp": "2024-01-01T00:00:00Z"}, "status": {"phase": "Running", "podIP": "10.0.0.4", "conditions": [{"type": "Ready", "status": "True"}]}}
+  ]
+}`
+
+func TestKubernetesDiscoverer(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/impala/pods" || r.URL.Query().Get("labelSelector") != "app=impala-coordinator" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(podListJSON))
+	}))
+	defer api.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d := &kubernetesDiscoverer{
+		apiURL:    api.URL,
+		namespace: "impala",
+		selector:  "app=impala-coordinator",
+		port:      25000,
+		tokenFile: tokenFile,
+		client:    api.Client(),
+	}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+	if want := []string{"10.0.0.1:25000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("expired"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() with a rejected token succeeded, want error")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffServers(t *testing.T) {
+	tests := []struct {
+		name           string
+		previous, next []string
+		added, removed int
+	}{
+		{"initial", nil, []string{"a:25000", "b:25000"}, 2, 0},
+		{"unchanged", []string{"a:25000"}, []string{"a:25000"}, 0, 0},
+		{"replaced", []string{"a:25000", "b:25000"}, []string{"b:25000", "c:25000"}, 1, 1},
+		{"emptied", []string{"a:25000"}, nil, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := diffServers(tt.previous, tt.next)
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("diffServers(%q, %q) = %d, %d, want %d, %d", tt.previous, tt.next, added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+type staticDiscoverer struct {
+	servers []string
+	err     error
+}
+
+func (d *staticDiscoverer) Name() string { return "static" }
+
+func (d *staticDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	return d.servers, d.err
+}
+
+func TestDiscoveryRefreshKeepsTargetsOnFailure(t *testing.T) {
+	exporter := NewExporter(nil, ExporterOptions{})
+	m := &discoveryManager{exporter: exporter}
+	d := &staticDiscoverer{servers: []string{"a:25000", "a:25000", "b:25000"}}
+
+	current := m.refresh(context.Background(), d, nil)
+	want := []string{"a:25000", "b:25000"}
+	if !reflect.DeepEqual(current, want) || !reflect.DeepEqual(exporter.Servers(), want) {
+		t.Fatalf("after refresh: current %q, exporter %q, want %q", current, exporter.Servers(), want)
+	}
+
+	d.err = errors.New("session expired")
+	current = m.refresh(context.Background(), d, current)
+	if !reflect.DeepEqual(current, want) || !reflect.DeepEqual(exporter.Servers(), want) {
+		t.Errorf("after failed refresh: current %q, exporter %q, want %q", current, exporter.Servers(), want)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultExecutorGroup is the group of the executors started without -executor_groups
+const defaultExecutorGroup = "default"
+
+// ExecutorGroupBackend represents the executor group parts of a daemon as rendered by Impala's /backends?json page
+type ExecutorGroupBackend struct {
+	Address    string `json:"address"`
+	IsExecutor bool   `json:"is_executor"`
+	// ExecutorGroups are the comma-separated groups the executor belongs to
+	ExecutorGroups string `json:"executor_groups"`
+	AdmissionSlots int    `json:"admission_slots"`
+}
+
+// ExecutorGroupsResponse represents the executor group parts of the JSON response from Impala for /backends
+type ExecutorGroupsResponse struct {
+	Backends []ExecutorGroupBackend `json:"backends"`
+}
+
+// executorGroup is the executors of a group seen by a coordinator
+type executorGroup struct {
+	executors      int
+	admissionSlots int
+}
+
+// executorGroups returns the executors and admission slots of every group of the cluster membership, including the
+// groups of minSizes without any executor, which autoscaling may have shut down
+func executorGroups(backends []ExecutorGroupBackend, minSizes map[string]int) map[string]*executorGroup {
+	groups := make(map[string]*executorGroup)
+	for name := range minSizes {
+		groups[name] = &executorGroup{}
+	}
+	for _, backend := range backends {
+		if !backend.IsExecutor {
+			continue
+		}
+		names := strings.Split(backend.ExecutorGroups, ",")
+		if strings.TrimSpace(backend.ExecutorGroups) == "" {
+			names = []string{defaultExecutorGroup}
+		}
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			group, ok := groups[name]
+			if !ok {
+				group = &executorGroup{}
+				groups[name] = group
+			}
+			group.executors++
+			group.admissionSlots += backend.AdmissionSlots
+		}
+	}
+	return groups
+}
+
+// parseExecutorGroupMinSizes parses the minimum sizes of the executor groups, as group=size pairs separated by commas
+func parseExecutorGroupMinSizes(s string) (map[string]int, error) {
+	sizes := make(map[string]int)
+	if strings.TrimSpace(s) == "" {
+		return sizes, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		size, err := strconv.Atoi(value)
+		if !ok || name == "" || err != nil || size < 0 {
+			return nil, fmt.Errorf("expected group=size, got %q", pair)
+		}
+		sizes[name] = size
+	}
+	return sizes, nil
+}
+
+// executorGroupsCollector exports the executor groups of the cluster membership seen by a coordinator, for
+// deployments running several of them, e.g. with autoscaling
+type executorGroupsCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the executor group metrics over to the provided channel
+func (c executorGroupsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.executorGroupExecutors
+	ch <- c.e.executorGroupSlots
+	ch <- c.e.executorGroupHealthy
+}
+
+// Collect fetches the backends of a coordinator and sends the executors and admission slots of each executor group
+// over to the provided channel, along with whether the groups of ExecutorGroupMinSizes reach their minimum size
+func (c executorGroupsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var resp ExecutorGroupsResponse
+	if err := fetchJSON(ctx, target.Address, "/backends?json", &resp); err != nil {
+		return err
+	}
+	minSizes := e.options.ExecutorGroupMinSizes
+	for name, group := range executorGroups(resp.Backends, minSizes) {
+		ch <- prometheus.MustNewConstMetric(e.executorGroupExecutors, prometheus.GaugeValue, float64(group.executors), target.Name, name)
+		ch <- prometheus.MustNewConstMetric(e.executorGroupSlots, prometheus.GaugeValue, float64(group.admissionSlots), target.Name, name)
+		if minSize, ok := minSizes[name]; ok {
+			healthy := 0.0
+			if group.executors >= minSize {
+				healthy = 1
+			}
+			ch <- prometheus.MustNewConstMetric(e.executorGroupHealthy, prometheus.GaugeValue, healthy, target.Name, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseExecutorGroupMinSizes(t *testing.T) {
+	got, err := parseExecutorGroupMinSizes("root.large-group=4, root.small-group=2")
+	if err != nil {
+		t.Fatalf("parseExecutorGroupMinSizes() error = %v", err)
+	}
+	if want := map[string]int{"root.large-group": 4, "root.small-group": 2}; !maps.Equal(got, want) {
+		t.Errorf("parseExecutorGroupMinSizes() = %v, want %v", got, want)
+	}
+	if got, err := parseExecutorGroupMinSizes(""); err != nil || len(got) != 0 {
+		t.Errorf("parseExecutorGroupMinSizes(\"\") = %v, %v, want none", got, err)
+	}
+	for _, s := range []string{"root.large-group", "=4", "root.large-group=-1", "root.large-group=many"} {
+		if _, err := parseExecutorGroupMinSizes(s); err == nil {
+			t.Errorf("parseExecutorGroupMinSizes(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestExecutorGroupsCollector(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"backends": [
+			{"address": "coord:27000", "is_coordinator": true, "is_executor": false, "executor_groups": "", "admission_slots": 8},
+			{"address": "large-1:27000", "is_executor": true, "executor_groups": "root.large-group", "admission_slots": 16},
+			{"address": "large-2:27000", "is_executor": true, "executor_groups": "root.large-group", "admission_slots": 16},
+			{"address": "exec:27000", "is_executor": true, "admission_slots": 4}
+		]}`))
+	}))
+	defer impala.Close()
+
+	// The small group was scaled down to no executors
+	e := NewExporter(nil, ExporterOptions{ExecutorGroupMinSizes: map[string]int{"root.large-group": 3, "root.small-group": 1}})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (executorGroupsCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	want := map[string]float64{
+		`impala_executor_group_executors{group="root.large-group"}`:       2,
+		`impala_executor_group_admission_slots{group="root.large-group"}`: 32,
+		`impala_executor_group_healthy{group="root.large-group"}`:         0,
+		`impala_executor_group_executors{group="root.small-group"}`:       0,
+		`impala_executor_group_admission_slots{group="root.small-group"}`: 0,
+		`impala_executor_group_healthy{group="root.small-group"}`:         0,
+		`impala_executor_group_executors{group="default"}`:                1,
+		`impala_executor_group_admission_slots{group="default"}`:          4,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
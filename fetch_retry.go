@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fetchRetries is how many times a request to the Impala web UI failing transiently is retried within a scrape,
+// waiting fetchBackoff before the first retry and twice as long before each further one
+var (
+	fetchRetries = 1
+	fetchBackoff = 100 * time.Millisecond
+)
+
+var fetchRetriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "impala_exporter_fetch_retries_total",
+		Help: "Number of requests to an Impala web UI endpoint retried after a transient failure",
+	},
+	[]string{"endpoint"},
+)
+
+// fetchWithRetries calls fetch until it succeeds, fails permanently or fetchRetries retries are used up.
+// The retries stop when ctx is done, so they never outlast the scrape.
+func fetchWithRetries(ctx context.Context, path string, fetch func() error) error {
+	backoff := fetchBackoff
+	for attempt := 0; ; attempt++ {
+		err := fetch()
+		if err == nil || attempt >= fetchRetries || !isTransientFetchError(ctx, err) {
+			return err
+		}
+		endpoint, _, _ := strings.Cut(path, "?")
+		slog.Debug("Retrying Impala request", "endpoint", endpoint, "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		fetchRetriesTotal.WithLabelValues(endpoint).Inc()
+		backoff *= 2
+	}
+}
+
+// isTransientFetchError reports whether err may go away on a retry: connection failures, responses cut short and
+// server errors, but neither malformed JSON, oversized responses, other unexpected responses nor an expired scrape. Timeouts are not retried either, a hung endpoint would
+// otherwise hold the scrape for several -impala_timeout.
+func isTransientFetchError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, errResponseTooLarge) {
+		return false
+	}
+	// A server error may be passing, a client error or an HTML page will be served again
+	var respErr *unexpectedResponseError
+	if errors.As(err, &respErr) {
+		return respErr.status >= http.StatusInternalServerError
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr)
+}
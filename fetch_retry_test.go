@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchJSONRetriesDroppedConnection(t *testing.T) {
+	var requests atomic.Int32
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Reset the first connection mid-response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"client_hosts": [{"hostname": "client1"}]}`))
+	}))
+	defer impala.Close()
+
+	var sessions ImpalaSessionsResponse
+	if err := fetchJSON(context.Background(), strings.TrimPrefix(impala.URL, "http://"), "/sessions?json", &sessions); err != nil {
+		t.Fatalf("fetchJSON() error = %v", err)
+	}
+	if len(sessions.ClientHosts) != 1 || requests.Load() != 2 {
+		t.Errorf("got %d client hosts after %d requests, want 1 after 2", len(sessions.ClientHosts), requests.Load())
+	}
+}
+
+func TestFetchJSONDoesNotRetryMalformedJSON(t *testing.T) {
+	var requests atomic.Int32
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`<html>`))
+	}))
+	defer impala.Close()
+
+	var sessions ImpalaSessionsResponse
+	if err := fetchJSON(context.Background(), strings.TrimPrefix(impala.URL, "http://"), "/sessions?json", &sessions); err == nil {
+		t.Fatal("fetchJSON() error = nil, want a decoding error")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("got %d requests, want 1", requests.Load())
+	}
+}
+
+func TestFetchWithRetriesStopsWithContext(t *testing.T) {
+	defer func(retries int, backoff time.Duration) { fetchRetries, fetchBackoff = retries, backoff }(fetchRetries, fetchBackoff)
+	fetchRetries, fetchBackoff = 5, time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	err := fetchWithRetries(ctx, "/queries?json", func() error {
+		attempts++
+		return io.ErrUnexpectedEOF
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("got error %v after %d attempts, want an error after 1", err, attempts)
+	}
+}
+
+func TestFetchJSONRejectsOversizedResponse(t *testing.T) {
+	defer func(limit int64) { maxResponseBytes = limit }(maxResponseBytes)
+	maxResponseBytes = 16
+
+	var requests atomic.Int32
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"client_hosts": [{"hostname": "client1"}]}`))
+	}))
+	defer impala.Close()
+
+	var sessions ImpalaSessionsResponse
+	err := fetchJSON(context.Background(), strings.TrimPrefix(impala.URL, "http://"), "/sessions?json", &sessions)
+	if !errors.Is(err, errResponseTooLarge) {
+		t.Errorf("fetchJSON() error = %v, want errResponseTooLarge", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("got %d requests, want 1", requests.Load())
+	}
+}
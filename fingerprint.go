@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherFingerprint is the fingerprint label of the queries whose shape is beyond the cardinality cap
+const otherFingerprint = "other"
+
+// fingerprintBuckets are the buckets of impala_query_fingerprint_duration_seconds
+var fingerprintBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Lists of placeholders left by ScrubSQL, such as the values of an IN-list or the rows of a VALUES clause
+var (
+	placeholderListRe = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	placeholderRowsRe = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+)
+
+// normalizeSQL reduces a statement to its shape: literals are replaced with ?, lists of them collapsed to (?),
+// comments dropped, whitespace collapsed and everything but `quoted identifiers` lowercased
+func normalizeSQL(stmt string) string {
+	scrubbed := ScrubSQL(stmt)
+	var b strings.Builder
+	b.Grow(len(scrubbed))
+	space := false
+	for i := 0; i < len(scrubbed); {
+		c := scrubbed[i]
+		switch {
+		case c == '`':
+			end := strings.IndexByte(scrubbed[i+1:], '`')
+			if end < 0 {
+				end = len(scrubbed) - i - 2
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(scrubbed[i : i+end+2])
+			i += end + 2
+			space = false
+		case c == '-' && strings.HasPrefix(scrubbed[i:], "--"):
+			end := strings.IndexByte(scrubbed[i:], '\n')
+			if end < 0 {
+				end = len(scrubbed) - i
+			}
+			i += end
+			space = true
+		case c == '/' && strings.HasPrefix(scrubbed[i:], "/*"):
+			end := strings.Index(scrubbed[i+2:], "*/")
+			if end < 0 {
+				end = len(scrubbed) - i - 4
+			}
+			i += end + 4
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			space = true
+		default:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			b.WriteByte(c)
+			i++
+			space = false
+		}
+	}
+	normalized := placeholderListRe.ReplaceAllString(b.String(), "(?)")
+	normalized = placeholderRowsRe.ReplaceAllString(normalized, "(?)")
+	return strings.TrimSuffix(normalized, ";")
+}
+
+// fingerprintSQL returns the fingerprint of the shape of a statement, see normalizeSQL
+func fingerprintSQL(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fingerprintStats accumulates the durations of the completed queries of a fingerprint
+type fingerprintStats struct {
+	count uint64
+	sum   float64
+	// buckets are the non-cumulative counts of fingerprintBuckets
+	buckets []uint64
+}
+
+func (s *fingerprintStats) observe(seconds float64) {
+	s.count++
+	s.sum += seconds
+	for i, bound := range fingerprintBuckets {
+		if seconds <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+}
+
+// queryFingerprints accumulates, per server, the completed queries of each fingerprint
+type queryFingerprints struct {
+	completedQueries
+	stats map[string]*fingerprintStats
+}
+
+// collectFingerprints accounts for the queries of a server completed since the previous scrape and sends the
+// per-fingerprint query counts and durations over to the provided channel. Beyond FingerprintLimit fingerprints,
+// further shapes are accounted for as otherFingerprint. A new fingerprint is logged with its normalized statement,
+// so that it can be told what it stands for.
+func (e *Exporter) collectFingerprints(ch chan<- prometheus.Metric, server string, completed []CompletedQuery) {
+	limit := e.options.FingerprintLimit
+	if limit <= 0 {
+		return
+	}
+	e.fingerprintsMu.Lock()
+	defer e.fingerprintsMu.Unlock()
+	fingerprints, ok := e.fingerprints[server]
+	if !ok {
+		fingerprints = &queryFingerprints{stats: make(map[string]*fingerprintStats)}
+		e.fingerprints[server] = fingerprints
+	}
+	for _, query := range fingerprints.pendingQueries(completed, len(completed)) {
+		fingerprints.counted[query.QueryID] = true
+		seconds, err := ParseDuration(query.Duration)
+		if err != nil {
+			slog.Debug("Error parsing query duration", "target", server, "endpoint", "/queries?json", "err", err)
+			continue
+		}
+		normalized := normalizeSQL(query.Stmt)
+		fingerprint := fingerprintSQL(normalized)
+		stats, ok := fingerprints.stats[fingerprint]
+		if !ok {
+			shapes := len(fingerprints.stats)
+			if _, ok := fingerprints.stats[otherFingerprint]; ok {
+				shapes--
+			}
+			if shapes >= limit {
+				fingerprint = otherFingerprint
+			} else {
+				slog.Info("New query fingerprint", "target", server, "fingerprint", fingerprint, "statement", statementSnippet(normalized))
+			}
+			if stats, ok = fingerprints.stats[fingerprint]; !ok {
+				stats = &fingerprintStats{buckets: make([]uint64, len(fingerprintBuckets))}
+				fingerprints.stats[fingerprint] = stats
+			}
+		}
+		stats.observe(seconds)
+	}
+
+	for fingerprint, stats := range fingerprints.stats {
+		ch <- prometheus.MustNewConstMetric(e.fingerprintQueries, prometheus.CounterValue, float64(stats.count), server, fingerprint)
+		buckets := make(map[float64]uint64, len(fingerprintBuckets))
+		var cumulative uint64
+		for i, bound := range fingerprintBuckets {
+			cumulative += stats.buckets[i]
+			buckets[bound] = cumulative
+		}
+		ch <- prometheus.MustNewConstHistogram(e.fingerprintDuration, stats.count, stats.sum, buckets, server, fingerprint)
+	}
+}
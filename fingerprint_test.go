@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{"literals", "SELECT * FROM t WHERE a = 'x' AND b > 42", "select * from t where a = ? and b > ?"},
+		{"in-list", "select * from t where id IN (1, 2,3) and s in ('a')", "select * from t where id in (?) and s in (?)"},
+		{"values rows", "insert into t values (1, 'a'), (2, 'b') ,(3,'c');", "insert into t values (?)"},
+		{"comments and whitespace", "select /* +straight_join */ a\n\t-- trailing\nfrom   t", "select a from t"},
+		{"quoted identifiers", "select `Col A` from `DB`.T", "select `Col A` from `DB`.t"},
+		{"function arguments", "select concat(a, b) from t", "select concat(a, b) from t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeSQL(tt.stmt); got != tt.want {
+				t.Errorf("normalizeSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if fingerprintSQL(normalizeSQL("select 1")) != fingerprintSQL(normalizeSQL("SELECT  2")) {
+		t.Error("statements of the same shape have different fingerprints")
+	}
+}
+
+func TestCollectFingerprints(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{FingerprintLimit: 2})
+	scrape := func(queries ...CompletedQuery) map[string]float64 {
+		return collectValues(t, e, func(ch chan<- prometheus.Metric) { e.collectFingerprints(ch, "impalad", queries) })
+	}
+	a, b, c := fingerprintSQL("select ? from a"), fingerprintSQL("select ? from b"), fingerprintSQL("select ? from c")
+
+	// The queries already completed on the first scrape are not counted
+	scrape(CompletedQuery{QueryID: "0", Stmt: "select 0 from a", Duration: "1s"})
+	scrape(
+		CompletedQuery{QueryID: "1", Stmt: "select 1 from a", Duration: "1s"},
+		CompletedQuery{QueryID: "2", Stmt: "select 2 from b", Duration: "2s"},
+		CompletedQuery{QueryID: "3", Stmt: "select 3 from c", Duration: "3s"},
+		CompletedQuery{QueryID: "0", Stmt: "select 0 from a", Duration: "1s"},
+	)
+	got := scrape(
+		CompletedQuery{QueryID: "4", Stmt: "SELECT 4 FROM a", Duration: "500ms"},
+		CompletedQuery{QueryID: "1", Stmt: "select 1 from a", Duration: "1s"},
+		CompletedQuery{QueryID: "2", Stmt: "select 2 from b", Duration: "2s"},
+		CompletedQuery{QueryID: "3", Stmt: "select 3 from c", Duration: "3s"},
+	)
+	want := map[string]float64{
+		`impala_query_fingerprint_queries_total{fingerprint="` + a + `"}`:    2,
+		`impala_query_fingerprint_queries_total{fingerprint="` + b + `"}`:    1,
+		`impala_query_fingerprint_queries_total{fingerprint="other"}`:        1,
+		`impala_query_fingerprint_duration_seconds{fingerprint="` + a + `"}`: 0,
+		`impala_query_fingerprint_duration_seconds{fingerprint="` + b + `"}`: 0,
+		`impala_query_fingerprint_duration_seconds{fingerprint="other"}`:     0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectFingerprints() = %v, want %v", got, want)
+	}
+	if _, ok := got[`impala_query_fingerprint_queries_total{fingerprint="`+c+`"}`]; ok {
+		t.Errorf("fingerprint beyond the limit exported")
+	}
+}
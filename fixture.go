@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// fixtureTransport answers the requests to the Impala web UIs with saved responses rather than contacting the
+// servers: the response of an endpoint of a server is the file named after the endpoint in the directory named after
+// the server address, e.g. <dir>/coord-1:25000/sessions.json for /sessions?json. Every query profile is served from
+// the same query_profile.json. The files are read on every request, so that they can be edited while the exporter runs.
+type fixtureTransport struct {
+	dir string
+}
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	file := filepath.Join(t.dir, req.URL.Host, path.Base(req.URL.Path)+".json")
+	status, body := http.StatusOK, []byte(nil)
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		status, body = http.StatusNotFound, []byte(fmt.Sprintf("no fixture %s\n", file))
+	case err != nil:
+		return nil, err
+	default:
+		body = data
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// fixtureServers returns the servers of a fixture directory, the names of its subdirectories
+func fixtureServers(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			servers = append(servers, entry.Name())
+		}
+	}
+	slices.Sort(servers)
+	return servers, nil
+}
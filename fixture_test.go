@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFixtureTransport(t *testing.T) {
+	dir := t.TempDir()
+	server := filepath.Join(dir, "coord-1:25000")
+	if err := os.Mkdir(server, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(server, "sessions.json"), []byte(`{"client_hosts": [{"hostname": "bi", "total_sessions": 3}]}`), 0o644)
+	os.WriteFile(filepath.Join(server, "queries.json"), []byte(`{"in_flight_queries": [{"query_id": "a:1", "duration": "2m"}]}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a server"), 0o644)
+
+	servers, err := fixtureServers(dir)
+	if err != nil || !slices.Equal(servers, []string{"coord-1:25000"}) {
+		t.Fatalf("fixtureServers() = %q, %v, want the coord-1:25000 directory", servers, err)
+	}
+
+	defer func(rt http.RoundTripper) { httpClient.Transport = rt }(httpClient.Transport)
+	httpClient.Transport = fixtureTransport{dir: dir}
+	e := NewExporter(servers, ExporterOptions{Collectors: map[string]bool{"sessions": true, "queries": true}})
+	values := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if !e.collectTarget(context.Background(), ch, newTarget(servers[0], "")) {
+			t.Error("collectTarget() = false, want a complete scrape from the fixtures")
+		}
+	})
+	if got := values[`impala_total_sessions{impala_client="bi"}`]; got != 3 {
+		t.Errorf("impala_total_sessions = %v, want 3", got)
+	}
+	if got := values["impala_inflight_queries_count"]; got != 1 {
+		t.Errorf("impala_inflight_queries_count = %v, want 1", got)
+	}
+
+	// An endpoint without a fixture fails like a missing page
+	var metrics map[string]any
+	if err := fetchJSON(context.Background(), servers[0], "/metrics?json", &metrics); err == nil {
+		t.Error("fetchJSON() of an endpoint without a fixture succeeded")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultFlagAllowlist are the startup flags exported by default, those whose drift across a fleet matters most
+const defaultFlagAllowlist = "mem_limit,queue_wait_timeout_ms,default_pool_max_requests,default_pool_max_queued,idle_session_timeout,idle_query_timeout,fe_service_threads"
+
+// flagInfoCollector exports the startup flags of a daemon listed in FlagAllowlist, so that the configuration of a
+// fleet can be checked for consistency from Prometheus. Only allowlisted flags are exported since some, such as
+// -ldap_bind_password_cmd or -ssl_private_key, may hold secrets.
+type flagInfoCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_flag_info over to the provided channel
+func (c flagInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.flagInfo
+}
+
+// Collect fetches the flags of a daemon from /varz and sends the current value of the allowlisted ones it has over
+// to the provided channel
+func (c flagInfoCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var varz VarzResponse
+	if err := fetchJSON(ctx, target.Address, "/varz?json", &varz); err != nil {
+		return err
+	}
+	for _, name := range e.options.FlagAllowlist {
+		if value, ok := varz.flag(name); ok {
+			ch <- prometheus.MustNewConstMetric(e.flagInfo, prometheus.GaugeValue, 1, target.Name, name, value)
+		}
+	}
+	return nil
+}
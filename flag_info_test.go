@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFlagInfoCollector(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/varz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"flags": [
+			{"name": "mem_limit", "type": "string", "default": "80%", "current": "64g"},
+			{"name": "queue_wait_timeout_ms", "type": "int64", "default": "60000", "current": "60000"},
+			{"name": "ldap_bind_password_cmd", "type": "string", "default": "", "current": "cat /secret"}
+		]}`))
+	}))
+	defer impala.Close()
+
+	// Allowlisted flags the daemon does not have are left out
+	e := NewExporter(nil, ExporterOptions{FlagAllowlist: []string{"mem_limit", "queue_wait_timeout_ms", "admission_control_slots"}})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (flagInfoCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	want := map[string]float64{
+		`impala_flag_info{name="mem_limit",value="64g"}`:               1,
+		`impala_flag_info{name="queue_wait_timeout_ms",value="60000"}`: 1,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	graphiteAddressFlag  = flag.String("graphite.address", "", "host:port of a Graphite carbon plaintext listener the metrics are sent to; enables the graphite sink")
+	graphitePrefixFlag   = flag.String("graphite.prefix", "impala", "Prefix of the metric paths sent to -graphite.address")
+	graphiteIntervalFlag = flag.Duration("graphite.interval", 0, "How often the metrics are sent to -graphite.address, a multiple of -sink.interval; 0 sends every snapshot")
+	graphiteTaggedFlag   = flag.Bool("graphite.tagged", false, "Send the labels as Graphite 1.1 tags, name;label=value, rather than as path components holding the label values")
+)
+
+func init() {
+	RegisterSink("graphite", func() (Sink, error) {
+		if *graphiteAddressFlag == "" {
+			return nil, nil
+		}
+		if _, _, err := net.SplitHostPort(*graphiteAddressFlag); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", *graphiteAddressFlag, err)
+		}
+		return &graphiteSink{
+			address:  *graphiteAddressFlag,
+			prefix:   strings.TrimSuffix(*graphitePrefixFlag, "."),
+			interval: *graphiteIntervalFlag,
+			tagged:   *graphiteTaggedFlag,
+			timeout:  10 * time.Second,
+		}, nil
+	})
+}
+
+// graphitePathRe matches the characters left out of a Graphite path component
+var graphitePathRe = regexp.MustCompile(`[^a-zA-Z0-9_:-]`)
+
+// graphiteTagRe matches the characters left out of a Graphite tag value, which may not hold ; or ~ nor be empty
+var graphiteTagRe = regexp.MustCompile(`[;~\s]`)
+
+// graphiteSink sends the metrics snapshots to a Graphite carbon listener over its plaintext protocol
+type graphiteSink struct {
+	address  string
+	prefix   string
+	interval time.Duration
+	tagged   bool
+	timeout  time.Duration
+	// sent is when the last snapshot was sent, to send one every interval
+	sent time.Time
+}
+
+func (s *graphiteSink) Name() string {
+	return "graphite"
+}
+
+func (s *graphiteSink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit sends the metrics of a snapshot event, one "path value timestamp" line per sample, over a new connection,
+// skipping the other events and the snapshots published within interval of the last one sent
+func (s *graphiteSink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 || event.Time.Sub(s.sent) < s.interval {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	w := bufio.NewWriter(conn)
+	for _, sample := range familySamples(event.Metrics, event.Time) {
+		fmt.Fprintf(w, "%s %s %d\n", s.path(sample), strconv.FormatFloat(sample.Value, 'g', -1, 64), sample.Time.Unix())
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	s.sent = event.Time
+	return nil
+}
+
+// path returns the Graphite path of a sample: prefix.name followed by its label values in label name order, or by
+// its labels as tags when tagged
+func (s *graphiteSink) path(sample sinkSample) string {
+	var b strings.Builder
+	if s.prefix != "" {
+		b.WriteString(s.prefix + ".")
+	}
+	b.WriteString(sample.Name)
+	for _, l := range sample.Labels {
+		if s.tagged {
+			if value := graphiteTagRe.ReplaceAllString(l.GetValue(), "_"); value != "" {
+				b.WriteString(";" + l.GetName() + "=" + value)
+			}
+			continue
+		}
+		b.WriteString("." + graphitePathRe.ReplaceAllString(l.GetValue(), "_"))
+	}
+	return b.String()
+}
+
+func (s *graphiteSink) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGraphiteSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b, _ := io.ReadAll(conn)
+			conn.Close()
+			received <- string(b)
+		}
+	}()
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up"}, []string{"impala_server", "role"})
+	up.WithLabelValues("coord-1.example.com:25000", "impalad").Set(1)
+	registry.MustRegister(up)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	s := &graphiteSink{address: listener.Addr().String(), prefix: "dc1", interval: time.Minute, timeout: time.Second}
+	// Events without metrics are skipped
+	if err := s.Emit(context.Background(), Event{Time: now, LongQuery: &slowQueryEntry{QueryID: "q1"}}); err != nil {
+		t.Fatalf("Emit() of a query event error = %v", err)
+	}
+	if err := s.Emit(context.Background(), Event{Time: now, Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	select {
+	case got := <-received:
+		if want := "dc1.impala_up.coord-1_example_com:25000.impalad 1 1700000000\n"; got != want {
+			t.Errorf("sent %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing sent")
+	}
+	// Snapshots within the interval of the last one sent are skipped
+	if err := s.Emit(context.Background(), Event{Time: now.Add(30 * time.Second), Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+
+	s.tagged = true
+	if err := s.Emit(context.Background(), Event{Time: now.Add(time.Minute), Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	select {
+	case got := <-received:
+		if !strings.HasPrefix(got, "dc1.impala_up;impala_server=coord-1.example.com:25000;role=impalad 1 ") {
+			t.Errorf("sent %q, want the labels as tags", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing sent")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// healthzHandler reports that the process is up and serving HTTP
+func healthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// readyzHandler reports readiness once ready is set and, if requireScrape is true,
+// once at least one Impala server has been scraped successfully.
+// Readiness checks never contact the Impala servers themselves, so they answer within probe timeouts.
+func readyzHandler(ready *atomic.Bool, exporter *Exporter, requireScrape bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "configuration not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		if requireScrape && !exporter.Scraped() {
+			http.Error(w, "no successful Impala scrape yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// Scraped reports whether any Impala server has been scraped successfully since startup
+func (e *Exporter) Scraped() bool {
+	return e.scraped.Load()
+}
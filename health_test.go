@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	// The server is unreachable: readiness must not depend on contacting it
+	exporter := NewExporter([]string{"127.0.0.1:1"}, ExporterOptions{})
+	var ready atomic.Bool
+	handler := readyzHandler(&ready, exporter, true)
+
+	status := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("before configuration: status %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	ready.Store(true)
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("before a scrape: status %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	exporter.scraped.Store(true)
+	if got := status(); got != http.StatusOK {
+		t.Errorf("after a scrape: status %d, want %d", got, http.StatusOK)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// hs2ProtocolV6 is the HiveServer2 protocol version requested, the highest Impala speaks
+const hs2ProtocolV6 = 5
+
+// HiveServer2 status codes of TStatus
+const (
+	hs2StatusSuccess         = 0
+	hs2StatusSuccessWithInfo = 1
+)
+
+// SASL negotiation status bytes of the Hive SASL transport
+const (
+	saslStart    = 1
+	saslOK       = 2
+	saslBad      = 3
+	saslError    = 4
+	saslComplete = 5
+)
+
+// hs2Auth is how a HiveServer2 connection authenticates: without SASL when mechanism is empty, or with SASL PLAIN,
+// as for LDAP authentication
+type hs2Auth struct {
+	mechanism string
+	user      string
+	password  string
+}
+
+// hs2Conn is a HiveServer2 client connection over the binary Thrift protocol, unframed without SASL and framed by
+// the SASL transport otherwise. Kerberos is not supported. The Beeswax port speaks the same transports, so it is
+// reached with an hs2Conn as well for liveness checks.
+type hs2Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	sasl bool
+	seq  int32
+}
+
+// dialHS2 connects to the HiveServer2 port at address and authenticates. The connection is closed when ctx is done.
+func dialHS2(ctx context.Context, address string, auth hs2Auth) (*hs2Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+	c := &hs2Conn{conn: conn, r: bufio.NewReader(conn), sasl: auth.mechanism != ""}
+	if c.sasl {
+		if err := c.negotiateSASL(auth); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("SASL negotiation: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// negotiateSASL authenticates with the PLAIN mechanism, its initial response carrying the credentials
+func (c *hs2Conn) negotiateSASL(auth hs2Auth) error {
+	var b []byte
+	b = appendSASLMessage(b, saslStart, []byte(auth.mechanism))
+	b = appendSASLMessage(b, saslOK, []byte("\x00"+auth.user+"\x00"+auth.password))
+	if _, err := c.conn.Write(b); err != nil {
+		return err
+	}
+	status, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	payload, err := readThriftFrame(c.r)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case saslComplete:
+		return nil
+	case saslBad, saslError:
+		message, _ := io.ReadAll(payload)
+		return fmt.Errorf("rejected: %s", message)
+	}
+	return fmt.Errorf("unexpected SASL status %d", status)
+}
+
+func appendSASLMessage(b []byte, status byte, payload []byte) []byte {
+	b = append(b, status)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// call calls a TCLIService method with its single request struct and returns the response struct
+func (c *hs2Conn) call(method string, req thriftFields) (thriftFields, error) {
+	result, err := c.roundTrip(method, thriftFields{1: thriftStructValue(req)})
+	if err != nil {
+		return nil, err
+	}
+	resp := result.structField(0)
+	if resp == nil {
+		return nil, fmt.Errorf("%s returned no response", method)
+	}
+	status := resp.structField(1)
+	if code, _ := status.i32(1); code != hs2StatusSuccess && code != hs2StatusSuccessWithInfo {
+		return nil, fmt.Errorf("%s failed: %s", method, status.str(5))
+	}
+	return resp, nil
+}
+
+// pingMethod is a method no Impala service implements
+const pingMethod = "impala_exporter_ping"
+
+// ping calls pingMethod: a server answering with an exception, or any other reply, is processing requests
+func (c *hs2Conn) ping() error {
+	_, err := c.roundTrip(pingMethod, thriftFields{})
+	var appErr *thriftApplicationError
+	if errors.As(err, &appErr) {
+		return nil
+	}
+	return err
+}
+
+// roundTrip sends a call of method with the given arguments struct and returns the result struct of the reply
+func (c *hs2Conn) roundTrip(method string, args thriftFields) (thriftFields, error) {
+	c.seq++
+	msg := appendThriftMessage(nil, method, c.seq, args)
+	if c.sasl {
+		msg = append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+	}
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	r := &thriftReader{r: c.r}
+	if c.sasl {
+		frame, err := readThriftFrame(c.r)
+		if err != nil {
+			return nil, err
+		}
+		r = &thriftReader{r: frame}
+	}
+	return r.readMessage(method, c.seq)
+}
+
+// Close closes the connection
+func (c *hs2Conn) Close() error {
+	return c.conn.Close()
+}
+
+// runQuery opens a session, runs stmt, fetches the first row of its result and closes the session again
+func (c *hs2Conn) runQuery(stmt string, auth hs2Auth) (err error) {
+	open := thriftFields{1: thriftI32Value(hs2ProtocolV6)}
+	if auth.user != "" {
+		open[2] = thriftStringValue(auth.user)
+	}
+	resp, err := c.call("OpenSession", open)
+	if err != nil {
+		return err
+	}
+	session := resp.structField(3)
+	if session == nil {
+		return errors.New("OpenSession returned no session handle")
+	}
+	defer func() {
+		_, closeErr := c.call("CloseSession", thriftFields{1: thriftStructValue(session)})
+		err = cmp.Or(err, closeErr)
+	}()
+
+	resp, err = c.call("ExecuteStatement", thriftFields{
+		1: thriftStructValue(session),
+		2: thriftStringValue(stmt),
+		4: thriftBoolValue(false),
+	})
+	if err != nil {
+		return err
+	}
+	operation := resp.structField(2)
+	if operation == nil {
+		return errors.New("ExecuteStatement returned no operation handle")
+	}
+	defer func() {
+		_, closeErr := c.call("CloseOperation", thriftFields{1: thriftStructValue(operation)})
+		err = cmp.Or(err, closeErr)
+	}()
+
+	// The statement only has to have run to completion once its first row can be fetched
+	if operation.boolField(3) {
+		_, err = c.call("FetchResults", thriftFields{
+			1: thriftStructValue(operation),
+			2: thriftI32Value(0),
+			3: thriftI64Value(1),
+		})
+	}
+	return err
+}
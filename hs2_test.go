@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThriftRoundTrip(t *testing.T) {
+	args := thriftFields{
+		1: thriftStructValue(thriftFields{1: thriftStringValue("guid"), 2: thriftValue{typ: thriftDouble, v: 1.5}}),
+		2: thriftValue{typ: thriftList, elem: [2]byte{thriftI64}, v: []thriftValue{thriftI64Value(-1), thriftI64Value(7)}},
+		3: thriftValue{typ: thriftMap, elem: [2]byte{thriftString, thriftBool}, v: [][2]thriftValue{{thriftStringValue("k"), thriftBoolValue(true)}}},
+		4: thriftValue{typ: thriftI16, v: int16(-3)},
+		5: thriftValue{typ: thriftByte, v: int8(9)},
+	}
+	msg := appendThriftMessage(nil, "Echo", 7, args)
+	// Turn the call into a reply
+	msg[3] = thriftReply
+	r := &thriftReader{r: strings.NewReader(string(msg))}
+	got, err := r.readMessage("Echo", 7)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	if string(appendThriftStruct(nil, got)) != string(appendThriftStruct(nil, args)) {
+		t.Errorf("round trip changed the struct: %v", got)
+	}
+	if got.structField(1).str(1) != "guid" {
+		t.Errorf("nested string = %q, want guid", got.structField(1).str(1))
+	}
+	if _, err := (&thriftReader{r: strings.NewReader(string(msg))}).readMessage("Echo", 8); err == nil {
+		t.Error("readMessage() accepted a reply with another sequence number")
+	}
+}
+
+// fakeHS2 serves the TCLIService calls of the canary query, optionally behind SASL PLAIN, recording the calls. Other
+// methods are answered with an exception, as by a Thrift server that does not know them.
+type fakeHS2 struct {
+	sasl     bool
+	password string
+	failExec bool
+
+	mu    sync.Mutex
+	calls []string
+	stmt  string
+}
+
+func (s *fakeHS2) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if s.sasl {
+		var payloads []string
+		for range 2 {
+			status, _ := r.ReadByte()
+			frame, err := readThriftFrame(r)
+			if err != nil || status == 0 {
+				return
+			}
+			p, _ := io.ReadAll(frame)
+			payloads = append(payloads, string(p))
+		}
+		status := byte(saslComplete)
+		if payloads[0] != "PLAIN" || payloads[1] != "\x00canary\x00"+s.password {
+			status = saslBad
+		}
+		conn.Write(appendSASLMessage(nil, status, []byte("bad credentials")))
+		if status != saslComplete {
+			return
+		}
+	}
+	for {
+		var in io.Reader = r
+		if s.sasl {
+			frame, err := readThriftFrame(r)
+			if err != nil {
+				return
+			}
+			in = frame
+		}
+		tr := &thriftReader{r: in}
+		if _, err := tr.readI32(); err != nil {
+			return
+		}
+		name, _ := tr.readBytes()
+		seq, _ := tr.readI32()
+		args, err := tr.readStruct()
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			return
+		}
+		req := args.structField(1)
+		s.mu.Lock()
+		s.calls = append(s.calls, string(name))
+		s.mu.Unlock()
+
+		status := thriftFields{1: thriftI32Value(hs2StatusSuccess)}
+		resp := thriftFields{1: thriftStructValue(status)}
+		handle := thriftFields{1: thriftStructValue(thriftFields{1: thriftStringValue("guid"), 2: thriftStringValue("secret")})}
+		unknown := false
+		switch string(name) {
+		case "FetchResults", "CloseOperation", "CloseSession":
+		case "OpenSession":
+			resp[3] = thriftStructValue(handle)
+		case "ExecuteStatement":
+			s.mu.Lock()
+			s.stmt = req.str(2)
+			s.mu.Unlock()
+			if req.structField(1).structField(1).str(2) != "secret" {
+				t.Errorf("ExecuteStatement got session %v", req.structField(1))
+			}
+			if s.failExec {
+				resp[1] = thriftStructValue(thriftFields{1: thriftI32Value(3), 5: thriftStringValue("AnalysisException: boom")})
+			} else {
+				operation := thriftFields{1: handle[1], 2: thriftI32Value(0), 3: thriftBoolValue(true)}
+				resp[2] = thriftStructValue(operation)
+			}
+		default:
+			unknown = true
+		}
+		out := appendThriftMessage(nil, string(name), seq, thriftFields{0: thriftStructValue(resp)})
+		binary.BigEndian.PutUint32(out, thriftVersion1|thriftReply)
+		if unknown {
+			out = appendThriftMessage(nil, string(name), seq, thriftFields{1: thriftStringValue("Invalid method name: '" + string(name) + "'")})
+			binary.BigEndian.PutUint32(out, thriftVersion1|thriftException)
+		}
+		if s.sasl {
+			out = append(binary.BigEndian.AppendUint32(nil, uint32(len(out))), out...)
+		}
+		conn.Write(out)
+	}
+}
+
+func (s *fakeHS2) start(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(t, conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestRunCanaryQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   *fakeHS2
+		auth     hs2Auth
+		wantErr  string
+		wantCall string
+	}{
+		{"nosasl", &fakeHS2{}, hs2Auth{}, "", "OpenSession,ExecuteStatement,FetchResults,CloseOperation,CloseSession"},
+		{"plain", &fakeHS2{sasl: true, password: "pw"}, hs2Auth{mechanism: "PLAIN", user: "canary", password: "pw"}, "", "OpenSession,ExecuteStatement,FetchResults,CloseOperation,CloseSession"},
+		{"bad password", &fakeHS2{sasl: true, password: "pw"}, hs2Auth{mechanism: "PLAIN", user: "canary", password: "wrong"}, "bad credentials", ""},
+		{"query error", &fakeHS2{failExec: true}, hs2Auth{}, "AnalysisException: boom", "OpenSession,ExecuteStatement,CloseSession"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := tt.server.start(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := runCanaryQuery(ctx, address, canaryConfig{query: "select 1", auth: tt.auth})
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("runCanaryQuery() error = %v, want %q", err, tt.wantErr)
+			}
+			tt.server.mu.Lock()
+			defer tt.server.mu.Unlock()
+			if got := strings.Join(tt.server.calls, ","); got != tt.wantCall {
+				t.Errorf("calls = %s, want %s", got, tt.wantCall)
+			}
+			if tt.wantCall != "" && tt.server.stmt != "select 1" {
+				t.Errorf("statement = %q, want select 1", tt.server.stmt)
+			}
+		})
+	}
+}
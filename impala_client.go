@@ -0,0 +1,230 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// impalaTransportConfig configures how the Impala web UIs are reached
+type impalaTransportConfig struct {
+	// proxyURL is a forward proxy every request goes through; when empty the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables apply
+	proxyURL string
+	// certFile and keyFile hold the client certificate presented to web UIs requiring mutual TLS
+	certFile string
+	keyFile  string
+	// caFile holds the CA certificates the web UI certificates are verified against, instead of the system ones
+	caFile string
+}
+
+// newImpalaTransport returns the transport of the requests to the Impala web UIs
+func newImpalaTransport(config impalaTransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.proxyURL != "" {
+		u, err := url.Parse(config.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return nil, fmt.Errorf("invalid proxy URL %q, want http://, https:// or socks5://host:port", u.Redacted())
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if (config.certFile == "") != (config.keyFile == "") {
+		return nil, errors.New("a client certificate needs both a certificate and a key file")
+	}
+	if config.certFile != "" {
+		if _, err := tls.LoadX509KeyPair(config.certFile, config.keyFile); err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		// Loaded for every handshake, so that a renewed certificate is picked up without a restart
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(config.certFile, config.keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	if config.caFile != "" {
+		pem, err := os.ReadFile(config.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", config.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// impalaServerConfig holds the request settings of an Impala server
+type impalaServerConfig struct {
+	// Headers are added to every request, e.g. the token of an authenticating reverse proxy
+	Headers map[string]secret `yaml:"headers"`
+	// Scheme is http or https, http when empty
+	Scheme string `yaml:"scheme"`
+	// BasePath prefixes the path of every request, e.g. /gateway/cdp-proxy/impalad for a server behind Apache Knox
+	BasePath string `yaml:"base_path"`
+	// BasicAuth is sent until the server sets a session cookie, and again once the session is rejected
+	BasicAuth *basicAuth `yaml:"basic_auth"`
+}
+
+// basicAuth holds HTTP basic authentication credentials
+type basicAuth struct {
+	Username string `yaml:"username"`
+	Password secret `yaml:"password"`
+}
+
+// impalaClientConfig is the -impala.client-config file: the settings of every server, and per server address the
+// settings overriding them. Headers are merged, those of a server overriding the common ones of the same name.
+type impalaClientConfig struct {
+	impalaServerConfig `yaml:",inline"`
+	Servers            map[string]impalaServerConfig `yaml:"servers"`
+}
+
+// clientConfig holds the request settings of the Impala servers, loaded from -impala.client-config
+var clientConfig impalaClientConfig
+
+// loadImpalaClientConfig reads and validates an -impala.client-config file
+func loadImpalaClientConfig(path string) (impalaClientConfig, error) {
+	var config impalaClientConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := config.impalaServerConfig.check(); err != nil {
+		return config, err
+	}
+	for address, server := range config.Servers {
+		if err := server.check(); err != nil {
+			return config, fmt.Errorf("server %s: %w", address, err)
+		}
+		config.Servers[address] = config.impalaServerConfig.merge(server)
+	}
+	return config, nil
+}
+
+// check reports an error for a setting that cannot be used, and normalizes BasePath
+func (s *impalaServerConfig) check() error {
+	if err := checkHeaders(s.Headers); err != nil {
+		return err
+	}
+	if s.Scheme != "" && s.Scheme != "http" && s.Scheme != "https" {
+		return fmt.Errorf("invalid scheme %q, want http or https", s.Scheme)
+	}
+	if s.BasePath != "" && !strings.HasPrefix(s.BasePath, "/") {
+		return fmt.Errorf("invalid base path %q, want an absolute path", s.BasePath)
+	}
+	s.BasePath = strings.TrimRight(s.BasePath, "/")
+	return nil
+}
+
+// merge returns the settings of s overridden by the settings set in over, headers merged by name
+func (s impalaServerConfig) merge(over impalaServerConfig) impalaServerConfig {
+	headers := maps.Clone(s.Headers)
+	if headers == nil {
+		headers = make(map[string]secret)
+	}
+	maps.Copy(headers, over.Headers)
+	s.Headers = headers
+	s.Scheme = cmp.Or(over.Scheme, s.Scheme)
+	s.BasePath = cmp.Or(over.BasePath, s.BasePath)
+	if over.BasicAuth != nil {
+		s.BasicAuth = over.BasicAuth
+	}
+	return s
+}
+
+// checkHeaders reports an error for a header name that cannot be sent
+func checkHeaders(headers map[string]secret) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(string(value), "\r\n") {
+			return fmt.Errorf("invalid value of header %s", name)
+		}
+	}
+	return nil
+}
+
+// server returns the settings of the server at address
+func (c impalaClientConfig) server(address string) impalaServerConfig {
+	if server, ok := c.Servers[address]; ok {
+		return server
+	}
+	return c.impalaServerConfig
+}
+
+// url returns the URL of path on the web UI at address
+func (s impalaServerConfig) url(address, path string) string {
+	return cmp.Or(s.Scheme, "http") + "://" + address + s.BasePath + path
+}
+
+// apply adds the configured headers to req, along with the basic authentication credentials when authenticate is
+// set; a Host header sets the host requested
+func (s impalaServerConfig) apply(req *http.Request, authenticate bool) {
+	for name, value := range s.Headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = string(value)
+			continue
+		}
+		req.Header.Set(name, string(value))
+	}
+	if authenticate && s.BasicAuth != nil {
+		req.SetBasicAuth(s.BasicAuth.Username, string(s.BasicAuth.Password))
+	}
+}
+
+// requestImpala requests path from the Impala web UI at address with the settings of the server.
+// Credentials are only sent while the cookie jar holds no cookie for the URL, so that a gateway such as Knox
+// authenticates the exporter once per session rather than on every request; a rejected session is authenticated
+// anew.
+func requestImpala(ctx context.Context, address, path string) (*http.Response, error) {
+	server := clientConfig.server(address)
+	u := server.url(address, path)
+	authenticate := !hasSession(u)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		server.apply(req, authenticate)
+		resp, err := httpClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || authenticate || server.BasicAuth == nil {
+			return resp, err
+		}
+		resp.Body.Close()
+		authenticate = true
+	}
+}
+
+// hasSession reports whether the cookie jar of httpClient holds cookies for rawURL
+func hasSession(rawURL string) bool {
+	if httpClient.Jar == nil {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && len(httpClient.Jar.Cookies(u)) > 0
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImpalaTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`{"version": "impalad version 4.1.0-RELEASE RELEASE (build 0a1b2c3d)"}`))
+	}))
+	defer proxy.Close()
+
+	transport, err := newImpalaTransport(impalaTransportConfig{proxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("newImpalaTransport() error = %v", err)
+	}
+	defer func(rt http.RoundTripper) { httpClient.Transport = rt }(httpClient.Transport)
+	httpClient.Transport = transport
+
+	version, _, err := fetchBuildInfo(context.Background(), "coordinator.invalid:25000")
+	if err != nil {
+		t.Fatalf("fetchBuildInfo() error = %v", err)
+	}
+	if version != "4.1.0-RELEASE" || proxied != "http://coordinator.invalid:25000/?json" {
+		t.Errorf("got version %q through the proxy requesting %q", version, proxied)
+	}
+}
+
+func TestImpalaTransportInvalidProxy(t *testing.T) {
+	for _, proxyURL := range []string{"proxy:3128", "ftp://proxy:21", "http://%zz"} {
+		if _, err := newImpalaTransport(impalaTransportConfig{proxyURL: proxyURL}); err == nil {
+			t.Errorf("newImpalaTransport(%q) error = nil, want an error", proxyURL)
+		}
+	}
+}
+
+func TestLoadImpalaClientConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.yml")
+	os.WriteFile(path, []byte(`
+headers:
+  X-Auth-Token: common
+  X-Team: analytics
+servers:
+  "coord-1:25000":
+    headers:
+      X-Auth-Token: coord-1
+      Host: impala.example.com
+`), 0o600)
+	config, err := loadImpalaClientConfig(path)
+	if err != nil {
+		t.Fatalf("loadImpalaClientConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		address  string
+		want     http.Header
+		wantHost string
+	}{
+		{"coord-1:25000", http.Header{"X-Auth-Token": {"coord-1"}, "X-Team": {"analytics"}}, "impala.example.com"},
+		{"coord-2:25000", http.Header{"X-Auth-Token": {"common"}, "X-Team": {"analytics"}}, "coord-2:25000"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.address+"/", nil)
+		req.Header = http.Header{}
+		config.server(tt.address).apply(req, true)
+		if !reflect.DeepEqual(req.Header, tt.want) || req.Host != tt.wantHost {
+			t.Errorf("request to %s has headers %v and host %q, want %v and %q", tt.address, req.Header, req.Host, tt.want, tt.wantHost)
+		}
+	}
+}
+
+func TestLoadImpalaClientConfigErrors(t *testing.T) {
+	for _, content := range []string{
+		"headers:\n  \"Bad Name\": x\n",
+		"servers:\n  \"coord:25000\":\n    headers:\n      \"X-Token\": \"a\\nb\"\n",
+		"unknown: true\n",
+		"scheme: ftp\n",
+		"servers:\n  \"coord:25000\":\n    base_path: gateway/impalad\n",
+	} {
+		path := filepath.Join(t.TempDir(), "client.yml")
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := loadImpalaClientConfig(path); err == nil {
+			t.Errorf("loadImpalaClientConfig(%q) error = nil, want an error", content)
+		}
+	}
+}
+
+func TestFetchJSONSendsConfiguredHeaders(t *testing.T) {
+	var got string
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+
+	address := strings.TrimPrefix(impala.URL, "http://")
+	defer func(c impalaClientConfig) { clientConfig = c }(clientConfig)
+	clientConfig = impalaClientConfig{Servers: map[string]impalaServerConfig{address: {Headers: map[string]secret{"Authorization": "Bearer secret"}}}}
+
+	var root RootResponse
+	if err := fetchJSON(context.Background(), address, "/?json", &root); err != nil {
+		t.Fatalf("fetchJSON() error = %v", err)
+	}
+	if got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestRequestImpalaThroughKnox(t *testing.T) {
+	var logins, requests int
+	session := "s1"
+	knox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/gateway/cdp-proxy/impalad/" {
+			http.NotFound(w, r)
+			return
+		}
+		if cookie, err := r.Cookie("hadoop-jwt"); err == nil && cookie.Value == session {
+			if _, _, ok := r.BasicAuth(); ok {
+				t.Errorf("credentials sent along with a valid session cookie")
+			}
+			w.Write([]byte(`{"version": "impalad version 4.1.0 (build 0a1b)"}`))
+			return
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "exporter" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		logins++
+		http.SetCookie(w, &http.Cookie{Name: "hadoop-jwt", Value: session, Path: "/"})
+		w.Write([]byte(`{"version": "impalad version 4.1.0 (build 0a1b)"}`))
+	}))
+	defer knox.Close()
+
+	address := strings.TrimPrefix(knox.URL, "http://")
+	defer func(c impalaClientConfig, jar http.CookieJar) { clientConfig, httpClient.Jar = c, jar }(clientConfig, httpClient.Jar)
+	clientConfig = impalaClientConfig{impalaServerConfig: impalaServerConfig{
+		BasePath:  "/gateway/cdp-proxy/impalad",
+		BasicAuth: &basicAuth{Username: "exporter", Password: "secret"},
+	}}
+	httpClient.Jar, _ = cookiejar.New(nil)
+
+	for range 3 {
+		if _, _, err := fetchBuildInfo(context.Background(), address); err != nil {
+			t.Fatalf("fetchBuildInfo() error = %v", err)
+		}
+	}
+	if logins != 1 || requests != 3 {
+		t.Errorf("got %d logins in %d requests, want 1 login in 3 requests", logins, requests)
+	}
+
+	// An expired session is authenticated anew
+	session = "s2"
+	if _, _, err := fetchBuildInfo(context.Background(), address); err != nil {
+		t.Fatalf("fetchBuildInfo() with an expired session error = %v", err)
+	}
+	if logins != 2 {
+		t.Errorf("got %d logins after the session expired, want 2", logins)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key as PEM files to dir
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "impala-exporter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return cert, certFile, keyFile
+}
+
+func TestImpalaTransportClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	impala := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "impalad version 4.1.0 (build 0a1b)"}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	impala.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	impala.StartTLS()
+	defer impala.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: impala.Certificate().Raw}), 0o600)
+
+	address := strings.TrimPrefix(impala.URL, "https://")
+	defer func(c impalaClientConfig, rt http.RoundTripper) { clientConfig, httpClient.Transport = c, rt }(clientConfig, httpClient.Transport)
+	clientConfig = impalaClientConfig{impalaServerConfig: impalaServerConfig{Scheme: "https"}}
+
+	withoutCert, err := newImpalaTransport(impalaTransportConfig{caFile: caFile})
+	if err != nil {
+		t.Fatalf("newImpalaTransport() error = %v", err)
+	}
+	httpClient.Transport = withoutCert
+	if _, _, err := fetchBuildInfo(context.Background(), address); err == nil {
+		t.Error("fetchBuildInfo() without a client certificate succeeded, want a handshake error")
+	}
+
+	withCert, err := newImpalaTransport(impalaTransportConfig{certFile: certFile, keyFile: keyFile, caFile: caFile})
+	if err != nil {
+		t.Fatalf("newImpalaTransport() error = %v", err)
+	}
+	httpClient.Transport = withCert
+	if version, _, err := fetchBuildInfo(context.Background(), address); err != nil || version != "4.1.0" {
+		t.Errorf("fetchBuildInfo() = %q, %v, want 4.1.0", version, err)
+	}
+}
+
+func TestImpalaTransportInvalidTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, keyFile := writeClientCert(t, dir)
+	for _, config := range []impalaTransportConfig{
+		{certFile: certFile},
+		{certFile: keyFile, keyFile: certFile},
+		{caFile: keyFile},
+		{caFile: filepath.Join(dir, "missing.crt")},
+	} {
+		if _, err := newImpalaTransport(config); err == nil {
+			t.Errorf("newImpalaTransport(%+v) error = nil, want an error", config)
+		}
+	}
+}
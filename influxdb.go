@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const influxDBTokenEnv = "IMPALA_EXPORTER_INFLUXDB_TOKEN"
+
+var (
+	influxDBURLFlag       = flag.String("influxdb.url", "", "Base URL of an InfluxDB server, e.g. http://influxdb:8086, the metrics are written to in line protocol every -sink.interval; enables the influxdb sink")
+	influxDBDatabaseFlag  = flag.String("influxdb.database", "", "InfluxDB 1.x database the metrics are written to through /write")
+	influxDBOrgFlag       = flag.String("influxdb.org", "", "InfluxDB 2.x organization of -influxdb.bucket")
+	influxDBBucketFlag    = flag.String("influxdb.bucket", "", "InfluxDB 2.x bucket the metrics are written to through /api/v2/write, instead of -influxdb.database")
+	influxDBUsernameFlag  = flag.String("influxdb.username", "", "InfluxDB 1.x user the metrics are written as, with the token as password")
+	influxDBTokenFileFlag = flag.String("influxdb.token-file", "", "Path of a file holding the InfluxDB 2.x API token, or the InfluxDB 1.x password of -influxdb.username; when unset it is read from $"+influxDBTokenEnv)
+	influxDBTimeoutFlag   = flag.Duration("influxdb.timeout", 30*time.Second, "Timeout of a write to -influxdb.url")
+)
+
+func init() {
+	RegisterSink("influxdb", func() (Sink, error) {
+		if *influxDBURLFlag == "" {
+			return nil, nil
+		}
+		base, err := url.Parse(*influxDBURLFlag)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			return nil, fmt.Errorf("invalid URL %q", *influxDBURLFlag)
+		}
+		token, _, err := readSecret(*influxDBTokenFileFlag, influxDBTokenEnv)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		s := &influxDBSink{client: &http.Client{Timeout: *influxDBTimeoutFlag}}
+		query := url.Values{"precision": {"ms"}}
+		switch {
+		case *influxDBBucketFlag != "":
+			if *influxDBOrgFlag == "" {
+				return nil, fmt.Errorf("-influxdb.bucket requires -influxdb.org")
+			}
+			base = base.JoinPath("/api/v2/write")
+			query.Set("org", *influxDBOrgFlag)
+			query.Set("bucket", *influxDBBucketFlag)
+			if token != "" {
+				s.authorization = "Token " + token
+			}
+		case *influxDBDatabaseFlag != "":
+			base = base.JoinPath("/write")
+			query.Set("db", *influxDBDatabaseFlag)
+			if *influxDBUsernameFlag != "" {
+				s.username, s.password = *influxDBUsernameFlag, token
+			}
+		default:
+			return nil, fmt.Errorf("-influxdb.url requires -influxdb.database or -influxdb.bucket")
+		}
+		base.RawQuery = query.Encode()
+		s.url = base.String()
+		return s, nil
+	})
+}
+
+// influxDBSink writes every metrics snapshot to InfluxDB 1.x or 2.x in line protocol, one measurement per metric
+// name with the labels as tags and the sample as its value field
+type influxDBSink struct {
+	// url is the write endpoint, its query selecting the database or bucket and the precision
+	url string
+	// authorization is the Authorization header of InfluxDB 2.x
+	authorization string
+	// username and password authenticate to InfluxDB 1.x
+	username string
+	password string
+	client   *http.Client
+}
+
+func (s *influxDBSink) Name() string {
+	return "influxdb"
+}
+
+func (s *influxDBSink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit writes the metrics of a snapshot event, skipping the other events. A snapshot InfluxDB rejects is dropped
+// rather than retried, the next one supersedes it.
+func (s *influxDBSink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 {
+		return nil
+	}
+	body := encodeLineProtocol(familySamples(event.Metrics, event.Time))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB responded with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (s *influxDBSink) Close() error {
+	return nil
+}
+
+var (
+	// lineMeasurementEscaper escapes a measurement name of the line protocol
+	lineMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	// lineTagEscaper escapes a tag key or value of the line protocol
+	lineTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// encodeLineProtocol renders samples as InfluxDB line protocol with millisecond timestamps:
+//
+//	impala_up,impala_server=coord:25000 value=1 1700000000000
+//
+// Tags with an empty value and samples that are not finite are left out, as InfluxDB rejects them.
+func encodeLineProtocol(samples []sinkSample) []byte {
+	var b bytes.Buffer
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		b.WriteString(lineMeasurementEscaper.Replace(sample.Name))
+		for _, l := range sample.Labels {
+			if l.GetValue() == "" {
+				continue
+			}
+			b.WriteString("," + lineTagEscaper.Replace(l.GetName()) + "=" + lineTagEscaper.Replace(l.GetValue()))
+		}
+		b.WriteString(" value=" + strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		b.WriteString(" " + strconv.FormatInt(sample.Time.UnixMilli(), 10) + "\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestEncodeLineProtocol(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	samples := []sinkSample{
+		{Name: "impala_up", Labels: []*dto.LabelPair{labelPair("impala_server", "coord:25000"), labelPair("pool", "")}, Value: 1, Time: now},
+		{Name: "impala_queries", Labels: []*dto.LabelPair{labelPair("user", "etl user,a=b")}, Value: 2.5, Time: now},
+		{Name: "impala_ratio", Value: math.NaN(), Time: now},
+	}
+	want := "impala_up,impala_server=coord:25000 value=1 1700000000000\n" +
+		`impala_queries,user=etl\ user\,a\=b value=2.5 1700000000000` + "\n"
+	if got := string(encodeLineProtocol(samples)); got != want {
+		t.Errorf("encodeLineProtocol() = %q, want %q", got, want)
+	}
+}
+
+func TestInfluxDBSink(t *testing.T) {
+	var path, query, authorization string
+	var body []byte
+	influxdb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query, authorization = r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influxdb.Close()
+
+	*influxDBURLFlag, *influxDBOrgFlag, *influxDBBucketFlag = influxdb.URL, "ops", "impala"
+	t.Setenv(influxDBTokenEnv, "secret")
+	defer func() { *influxDBURLFlag, *influxDBOrgFlag, *influxDBBucketFlag = "", "", "" }()
+	sink, err := sinkFactories["influxdb"]()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGauge(prometheus.GaugeOpts{Name: "impala_up"})
+	up.Set(1)
+	registry.MustRegister(up)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Emit(context.Background(), Event{Time: time.UnixMilli(1700000000000), Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if path != "/api/v2/write" || query != "bucket=impala&org=ops&precision=ms" || authorization != "Token secret" {
+		t.Errorf("wrote to %s?%s with %q, want the bucket write endpoint with the token", path, query, authorization)
+	}
+	if want := "impala_up value=1 1700000000000\n"; string(body) != want {
+		t.Errorf("wrote %q, want %q", body, want)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// labelFlags collects the -labels name=value,name=value flag
+type labelFlags map[string]string
+
+func (l *labelFlags) String() string {
+	parts := make([]string, 0, len(*l))
+	for _, name := range slices.Sorted(maps.Keys(*l)) {
+		parts = append(parts, name+"="+(*l)[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *labelFlags) Set(value string) error {
+	if *l == nil {
+		*l = make(labelFlags)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		name, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=value, got %q", pair)
+		}
+		name = strings.TrimSpace(name)
+		if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := (*l)[name]; ok {
+			return fmt.Errorf("duplicate label %q", name)
+		}
+		(*l)[name] = strings.TrimSpace(val)
+	}
+	return nil
+}
+
+// labelingGatherer adds constant labels to every series of the wrapped Gatherer
+type labelingGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+// withLabels wraps a Gatherer so that every gathered series carries the given labels.
+// A series that already has one of the labels keeps its own value, so e.g. the cluster label of
+// impala_target_info is not overwritten by a cluster label passed with -labels.
+func withLabels(gatherer prometheus.Gatherer, labels map[string]string) prometheus.Gatherer {
+	if len(labels) == 0 {
+		return gatherer
+	}
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return &labelingGatherer{gatherer: gatherer, labels: pairs}
+}
+
+func (g *labelingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			for _, pair := range g.labels {
+				if !slices.ContainsFunc(metric.Label, func(l *dto.LabelPair) bool { return l.GetName() == pair.GetName() }) {
+					metric.Label = append(metric.Label, pair)
+				}
+			}
+			sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+		}
+	}
+	return families, err
+}
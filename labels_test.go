@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLabelFlagsSet(t *testing.T) {
+	var labels labelFlags
+	if err := labels.Set("cluster=prod, dc=eu1"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	want := labelFlags{"cluster": "prod", "dc": "eu1"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+	if got := labels.String(); got != "cluster=prod,dc=eu1" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestLabelFlagsSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing equals", "cluster"},
+		{"invalid name", "1dc=eu1"},
+		{"reserved name", "__name__=x"},
+		{"duplicate", "dc=eu1,dc=eu2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels labelFlags
+			if err := labels.Set(tt.input); err == nil {
+				t.Errorf("Set(%q) succeeded, want error", tt.input)
+			}
+		})
+	}
+}
+
+func TestWithLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	info := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "info", Help: "info"}, []string{"cluster"})
+	info.WithLabelValues("own").Set(1)
+	up := prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "up"})
+	reg.MustRegister(info, up)
+
+	families, err := withLabels(reg, map[string]string{"cluster": "prod", "dc": "eu1"}).Gather()
+	if err != nil {
+		t.Fatalf("Gather returned error: %v", err)
+	}
+	want := map[string]map[string]string{
+		"info": {"cluster": "own", "dc": "eu1"},
+		"up":   {"cluster": "prod", "dc": "eu1"},
+	}
+	for _, family := range families {
+		got := make(map[string]string)
+		for _, pair := range family.Metric[0].Label {
+			got[pair.GetName()] = pair.GetValue()
+		}
+		if !reflect.DeepEqual(got, want[family.GetName()]) {
+			t.Errorf("%s labels = %v, want %v", family.GetName(), got, want[family.GetName()])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Impala Exporter</title></head>
+<body>
+<h1>Impala Exporter</h1>
+<p>{{.Version}}</p>
+<h2>Metrics</h2>
+<ul>
+<li><a href="/metrics">/metrics</a></li>
+{{- range .Clusters}}
+<li><a href="/metrics/{{.Name}}">/metrics/{{.Name}}</a> ({{len .Servers}} servers)</li>
+{{- end}}
+</ul>
+<h2>API</h2>
+<ul>
+<li><a href="/api/v1/capacity">/api/v1/capacity</a> (cluster capacity report)</li>
+<li><a href="/api/v1/targets">/api/v1/targets</a> (targets and their last scrape)</li>
+<li><a href="/api/v1/status">/api/v1/status</a> (exporter status)</li>
+</ul>
+<h2>Targets</h2>
+<ul>
+{{- range .Targets}}
+<li>{{.}}</li>
+{{- else}}
+<li>No targets configured</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// landingPage is the data rendered by landingTemplate
+type landingPage struct {
+	Version  string
+	Clusters []Cluster
+	Targets  []string
+}
+
+// landingHandler serves a small HTML page linking the metrics paths and listing the configured targets
+func landingHandler(exporter *Exporter, clusters []Cluster) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := landingPage{
+			Version:  versionString(),
+			Clusters: clusters,
+			Targets:  exporter.Servers(),
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := landingTemplate.Execute(w, page); err != nil {
+			slog.Error("Error rendering landing page", "err", err)
+		}
+	})
+}
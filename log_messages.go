@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LogsResponse represents the structure of the JSON response from Impala for /logs, the tail of the INFO log of a
+// daemon, which holds the messages of every level
+type LogsResponse struct {
+	LogDir string `json:"logdir"`
+	Log    string `json:"log"`
+}
+
+// glogLineRe matches the glog header of a log message, e.g. "E1016 03:35:02.123456 12345 impala-server.cc:123]",
+// whose first letter is its level; continuation lines of a multi-line message have none
+var glogLineRe = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}\.\d+ `)
+
+// logLevels are the glog levels by the letter starting their messages
+var logLevels = map[string]string{"I": "INFO", "W": "WARNING", "E": "ERROR", "F": "FATAL"}
+
+// logCounts is the number of messages of each level seen in the logs of a server, and the last message counted
+type logCounts struct {
+	last   string
+	counts map[string]float64
+}
+
+// logCountsTracker holds the log message counts of every server across scrapes
+type logCountsTracker struct {
+	mu      sync.Mutex
+	servers map[string]*logCounts
+}
+
+// count accounts for the messages of the log tail of server not counted by a previous scrape, those following the
+// last message counted. When that message has left the tail, e.g. after a restart or a burst of logging, the whole
+// tail is counted; messages logged in between are missed. Everything is counted on the first scrape.
+func (t *logCountsTracker) count(server, tail string) map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.servers == nil {
+		t.servers = make(map[string]*logCounts)
+	}
+	counts, ok := t.servers[server]
+	if !ok {
+		counts = &logCounts{counts: make(map[string]float64, len(logLevels))}
+		for _, level := range logLevels {
+			counts.counts[level] = 0
+		}
+		t.servers[server] = counts
+	}
+
+	var messages []string
+	for _, line := range strings.Split(tail, "\n") {
+		if glogLineRe.MatchString(line) {
+			messages = append(messages, line)
+		}
+	}
+	start := 0
+	for i := len(messages) - 1; i >= 0 && counts.last != ""; i-- {
+		if messages[i] == counts.last {
+			start = i + 1
+			break
+		}
+	}
+	for _, message := range messages[start:] {
+		counts.counts[logLevels[message[:1]]]++
+	}
+	if len(messages) > 0 {
+		counts.last = messages[len(messages)-1]
+	}
+	return maps.Clone(counts.counts)
+}
+
+// logMessagesCollector counts the messages of each level in the logs of a daemon, so that a surge of errors can be
+// alerted on without shipping the logs
+type logMessagesCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_log_messages_total over to the provided channel
+func (c logMessagesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.logMessages
+}
+
+// Collect fetches the log tail of a daemon and sends the number of messages of each level logged so far over to the
+// provided channel
+func (c logMessagesCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var resp LogsResponse
+	if err := fetchJSON(ctx, target.Address, "/logs?json", &resp); err != nil {
+		return err
+	}
+	for level, count := range e.logCounts.count(target.Name, resp.Log) {
+		ch <- prometheus.MustNewConstMetric(e.logMessages, prometheus.CounterValue, count, target.Name, level)
+	}
+	return nil
+}
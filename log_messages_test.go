@@ -0,0 +1,37 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestLogCountsTracker(t *testing.T) {
+	var tracker logCountsTracker
+	tail := `I1016 03:35:01.000001 100 impala-server.cc:10] Starting
+W1016 03:35:02.000002 100 impala-server.cc:20] Slow RPC
+E1016 03:35:03.000003 100 impala-server.cc:30] Query failed:
+  continuation of the error
+`
+	got := tracker.count("impalad", tail)
+	want := map[string]float64{"INFO": 1, "WARNING": 1, "ERROR": 1, "FATAL": 0}
+	if !maps.Equal(got, want) {
+		t.Errorf("first count = %v, want %v", got, want)
+	}
+
+	// Only the messages following the last one counted are added
+	tail += `E1016 03:35:04.000004 100 impala-server.cc:30] Query failed
+I1016 03:35:05.000005 100 impala-server.cc:10] Done
+`
+	got = tracker.count("impalad", tail[len("I1016 03:35:01.000001 100 impala-server.cc:10] Starting\n"):])
+	want = map[string]float64{"INFO": 2, "WARNING": 1, "ERROR": 2, "FATAL": 0}
+	if !maps.Equal(got, want) {
+		t.Errorf("second count = %v, want %v", got, want)
+	}
+
+	// A tail without the last message counted, e.g. after a restart, is counted as a whole
+	got = tracker.count("impalad", "F1016 04:00:00.000000 200 impalad-main.cc:1] Check failed\n")
+	want = map[string]float64{"INFO": 2, "WARNING": 1, "ERROR": 2, "FATAL": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("count after restart = %v, want %v", got, want)
+	}
+}
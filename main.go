@@ -1,20 +1,122 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/promslog"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
+var progressRe = regexp.MustCompile(`(\d+)\s*/\s*(\d+)`)
+
+// durationRe matches one component of an Impala human-readable duration; ms must be tried before m
+var durationRe = regexp.MustCompile(`(\d+(?:\.\d+)?)(d|h|ms|us|ns|m|s)`)
+
+// durationUnits maps the unit of a duration component to seconds
+var durationUnits = map[string]float64{
+	"d":  86400,
+	"h":  3600,
+	"m":  60,
+	"s":  1,
+	"ms": 1e-3,
+	"us": 1e-6,
+	"ns": 1e-9,
+}
+
+// defaultNamespace is the prefix of the Impala metric names unless overridden with -metric.namespace
+const defaultNamespace = "impala"
+
+// slowQueryThresholds are the durations, in seconds, above which in-flight queries are counted as slow,
+// with the matching threshold label value of impala_slow_queries
+var slowQueryThresholds = []struct {
+	seconds int
+	label   string
+}{
+	{10, "10s"},
+	{30, "30s"},
+	{60, "1m"},
+	{120, "2m"},
+	{180, "3m"},
+	{300, "5m"},
+	{600, "10m"},
+}
+
+// httpClient is used for every request to the Impala web UI, so that a hung endpoint cannot stall a scrape
+var httpClient = &http.Client{Timeout: 3 * time.Second}
+
+// fetchJSON requests path from the Impala web UI at address and decodes the JSON response into v,
+// retrying transient failures
+func fetchJSON(ctx context.Context, address, path string, v any) error {
+	return fetchDecode(ctx, address, path, func(r io.Reader) error {
+		// Drop whatever a failed attempt decoded, so that maps are not merged across attempts
+		reflect.ValueOf(v).Elem().SetZero()
+		return json.NewDecoder(r).Decode(v)
+	})
+}
+
+// fetchDecode requests path from the Impala web UI at address and hands the response body over to decode,
+// retrying transient failures. Each attempt calls decode anew, which must then start over.
+func fetchDecode(ctx context.Context, address, path string, decode func(io.Reader) error) error {
+	return fetchWithRetries(ctx, path, func() error {
+		return fetchOnce(ctx, address, path, decode)
+	})
+}
+
+// fetchOnce requests path from the Impala web UI at address and hands the response body over to decode
+func fetchOnce(ctx context.Context, address, path string, decode func(io.Reader) error) error {
+	resp, err := requestImpala(ctx, address, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := checkResponse(resp, path)
+	if err != nil {
+		return err
+	}
+	if responseCapture != nil {
+		// The body is captured as read, drained after a decoder that stopped early so that it is captured whole
+		var captured bytes.Buffer
+		tee := io.TeeReader(limitBody(body, maxResponseBytes), &captured)
+		err := decode(tee)
+		io.Copy(io.Discard, tee)
+		responseCapture.save(address, path, captured.Bytes())
+		if err != nil {
+			return fmt.Errorf("decoding %s JSON: %w", path, err)
+		}
+		return nil
+	}
+	if err := decode(limitBody(body, maxResponseBytes)); err != nil {
+		return fmt.Errorf("decoding %s JSON: %w", path, err)
+	}
+	return nil
+}
+
 // ImpalaClientHost represents the structure of each client host in the JSON response
 type ImpalaClientHost struct {
 	Hostname              string `json:"hostname"`
@@ -28,218 +130,1738 @@ type ImpalaClientHost struct {
 
 // InFlightQuery represents a single in-flight query
 type InFlightQuery struct {
-	Duration string `json:"duration"`
+	QueryID       string `json:"query_id"`
+	EffectiveUser string `json:"effective_user"`
+	ResourcePool  string `json:"resource_pool"`
+	State         string `json:"state"`
+	Stmt          string `json:"stmt"`
+	Duration      string `json:"duration"`
+	Progress      string `json:"progress"`
+	// Waiting is set once the query finished executing, until its client closes it
+	Waiting bool `json:"waiting"`
 }
 
 // ImpalaSessionsResponse represents the structure of the JSON response from Impala
 type ImpalaSessionsResponse struct {
 	ClientHosts []ImpalaClientHost `json:"client_hosts"`
+	Sessions    []ImpalaSession    `json:"sessions"`
 }
 
-// QueriesResponse represents the structure of the JSON response from Impala for in-flight queries
-type QueriesResponse struct {
-	InFlightQueries []InFlightQuery `json:"in_flight_queries"`
+// ExporterOptions holds the tunables of an Exporter
+type ExporterOptions struct {
+	// StuckProgressPercent is the scan progress below which a long-running query is considered stuck
+	StuckProgressPercent float64
+	// StuckMinDuration is how long a query must have been running before it can be considered stuck
+	StuckMinDuration time.Duration
+	// ScrubLiterals replaces string and numeric literals in captured query statements with ?
+	ScrubLiterals bool
+	// SlowQueryLog, when set, logs the in-flight queries crossing its threshold
+	SlowQueryLog *slowQueryLog
+	// ClientPorts is which client ports the ports collector checks
+	ClientPorts clientPortsConfig
+	// Canary is how the canary collector reaches the coordinators and what it runs
+	Canary canaryConfig
+	// QueryEventThreshold is the running time after which an in-flight query is published as an event, see
+	// SetEventPublisher; 0 publishes none
+	QueryEventThreshold time.Duration
+	// ScrapeTimeout bounds a whole Collect, 0 for no bound; servers that have not answered by then are left out of the scrape
+	ScrapeTimeout time.Duration
+	// ScrapeInterval, when set, makes Collect serve the metrics cached by a background scrape run every interval
+	// instead of scraping the servers itself
+	ScrapeInterval time.Duration
+	// ClientLabel is how the hostname of a client is rendered in the impala_client label, one of clientLabelModes;
+	// empty keeps it as is
+	ClientLabel string
+	// AggregateClients exports the per-client metrics as per-server totals, without the impala_client label
+	AggregateClients bool
+	// ClientInclude, when set, restricts the per-client metrics to the clients whose hostname matches it
+	ClientInclude *regexp.Regexp
+	// Timezone is the time zone of the Impala daemons, in which the web UI renders timestamps; nil for the local one
+	Timezone *time.Location
+	// ExecutorGroupMinSizes are the minimum sizes of the executor groups by name, below which a group is unhealthy
+	ExecutorGroupMinSizes map[string]int
+	// FlagAllowlist are the startup flags exported in impala_flag_info by the flags collector
+	FlagAllowlist []string
+	// HeartbeatTimeout is how long a statestore subscriber may go without a heartbeat before it is counted as failed;
+	// 0 for defaultHeartbeatTimeout
+	HeartbeatTimeout time.Duration
+	// ClusterAggregates exports the in-flight queries, client connections and backends summed up per cluster
+	ClusterAggregates bool
+	// ClientExclude, when set, leaves the clients whose hostname matches it out of the per-client metrics
+	ClientExclude *regexp.Regexp
+	// TopUsers is the number of users whose active sessions are exported individually; 0 disables the metric
+	TopUsers int
+	// QueryExemplars exports impala_inflight_query_duration_seconds, whose buckets carry the query_id of their slowest
+	// query as exemplar
+	QueryExemplars bool
+	// QueryInfoLimit is the number of in-flight queries, the longest running ones, exported individually in
+	// impala_inflight_query_info; 0 disables the metric
+	QueryInfoLimit int
+	// LegacySlowQueryMetrics additionally exports the per-threshold impala_slowXX_queries_count metrics
+	LegacySlowQueryMetrics bool
+	// Collectors holds which per-endpoint collectors run, by name; nil runs them all
+	Collectors map[string]bool
+	// MaxConcurrentTargets bounds how many servers are scraped at once; 0 means no bound
+	MaxConcurrentTargets int
+	// TrackedQueryOptions are the query options whose overrides are counted from query profiles; none disables this
+	TrackedQueryOptions []string
+	// ProfileThreshold is the running time above which the profile of a completed query is fetched and its key
+	// counters logged; 0 disables this
+	ProfileThreshold time.Duration
+	// FingerprintLimit is the number of statement fingerprints the completed queries are counted by per server,
+	// further ones being counted together; 0 disables the fingerprint metrics
+	FingerprintLimit int
+	// QueryRetries counts the completed queries that were transparently retried, and the retries that failed
+	QueryRetries bool
+	// SpillingQueries fetches the profiles of the running queries to count those spilling to scratch
+	SpillingQueries bool
+	// CSVExport keeps the sessions and queries of the last scrape of every server, served as CSV on /export/csv
+	CSVExport bool
+	// MaxProfilesPerScrape bounds the query profiles fetched per server and scrape, for each use of them
+	MaxProfilesPerScrape int
+	// BreakerThreshold is the number of consecutive failed scrapes of a server after which it is only probed every
+	// BreakerProbeInterval; 0 scrapes every server on every scrape
+	BreakerThreshold     int
+	BreakerProbeInterval time.Duration
+	// SnapshotMaxAge is how old the last complete scrape of a server may be to be served when a scrape of it times out;
+	// 0 disables serving snapshots
+	SnapshotMaxAge time.Duration
+	// Namespace prefixes the name of every Impala metric, defaultNamespace when empty
+	Namespace string
 }
 
 // Exporter collects Impala metrics
 type Exporter struct {
 	impalaServers         []string
+	options               ExporterOptions
+	sourcesMu             sync.RWMutex
+	sourceServers         map[string][]string
+	sourceRoles           map[string]map[string]string
+	clusterOf             map[string]string
 	totalConnections      *prometheus.Desc
 	totalSessions         *prometheus.Desc
 	totalActiveSessions   *prometheus.Desc
 	totalInactiveSessions *prometheus.Desc
 	inflightQueries       *prometheus.Desc
 	totalQueries          *prometheus.Desc
+	clientQueriesTotal    *prometheus.Desc
 	inflightQueriesCount  *prometheus.Desc
-	slowQueriesCount      map[int]*prometheus.Desc
+	waitingToCloseQueries *prometheus.Desc
+	slowQueries           *prometheus.Desc
+	legacySlowQueries     map[int]*prometheus.Desc
+	rpcCalls              *prometheus.Desc
+	rpcHandlerLatency     *prometheus.Desc
+	rpcHandlerLatencyMax  *prometheus.Desc
+	rpcQueueOverflows     *prometheus.Desc
+	rpcQueueSize          *prometheus.Desc
+	rpcIdleThreads        *prometheus.Desc
+	stuckQueriesCount     *prometheus.Desc
+	scanProgressMin       *prometheus.Desc
+	scanProgressAvg       *prometheus.Desc
+	scanStalledQueries    *prometheus.Desc
+	inflightQueryDuration *prometheus.Desc
+	inflightQueryInfo     *prometheus.Desc
+	canarySuccess         *prometheus.Desc
+	clientPortUp          *prometheus.Desc
+	canaryDuration        *prometheus.Desc
+	userActiveSessions    *prometheus.Desc
+	backends              *prometheus.Desc
+	clusterInflight       *prometheus.Desc
+	clusterConnections    *prometheus.Desc
+	clusterBackends       *prometheus.Desc
+	daemonRoleInfo        *prometheus.Desc
+	flagInfo              *prometheus.Desc
+	logMessages           *prometheus.Desc
+	memTrackerConsumption *prometheus.Desc
+	clientMaxSessionAge   *prometheus.Desc
+	clientMaxSessionIdle  *prometheus.Desc
+	oldestSessionAge      *prometheus.Desc
+	expiredSessions       *prometheus.Desc
+	admissionRunning      *prometheus.Desc
+	admissionQueued       *prometheus.Desc
+	admissionMaxRequests  *prometheus.Desc
+	admissionUtilization  *prometheus.Desc
+	admissionMemAdmitted  *prometheus.Desc
+	admissionMemReserved  *prometheus.Desc
+	admissionMaxMem       *prometheus.Desc
+	buildInfo             *prometheus.Desc
+	targetInfo            *prometheus.Desc
+	// up is left out of descMeta, a snapshot served for a server must not report it as up
+	up *prometheus.Desc
+
+	// Subscribers of the statestore, from its /subscribers page
+	statestoreSubscribers     *prometheus.Desc
+	statestoreHeartbeatFailed *prometheus.Desc
+	statestoreSinceHeartbeat  *prometheus.Desc
+
+	// Executor groups of the cluster membership, from the /backends page of the coordinators
+	executorGroupExecutors *prometheus.Desc
+	executorGroupSlots     *prometheus.Desc
+	executorGroupHealthy   *prometheus.Desc
+
+	clientConnections             *prometheus.Desc
+	clientConnectionSetupTimeouts *prometheus.Desc
+	clientAuthFailures            *prometheus.Desc
+	fragmentInstancesRunning      *prometheus.Desc
+	fragmentInstances             *prometheus.Desc
+	jvmHeapUsed                   *prometheus.Desc
+	jvmHeapCommitted              *prometheus.Desc
+	jvmHeapMax                    *prometheus.Desc
+	jvmGCCollections              *prometheus.Desc
+	jvmGCSeconds                  *prometheus.Desc
+	tcmallocInUse                 *prometheus.Desc
+	tcmallocPageheapFree          *prometheus.Desc
+	tcmallocPageheapUnmapped      *prometheus.Desc
+	tcmallocPhysical              *prometheus.Desc
+	dataCacheHitBytes             *prometheus.Desc
+	dataCacheMissBytes            *prometheus.Desc
+	dataCacheUsed                 *prometheus.Desc
+	targetDataAge                 *prometheus.Desc
+	queryOptionOverrides          *prometheus.Desc
+	slowQueryProfiles             *prometheus.Desc
+	fingerprintQueries            *prometheus.Desc
+	fingerprintDuration           *prometheus.Desc
+	retriedQueries                *prometheus.Desc
+	retriesExhausted              *prometheus.Desc
+	spillingQueries               *prometheus.Desc
+	spilledBytes                  *prometheus.Desc
+	scratchUsed                   *prometheus.Desc
+	scratchLimit                  *prometheus.Desc
+
+	// collectors are the per-endpoint collectors run against each server, in scrape order
+	collectors []collectorEntry
+
+	buildInfoMu    sync.Mutex
+	buildInfoCache map[string]cachedBuildInfo
+
+	// roles holds the roles detected by the role collector, by server name
+	rolesMu sync.Mutex
+	roles   map[string]cachedRole
+
+	// descMeta holds the name and help of every descriptor above, snapshots the last complete scrape per server
+	descMeta    map[*prometheus.Desc]descMeta
+	snapshotsMu sync.Mutex
+	snapshots   map[string]TargetSnapshot
+
+	breakers *breakerSet
+
+	queryOptionsMu   sync.Mutex
+	queryOptionUsage map[string]*queryOptionUsage
+
+	slowProfilesMu sync.Mutex
+	slowProfiles   map[string]*slowQueryProfiles
+
+	fingerprintsMu sync.Mutex
+	fingerprints   map[string]*queryFingerprints
+
+	queryRetriesMu sync.Mutex
+	queryRetries   map[string]*queryRetries
+
+	clientQueries clientQueryCounter
+
+	// publish publishes the events of the in-flight queries crossing QueryEventThreshold, tracked in longQueries
+	publish     atomic.Pointer[func(Event)]
+	longQueries queryTracker
+
+	scanProgress scanProgressTracker
+	logCounts    logCountsTracker
+	// scrapeStatus holds the outcome of the last scrape of every server, served on /api/v1/targets
+	scrapeStatus scrapeStatusTracker
+	// scrapedData holds the sessions and queries of the last scrape of every server with CSVExport
+	scrapedData scrapedDataStore
+
+	// cache holds the last background scrape when ScrapeInterval is set
+	cacheMu sync.RWMutex
+	cache   *cachedScrape
+
+	// scraped is set once any Impala endpoint has been fetched and decoded successfully
+	scraped atomic.Bool
 }
 
 // NewExporter creates a new instance of Exporter
-func NewExporter(impalaServers []string) *Exporter {
-	slowQueriesCount := map[int]*prometheus.Desc{
-		10:  prometheus.NewDesc("impala_slow10s_queries_count", "Number of queries slower than 10 seconds", []string{"impala_server"}, nil),
-		30:  prometheus.NewDesc("impala_slow30s_queries_count", "Number of queries slower than 30 seconds", []string{"impala_server"}, nil),
-		60:  prometheus.NewDesc("impala_slow1m_queries_count", "Number of queries slower than 1 minute", []string{"impala_server"}, nil),
-		120: prometheus.NewDesc("impala_slow2m_queries_count", "Number of queries slower than 2 minutes", []string{"impala_server"}, nil),
-		180: prometheus.NewDesc("impala_slow3m_queries_count", "Number of queries slower than 3 minutes", []string{"impala_server"}, nil),
-		300: prometheus.NewDesc("impala_slow5m_queries_count", "Number of queries slower than 5 minutes", []string{"impala_server"}, nil),
-		600: prometheus.NewDesc("impala_slow10m_queries_count", "Number of queries slower than 10 minutes", []string{"impala_server"}, nil),
-	}
-	return &Exporter{
-		impalaServers: impalaServers,
-		totalConnections: prometheus.NewDesc(
-			"impala_total_connections",
+func NewExporter(impalaServers []string, options ExporterOptions) *Exporter {
+	namespace := cmp.Or(options.Namespace, defaultNamespace)
+	descs := make(map[*prometheus.Desc]descMeta)
+	newDesc := func(name, help string, labels []string, constLabels prometheus.Labels) *prometheus.Desc {
+		desc := prometheus.NewDesc(name, help, labels, constLabels)
+		descs[desc] = descMeta{name: name, help: help}
+		return desc
+	}
+	// The per-threshold metric names predating impala_slow_queries, only exported with LegacySlowQueryMetrics
+	var legacySlowQueries map[int]*prometheus.Desc
+	if options.LegacySlowQueryMetrics {
+		legacySlowQueries = map[int]*prometheus.Desc{
+			10:  newDesc(prometheus.BuildFQName(namespace, "", "slow10s_queries_count"), "Number of queries slower than 10 seconds", []string{"impala_server"}, nil),
+			30:  newDesc(prometheus.BuildFQName(namespace, "", "slow30s_queries_count"), "Number of queries slower than 30 seconds", []string{"impala_server"}, nil),
+			60:  newDesc(prometheus.BuildFQName(namespace, "", "slow1m_queries_count"), "Number of queries slower than 1 minute", []string{"impala_server"}, nil),
+			120: newDesc(prometheus.BuildFQName(namespace, "", "slow2m_queries_count"), "Number of queries slower than 2 minutes", []string{"impala_server"}, nil),
+			180: newDesc(prometheus.BuildFQName(namespace, "", "slow3m_queries_count"), "Number of queries slower than 3 minutes", []string{"impala_server"}, nil),
+			300: newDesc(prometheus.BuildFQName(namespace, "", "slow5m_queries_count"), "Number of queries slower than 5 minutes", []string{"impala_server"}, nil),
+			600: newDesc(prometheus.BuildFQName(namespace, "", "slow10m_queries_count"), "Number of queries slower than 10 minutes", []string{"impala_server"}, nil),
+		}
+	}
+	// The per-client metrics are per-server totals without impala_client with AggregateClients
+	clientLabels := []string{"impala_server", "impala_client"}
+	if options.AggregateClients {
+		clientLabels = clientLabels[:1]
+	}
+	e := &Exporter{
+		impalaServers:  impalaServers,
+		options:        options,
+		sourceServers:  make(map[string][]string),
+		sourceRoles:    make(map[string]map[string]string),
+		buildInfoCache: make(map[string]cachedBuildInfo),
+		roles:          make(map[string]cachedRole),
+		snapshots:      make(map[string]TargetSnapshot),
+		breakers:       newBreakerSet(options.BreakerThreshold, options.BreakerProbeInterval),
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Whether the last scrape of an Impala server succeeded, 0 while its circuit breaker is open",
+			[]string{"impala_server"},
+			nil,
+		),
+		queryOptionUsage: make(map[string]*queryOptionUsage),
+		slowProfiles:     make(map[string]*slowQueryProfiles),
+		fingerprints:     make(map[string]*queryFingerprints),
+		queryRetries:     make(map[string]*queryRetries),
+		descMeta:         descs,
+		totalConnections: newDesc(
+			prometheus.BuildFQName(namespace, "", "total_connections"),
 			"Total number of connections for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		totalSessions: prometheus.NewDesc(
-			"impala_total_sessions",
+		totalSessions: newDesc(
+			prometheus.BuildFQName(namespace, "", "total_sessions"),
 			"Total number of sessions for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		totalActiveSessions: prometheus.NewDesc(
-			"impala_total_active_sessions",
+		totalActiveSessions: newDesc(
+			prometheus.BuildFQName(namespace, "", "total_active_sessions"),
 			"Total number of active sessions for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		totalInactiveSessions: prometheus.NewDesc(
-			"impala_total_inactive_sessions",
+		totalInactiveSessions: newDesc(
+			prometheus.BuildFQName(namespace, "", "total_inactive_sessions"),
 			"Total number of inactive sessions for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		inflightQueries: prometheus.NewDesc(
-			"impala_inflight_queries",
+		inflightQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "inflight_queries"),
 			"Number of inflight queries for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		totalQueries: prometheus.NewDesc(
-			"impala_total_queries",
+		totalQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "total_queries"),
 			"Total number of queries for an Impala client",
-			[]string{"impala_server", "impala_client"},
+			clientLabels,
 			nil,
 		),
-		inflightQueriesCount: prometheus.NewDesc(
-			"impala_inflight_queries_count",
+		clientQueriesTotal: newDesc(
+			prometheus.BuildFQName(namespace, "client", "queries_total"),
+			"Number of queries submitted by an Impala client, kept increasing across daemon restarts",
+			clientLabels,
+			nil,
+		),
+		inflightQueriesCount: newDesc(
+			prometheus.BuildFQName(namespace, "", "inflight_queries_count"),
 			"Total number of in-flight queries",
 			[]string{"impala_server"},
 			nil,
 		),
-		slowQueriesCount: slowQueriesCount,
+		waitingToCloseQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "waiting_to_close_queries"),
+			"Number of in-flight queries that finished executing and wait for their client to close them, holding on to their resources",
+			[]string{"impala_server"},
+			nil,
+		),
+		slowQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "slow_queries"),
+			"Number of in-flight queries running for longer than the threshold",
+			[]string{"impala_server", "threshold"},
+			nil,
+		),
+		legacySlowQueries: legacySlowQueries,
+		rpcCalls: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_calls_total"),
+			"Total number of KRPC calls handled per service and method",
+			[]string{"impala_server", "service", "method"},
+			nil,
+		),
+		rpcHandlerLatency: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_handler_latency_seconds"),
+			"KRPC handler latency at the given percentile per service and method, as computed by Impala over its recent calls",
+			[]string{"impala_server", "service", "method", "percentile"},
+			nil,
+		),
+		rpcHandlerLatencyMax: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_handler_latency_max_seconds"),
+			"Maximum KRPC handler latency per service and method",
+			[]string{"impala_server", "service", "method"},
+			nil,
+		),
+		rpcQueueOverflows: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_queue_overflows_total"),
+			"Total number of KRPC calls rejected because the service queue was full",
+			[]string{"impala_server", "service"},
+			nil,
+		),
+		rpcQueueSize: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_queue_size"),
+			"Size of the KRPC service queue",
+			[]string{"impala_server", "service"},
+			nil,
+		),
+		rpcIdleThreads: newDesc(
+			prometheus.BuildFQName(namespace, "", "rpc_idle_threads"),
+			"Number of idle KRPC service threads",
+			[]string{"impala_server", "service"},
+			nil,
+		),
+		stuckQueriesCount: newDesc(
+			prometheus.BuildFQName(namespace, "", "stuck_queries_count"),
+			"Number of in-flight queries whose scan progress is below the stuck threshold after the minimum duration",
+			[]string{"impala_server"},
+			nil,
+		),
+		scanProgressMin: newDesc(
+			prometheus.BuildFQName(namespace, "inflight_scan_progress", "min_ratio"),
+			"Lowest ratio of completed scan ranges among the in-flight queries with scan ranges",
+			[]string{"impala_server"},
+			nil,
+		),
+		scanProgressAvg: newDesc(
+			prometheus.BuildFQName(namespace, "inflight_scan_progress", "avg_ratio"),
+			"Average ratio of completed scan ranges of the in-flight queries with scan ranges",
+			[]string{"impala_server"},
+			nil,
+		),
+		scanStalledQueries: newDesc(
+			prometheus.BuildFQName(namespace, "inflight_scan", "stalled_queries"),
+			"Number of in-flight queries whose scans have not completed a single scan range since the previous scrape",
+			[]string{"impala_server"},
+			nil,
+		),
+		inflightQueryDuration: newDesc(
+			prometheus.BuildFQName(namespace, "", "inflight_query_duration_seconds"),
+			"Running time of the in-flight queries, bucketed by the slow query thresholds; each bucket above the lowest carries the query_id of its slowest query as exemplar",
+			[]string{"impala_server"},
+			nil,
+		),
+		inflightQueryInfo: newDesc(
+			prometheus.BuildFQName(namespace, "", "inflight_query_info"),
+			"Running time in seconds of an in-flight query, for the longest running queries of a coordinator; coordinator is the address of its web UI",
+			[]string{"impala_server", "query_id", "user", "pool", "state", "coordinator"},
+			nil,
+		),
+		clientPortUp: newDesc(
+			prometheus.BuildFQName(namespace, "client", "port_up"),
+			"Whether a Thrift client port of a coordinator accepts connections and answers requests",
+			[]string{"impala_server", "port_type"},
+			nil,
+		),
+		canarySuccess: newDesc(
+			prometheus.BuildFQName(namespace, "canary", "query_success"),
+			"Whether the last canary query run over the HiveServer2 port succeeded",
+			[]string{"impala_server"},
+			nil,
+		),
+		canaryDuration: newDesc(
+			prometheus.BuildFQName(namespace, "canary", "query_duration_seconds"),
+			"Duration of the last successful canary query, including connecting and opening a session",
+			[]string{"impala_server"},
+			nil,
+		),
+		clientMaxSessionAge: newDesc(
+			prometheus.BuildFQName(namespace, "client", "max_connection_age_seconds"),
+			"Age of the oldest open session of an Impala client",
+			clientLabels,
+			nil,
+		),
+		clientMaxSessionIdle: newDesc(
+			prometheus.BuildFQName(namespace, "client", "max_idle_seconds"),
+			"Time since the longest idle open session of an Impala client was last used",
+			clientLabels,
+			nil,
+		),
+		oldestSessionAge: newDesc(
+			prometheus.BuildFQName(namespace, "", "oldest_session_age_seconds"),
+			"Age of the oldest open session of an Impala server, 0 without open sessions",
+			[]string{"impala_server"},
+			nil,
+		),
+		expiredSessions: newDesc(
+			prometheus.BuildFQName(namespace, "", "expired_sessions"),
+			"Number of sessions of an Impala server that expired after their idle timeout but were not closed by their client",
+			[]string{"impala_server"},
+			nil,
+		),
+		daemonRoleInfo: newDesc(
+			prometheus.BuildFQName(namespace, "daemon", "role_info"),
+			"Role of an impalad from its -is_coordinator and -is_executor flags: coordinator, executor, coordinator_executor or none",
+			[]string{"impala_server", "role"},
+			nil,
+		),
+		statestoreSubscribers: newDesc(
+			prometheus.BuildFQName(namespace, "statestore", "subscribers"),
+			"Number of daemons registered with the statestore",
+			[]string{"impala_server"},
+			nil,
+		),
+		statestoreHeartbeatFailed: newDesc(
+			prometheus.BuildFQName(namespace, "statestore", "subscribers_heartbeat_failed"),
+			"Number of subscribers of the statestore whose last heartbeat is older than -statestore.heartbeat-timeout",
+			[]string{"impala_server"},
+			nil,
+		),
+		statestoreSinceHeartbeat: newDesc(
+			prometheus.BuildFQName(namespace, "statestore", "subscriber_seconds_since_heartbeat"),
+			"Time since the statestore last heartbeated a subscriber",
+			[]string{"impala_server", "subscriber"},
+			nil,
+		),
+		executorGroupExecutors: newDesc(
+			prometheus.BuildFQName(namespace, "executor_group", "executors"),
+			"Number of executors of an executor group in the cluster membership seen by a coordinator",
+			[]string{"impala_server", "group"},
+			nil,
+		),
+		executorGroupSlots: newDesc(
+			prometheus.BuildFQName(namespace, "executor_group", "admission_slots"),
+			"Admission slots summed over the executors of an executor group",
+			[]string{"impala_server", "group"},
+			nil,
+		),
+		executorGroupHealthy: newDesc(
+			prometheus.BuildFQName(namespace, "executor_group", "healthy"),
+			"Whether an executor group has at least its minimum size of executors, for the groups of -executor-groups.min-size",
+			[]string{"impala_server", "group"},
+			nil,
+		),
+		memTrackerConsumption: newDesc(
+			prometheus.BuildFQName(namespace, "mem_tracker", "consumption_bytes"),
+			"Memory consumed by the process memory tracker of an Impala daemon, tracker Process, and by each of its children",
+			[]string{"impala_server", "tracker"},
+			nil,
+		),
+		logMessages: newDesc(
+			prometheus.BuildFQName(namespace, "log", "messages_total"),
+			"Number of messages of a level logged by an Impala daemon since the exporter started, counted from the tail of its log served on /logs",
+			[]string{"impala_server", "level"},
+			nil,
+		),
+		flagInfo: newDesc(
+			prometheus.BuildFQName(namespace, "flag", "info"),
+			"Current value of a startup flag of an Impala daemon, among those allowlisted by -flags.allowlist",
+			[]string{"impala_server", "name", "value"},
+			nil,
+		),
+		backends: newDesc(
+			prometheus.BuildFQName(namespace, "", "backends"),
+			"Number of backends in the cluster membership as seen by a coordinator",
+			[]string{"impala_server"},
+			nil,
+		),
+		clusterInflight: newDesc(
+			prometheus.BuildFQName(namespace, "cluster", "inflight_queries"),
+			"Number of in-flight queries summed over the servers of a cluster",
+			[]string{"cluster"},
+			nil,
+		),
+		clusterConnections: newDesc(
+			prometheus.BuildFQName(namespace, "cluster", "connections"),
+			"Number of client connections summed over the servers of a cluster",
+			[]string{"cluster"},
+			nil,
+		),
+		clusterBackends: newDesc(
+			prometheus.BuildFQName(namespace, "cluster", "backends"),
+			"Number of backends in the cluster membership, the largest seen by a server of the cluster; needs the backends collector",
+			[]string{"cluster"},
+			nil,
+		),
+		userActiveSessions: newDesc(
+			prometheus.BuildFQName(namespace, "", "user_active_sessions"),
+			"Number of active sessions per user, for the users holding the most sessions; the rest are summed up as user \"__other__\"",
+			[]string{"impala_server", "user"},
+			nil,
+		),
+		admissionRunning: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "running_queries"),
+			"Number of queries running in a resource pool across the cluster",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionQueued: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "queued_queries"),
+			"Number of queries queued in a resource pool across the cluster",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionMaxRequests: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "max_requests"),
+			"Maximum number of concurrently running queries of a resource pool, -1 when unlimited",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionUtilization: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "max_requests_utilization_ratio"),
+			"Running queries divided by max requests of a resource pool, omitted for pools without a limit",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionMemAdmitted: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "mem_admitted_bytes"),
+			"Memory admitted to the running queries of a resource pool by a coordinator",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionMemReserved: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "mem_reserved_bytes"),
+			"Memory reserved by the queries of a resource pool across the cluster",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		admissionMaxMem: newDesc(
+			prometheus.BuildFQName(namespace, "admission", "max_mem_bytes"),
+			"Maximum memory of a resource pool across the cluster, -1 when unlimited",
+			[]string{"impala_server", "pool"},
+			nil,
+		),
+		buildInfo: newDesc(
+			prometheus.BuildFQName(namespace, "", "build_info"),
+			"Impala version and build hash of the server, always 1",
+			[]string{"impala_server", "version", "build_hash"},
+			nil,
+		),
+		targetInfo: newDesc(
+			prometheus.BuildFQName(namespace, "", "target_info"),
+			"Metadata of a configured Impala server, always 1",
+			[]string{"impala_server", "role", "cluster", "scheme", "port"},
+			nil,
+		),
+		clientConnections: newDesc(
+			prometheus.BuildFQName(namespace, "client", "connections_total"),
+			"Number of client connections accepted by a client-facing Thrift server",
+			[]string{"impala_server", "protocol"},
+			nil,
+		),
+		clientConnectionSetupTimeouts: newDesc(
+			prometheus.BuildFQName(namespace, "client", "connection_setup_timeouts_total"),
+			"Number of client connection requests that timed out waiting for setup",
+			[]string{"impala_server", "protocol"},
+			nil,
+		),
+		targetDataAge: newDesc(
+			prometheus.BuildFQName(namespace, "target", "data_age_seconds"),
+			"Age of the data exported for a server: 0 when it was just scraped, the age of the snapshot served when the scrape timed out",
+			[]string{"impala_server"},
+			nil,
+		),
+		queryOptionOverrides: newDesc(
+			prometheus.BuildFQName(namespace, "query_option", "overrides_total"),
+			"Number of completed queries that set a query option through configuration, among those whose profile was fetched since the exporter started",
+			[]string{"impala_server", "option"},
+			nil,
+		),
+		slowQueryProfiles: newDesc(
+			prometheus.BuildFQName(namespace, "slow_query", "profiles_total"),
+			"Number of completed queries slower than the profile threshold whose profile was fetched and logged since the exporter started",
+			[]string{"impala_server"},
+			nil,
+		),
+		retriedQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "retried_queries_total"),
+			"Number of completed queries a coordinator transparently retried after a node failure since the exporter started",
+			[]string{"impala_server"},
+			nil,
+		),
+		retriesExhausted: newDesc(
+			prometheus.BuildFQName(namespace, "", "query_retries_exhausted_total"),
+			"Number of query retries that failed too, exhausting the retries of their query, since the exporter started",
+			[]string{"impala_server"},
+			nil,
+		),
+		spillingQueries: newDesc(
+			prometheus.BuildFQName(namespace, "", "spilling_queries"),
+			"Number of running queries of a coordinator that spilled to scratch, among those whose profile was fetched",
+			[]string{"impala_server"},
+			nil,
+		),
+		spilledBytes: newDesc(
+			prometheus.BuildFQName(namespace, "", "spilled_bytes_total"),
+			"Bytes a daemon spilled to its scratch directories since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		scratchUsed: newDesc(
+			prometheus.BuildFQName(namespace, "scratch", "used_bytes"),
+			"Scratch space used by a daemon across its scratch directories",
+			[]string{"impala_server"},
+			nil,
+		),
+		scratchLimit: newDesc(
+			prometheus.BuildFQName(namespace, "scratch", "limit_bytes"),
+			"Scratch space limit of an impalad, summed over the directories of its -scratch_dirs; omitted when one of them is unlimited",
+			[]string{"impala_server"},
+			nil,
+		),
+		fingerprintQueries: newDesc(
+			prometheus.BuildFQName(namespace, "query_fingerprint", "queries_total"),
+			"Number of queries completed since the exporter started by fingerprint of their statement shape, other beyond the fingerprint limit",
+			[]string{"impala_server", "fingerprint"},
+			nil,
+		),
+		fingerprintDuration: newDesc(
+			prometheus.BuildFQName(namespace, "query_fingerprint", "duration_seconds"),
+			"Duration of the queries completed since the exporter started by fingerprint of their statement shape, other beyond the fingerprint limit",
+			[]string{"impala_server", "fingerprint"},
+			nil,
+		),
+		fragmentInstancesRunning: newDesc(
+			prometheus.BuildFQName(namespace, "fragment_instances", "running"),
+			"Number of query fragment instances executing on an impalad",
+			[]string{"impala_server"},
+			nil,
+		),
+		jvmHeapUsed: newDesc(
+			prometheus.BuildFQName(namespace, "jvm_heap", "used_bytes"),
+			"Heap used by the JVM embedded in an impalad or catalogd",
+			[]string{"impala_server"},
+			nil,
+		),
+		jvmHeapCommitted: newDesc(
+			prometheus.BuildFQName(namespace, "jvm_heap", "committed_bytes"),
+			"Heap committed by the JVM embedded in an impalad or catalogd",
+			[]string{"impala_server"},
+			nil,
+		),
+		jvmHeapMax: newDesc(
+			prometheus.BuildFQName(namespace, "jvm_heap", "max_bytes"),
+			"Maximum heap of the JVM embedded in an impalad or catalogd, its -Xmx",
+			[]string{"impala_server"},
+			nil,
+		),
+		jvmGCCollections: newDesc(
+			prometheus.BuildFQName(namespace, "jvm_gc", "collections_total"),
+			"Number of garbage collections of the JVM embedded in an impalad or catalogd since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		jvmGCSeconds: newDesc(
+			prometheus.BuildFQName(namespace, "jvm_gc", "duration_seconds_total"),
+			"Time spent in garbage collection by the JVM embedded in an impalad or catalogd since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		tcmallocInUse: newDesc(
+			prometheus.BuildFQName(namespace, "tcmalloc", "in_use_bytes"),
+			"Bytes allocated by a daemon through TCMalloc and in use",
+			[]string{"impala_server"},
+			nil,
+		),
+		tcmallocPageheapFree: newDesc(
+			prometheus.BuildFQName(namespace, "tcmalloc", "pageheap_free_bytes"),
+			"Bytes free in the TCMalloc page heap of a daemon, still mapped and counted in its resident memory",
+			[]string{"impala_server"},
+			nil,
+		),
+		tcmallocPageheapUnmapped: newDesc(
+			prometheus.BuildFQName(namespace, "tcmalloc", "pageheap_unmapped_bytes"),
+			"Bytes free in the TCMalloc page heap of a daemon that were released to the OS",
+			[]string{"impala_server"},
+			nil,
+		),
+		tcmallocPhysical: newDesc(
+			prometheus.BuildFQName(namespace, "tcmalloc", "physical_bytes"),
+			"Physical memory reserved by TCMalloc for a daemon, its total reserved bytes less the unmapped ones",
+			[]string{"impala_server"},
+			nil,
+		),
+		dataCacheHitBytes: newDesc(
+			prometheus.BuildFQName(namespace, "data_cache", "hit_bytes_total"),
+			"Bytes of remote data an impalad read from its data cache since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		dataCacheMissBytes: newDesc(
+			prometheus.BuildFQName(namespace, "data_cache", "miss_bytes_total"),
+			"Bytes of remote data an impalad looked up in its data cache without finding them since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		dataCacheUsed: newDesc(
+			prometheus.BuildFQName(namespace, "data_cache", "used_bytes"),
+			"Bytes held in the data cache of an impalad",
+			[]string{"impala_server"},
+			nil,
+		),
+		fragmentInstances: newDesc(
+			prometheus.BuildFQName(namespace, "fragment_instances", "total"),
+			"Number of query fragment instances an impalad has executed since it started",
+			[]string{"impala_server"},
+			nil,
+		),
+		clientAuthFailures: newDesc(
+			prometheus.BuildFQName(namespace, "client", "auth_failures_total"),
+			"Number of failed client authentication attempts by mechanism",
+			[]string{"impala_server", "protocol", "mechanism"},
+			nil,
+		),
+	}
+	e.collectors = newCollectors(e)
+	return e
+}
+
+// SetSourceServers replaces the servers provided by a runtime source such as the targets API
+func (e *Exporter) SetSourceServers(source string, servers []string) {
+	e.sourcesMu.Lock()
+	defer e.sourcesMu.Unlock()
+	if len(servers) == 0 {
+		delete(e.sourceServers, source)
+		return
 	}
+	e.sourceServers[source] = slices.Clone(servers)
+}
+
+// SetSourceRoles replaces the roles, by server address, reported by a runtime source that knows them,
+// overriding the role inferred from the web UI port
+func (e *Exporter) SetSourceRoles(source string, roles map[string]string) {
+	e.sourcesMu.Lock()
+	defer e.sourcesMu.Unlock()
+	if len(roles) == 0 {
+		delete(e.sourceRoles, source)
+		return
+	}
+	e.sourceRoles[source] = maps.Clone(roles)
+}
+
+// Servers returns the statically configured servers followed by those of every runtime source, without duplicates
+func (e *Exporter) Servers() []string {
+	e.sourcesMu.RLock()
+	defer e.sourcesMu.RUnlock()
+
+	servers := slices.Clone(e.impalaServers)
+	for _, source := range slices.Sorted(maps.Keys(e.sourceServers)) {
+		servers = append(servers, e.sourceServers[source]...)
+	}
+	return dedupeServers(servers)
 }
 
 // Describe sends the descriptors of each metric over to the provided channel
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- e.totalConnections
-	ch <- e.totalSessions
-	ch <- e.totalActiveSessions
-	ch <- e.totalInactiveSessions
-	ch <- e.inflightQueries
-	ch <- e.totalQueries
-	ch <- e.inflightQueriesCount
-	for _, desc := range e.slowQueriesCount {
-		ch <- desc
+	for _, c := range e.collectors {
+		c.collector.Describe(ch)
+	}
+	ch <- e.targetInfo
+	ch <- e.up
+	ch <- e.targetDataAge
+	if e.options.ClusterAggregates {
+		ch <- e.clusterInflight
+		ch <- e.clusterConnections
+		ch <- e.clusterBackends
+	}
+	ch <- collectorDuration
+	ch <- collectorSuccess
+	if e.options.ScrapeInterval > 0 {
+		ch <- scrapeCacheAge
 	}
 }
 
-// ParseDuration parses the duration string to seconds
+// ParseDuration parses a duration string such as "2d3h", "1h2m", "3s500ms", "1.2s" or "345.678us" to seconds.
+// It returns an error when the string is not made up entirely of duration components.
 func ParseDuration(duration string) (float64, error) {
-	re := regexp.MustCompile(`(?:(\d+)m)?(?:(\d+)s)?(?:(\d+)ms)?`)
-	matches := re.FindStringSubmatch(duration)
+	duration = strings.TrimSpace(duration)
+	matches := durationRe.FindAllStringSubmatchIndex(duration, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("invalid duration %q", duration)
+	}
 
 	var totalSeconds float64
-	if matches[1] != "" {
-		minutes, _ := strconv.Atoi(matches[1])
-		totalSeconds += float64(minutes * 60)
-	}
-	if matches[2] != "" {
-		seconds, _ := strconv.Atoi(matches[2])
-		totalSeconds += float64(seconds)
+	end := 0
+	for _, m := range matches {
+		if m[0] != end {
+			return 0, fmt.Errorf("invalid duration %q", duration)
+		}
+		end = m[1]
+
+		value, err := strconv.ParseFloat(duration[m[2]:m[3]], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", duration, err)
+		}
+		totalSeconds += value * durationUnits[duration[m[4]:m[5]]]
 	}
-	if matches[3] != "" {
-		milliseconds, _ := strconv.Atoi(matches[3])
-		totalSeconds += float64(milliseconds) / 1000
+	if end != len(duration) {
+		return 0, fmt.Errorf("invalid duration %q", duration)
 	}
 	return totalSeconds, nil
 }
 
-// Collect fetches the metrics from the Impala servers and sends them over to the provided channel
+// ParseProgress parses a progress string such as "3 / 10 ( 30%)" into a completion percentage.
+// It reports false when the query has no scan ranges to track.
+func ParseProgress(progress string) (float64, bool) {
+	completed, total, ok := parseScanRanges(progress)
+	if !ok {
+		return 0, false
+	}
+	return float64(completed) / float64(total) * 100, true
+}
+
+// Collect sends the metrics of the Impala servers over to the provided channel: those cached by the last background
+// scrape when ScrapeInterval is set, otherwise freshly fetched ones
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	for _, server := range e.impalaServers {
-		// Collect session metrics
-		url := fmt.Sprintf("http://%s/sessions?json", server)
-		resp, err := http.Get(url)
-		if err != nil {
-			log.Printf("Error fetching sessions from %s: %v", server, err)
-			continue
+	e.CollectContext(context.Background(), ch)
+}
+
+// CollectContext is Collect bound to ctx: a live scrape is abandoned like a timed out one once ctx is done,
+// cancelling the requests to the Impala servers still in flight
+func (e *Exporter) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	if e.options.ScrapeInterval > 0 {
+		e.collectCached(ch)
+		return
+	}
+	e.collectLive(ctx, ch)
+}
+
+// collectLive fetches the metrics from the Impala servers and sends them over to the provided channel.
+// Servers are scraped concurrently, at most MaxConcurrentTargets at once, and the metrics of each are sent as soon
+// as it has been scraped completely, so when the scrape timeout expires the servers that already answered are still
+// reported.
+func (e *Exporter) collectLive(ctx context.Context, ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithCancel(ctx)
+	if e.options.ScrapeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, e.options.ScrapeTimeout)
+	}
+	defer cancel()
+
+	targets := e.Targets()
+	var aggregates *clusterAggregates
+	if e.options.ClusterAggregates {
+		aggregates = e.newClusterAggregates(targets)
+		defer aggregates.collect(ch)
+	}
+	// Target info is sent for every server up front, so the role and cluster can still be joined when a scrape fails
+	for _, target := range targets {
+		e.collectTargetInfo(ch, target)
+	}
+
+	// Buffered so that targets finishing after the timeout do not block once Collect has returned
+	results := make(chan targetMetrics, len(targets))
+	pending := make(map[string]bool, len(targets))
+	limit := e.options.MaxConcurrentTargets
+	if limit <= 0 {
+		limit = len(targets)
+	}
+	sem := make(chan struct{}, limit)
+	for _, target := range targets {
+		pending[target.Name] = true
+		go func() {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results <- targetMetrics{target: target.Name}
+				return
+			}
+			result := targetMetrics{target: target.Name}
+			// A server whose circuit is open is reported down without being scraped, between probes
+			if !e.breakers.allow(target.Name, time.Now()) {
+				e.scrapeStatus.record(target.Name, ScrapeStatus{Time: time.Now(), Error: "circuit breaker open, not scraped"})
+				result.metrics = append(result.metrics, prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0, target.Name))
+				results <- result
+				return
+			}
+			targetCh := make(chan prometheus.Metric)
+			complete := make(chan bool, 1)
+			go func() {
+				complete <- e.collectTarget(ctx, targetCh, target)
+				close(targetCh)
+			}()
+			for m := range targetCh {
+				result.metrics = append(result.metrics, m)
+			}
+			result.complete = <-complete
+			e.breakers.record(target.Name, result.complete, time.Now())
+			up := 0.0
+			if result.complete {
+				up = 1
+			}
+			result.metrics = append(result.metrics, prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, up, target.Name))
+			results <- result
+		}()
+	}
+
+	for len(pending) > 0 {
+		select {
+		case result := <-results:
+			for _, m := range result.metrics {
+				ch <- m
+			}
+			if result.complete {
+				e.recordSnapshot(result.target, result.metrics)
+				ch <- prometheus.MustNewConstMetric(e.targetDataAge, prometheus.GaugeValue, 0, result.target)
+			}
+			if aggregates != nil {
+				aggregates.observe(result.target, result.metrics)
+			}
+			delete(pending, result.target)
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				slog.Warn("Scrape cancelled, skipping unfinished targets", "targets", slices.Sorted(maps.Keys(pending)))
+			} else {
+				slog.Warn("Scrape timed out, skipping unfinished targets", "timeout", e.options.ScrapeTimeout, "targets", slices.Sorted(maps.Keys(pending)))
+			}
+			// Serve the last complete scrape of the unfinished targets, flagged by its data age
+			for target := range pending {
+				e.scrapeStatus.record(target, ScrapeStatus{Time: time.Now(), Error: "scrape did not finish in time: " + ctx.Err().Error()})
+				ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0, target)
+				if snapshot, ok := e.snapshot(target); ok {
+					for _, m := range e.constMetrics(snapshot) {
+						ch <- m
+					}
+					ch <- prometheus.MustNewConstMetric(e.targetDataAge, prometheus.GaugeValue, time.Since(snapshot.Time).Seconds(), target)
+					if aggregates != nil {
+						aggregates.observeSnapshot(target, snapshot)
+					}
+				}
+			}
+			return
 		}
-		defer resp.Body.Close()
+	}
+}
 
-		var sessions ImpalaSessionsResponse
-		if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
-			log.Printf("Error decoding JSON response from %s: %v", server, err)
+// targetMetrics holds the metrics scraped from a single server
+type targetMetrics struct {
+	target  string
+	metrics []prometheus.Metric
+	// complete is set when every endpoint of the server was scraped successfully
+	complete bool
+}
+
+// collectTarget scrapes a single server with every enabled collector serving its role and sends its metrics over to
+// the provided channel, along with the outcome and duration of each collector. It reports whether every collector
+// required for the role succeeded.
+func (e *Exporter) collectTarget(ctx context.Context, ch chan<- prometheus.Metric, target Target) bool {
+	slog.Debug("Scraping target", "target", target.Name)
+
+	role := daemonRole(target)
+	complete := true
+	scrapeStart := time.Now()
+	var errs []string
+	for _, c := range e.collectors {
+		if !slices.Contains(c.roles, role) || !e.collectorEnabled(c.name) {
 			continue
 		}
-
-		for _, client := range sessions.ClientHosts {
-			impalaClient := client.Hostname
-			ch <- prometheus.MustNewConstMetric(e.totalConnections, prometheus.GaugeValue, float64(client.TotalConnections), server, impalaClient)
-			ch <- prometheus.MustNewConstMetric(e.totalSessions, prometheus.GaugeValue, float64(client.TotalSessions), server, impalaClient)
-			ch <- prometheus.MustNewConstMetric(e.totalActiveSessions, prometheus.GaugeValue, float64(client.TotalActiveSessions), server, impalaClient)
-			ch <- prometheus.MustNewConstMetric(e.totalInactiveSessions, prometheus.GaugeValue, float64(client.TotalInactiveSessions), server, impalaClient)
-			ch <- prometheus.MustNewConstMetric(e.inflightQueries, prometheus.GaugeValue, float64(client.InflightQueries), server, impalaClient)
-			ch <- prometheus.MustNewConstMetric(e.totalQueries, prometheus.GaugeValue, float64(client.TotalQueries), server, impalaClient)
+		start := time.Now()
+		err := c.collector.Collect(ctx, ch, target)
+		success := 1.0
+		if err != nil {
+			slog.Warn("Error collecting", "collector", c.name, "target", target.Name, "endpoint", c.endpoint, "err", err)
+			success = 0
+			errs = append(errs, c.name+": "+err.Error())
 		}
+		ch <- prometheus.MustNewConstMetric(collectorDuration, prometheus.GaugeValue, time.Since(start).Seconds(), c.name, target.Name)
+		ch <- prometheus.MustNewConstMetric(collectorSuccess, prometheus.GaugeValue, success, c.name, target.Name)
 
-		// Collect query metrics
-		url = fmt.Sprintf("http://%s/queries?json", server)
-		resp, err = http.Get(url)
-		if err != nil {
-			log.Printf("Error fetching queries from %s: %v", server, err)
+		if !slices.Contains(c.required, role) {
 			continue
 		}
-		defer resp.Body.Close()
+		if err != nil {
+			complete = false
+		} else {
+			e.scraped.Store(true)
+		}
+	}
+	e.scrapeStatus.record(target.Name, ScrapeStatus{
+		Time:            scrapeStart,
+		DurationSeconds: time.Since(scrapeStart).Seconds(),
+		Up:              complete,
+		Error:           strings.Join(errs, "; "),
+	})
+	return complete
+}
 
-		var queries QueriesResponse
-		if err := json.NewDecoder(resp.Body).Decode(&queries); err != nil {
-			log.Printf("Error decoding JSON response from %s: %v", server, err)
-			continue
+// sessionsCollector exports the per client and per user session metrics of /sessions
+type sessionsCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the session metrics over to the provided channel
+func (c sessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.totalConnections
+	ch <- c.e.totalSessions
+	ch <- c.e.totalActiveSessions
+	ch <- c.e.totalInactiveSessions
+	ch <- c.e.inflightQueries
+	ch <- c.e.totalQueries
+	ch <- c.e.clientQueriesTotal
+	ch <- c.e.clientMaxSessionAge
+	ch <- c.e.clientMaxSessionIdle
+	ch <- c.e.oldestSessionAge
+	ch <- c.e.expiredSessions
+	ch <- c.e.userActiveSessions
+}
+
+// Collect fetches the sessions of a server and sends the per client and per user metrics over to the provided channel
+func (c sessionsCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	server := target.Name
+	var sessions ImpalaSessionsResponse
+	if err := fetchJSON(ctx, target.Address, "/sessions?json", &sessions); err != nil {
+		return err
+	}
+
+	// Filtered out clients are not tracked at all. The counters follow the actual clients, so that a client joining
+	// or leaving a relabeled group is not taken for a daemon restart.
+	hosts := filterClients(e.options.ClientInclude, e.options.ClientExclude, sessions.ClientHosts)
+	totals := e.clientQueries.observe(server, hosts)
+	labelMode := e.options.ClientLabel
+	if e.options.AggregateClients {
+		// All clients are summed into one, reported even without clients
+		labelMode = "drop"
+		if len(hosts) == 0 {
+			hosts = []ImpalaClientHost{{}}
+		}
+	}
+	hosts, totals = relabelClients(labelMode, hosts, totals)
+	labelValues := func(client string) []string {
+		if e.options.AggregateClients {
+			return []string{server}
 		}
+		return []string{server, client}
+	}
+	for _, client := range hosts {
+		values := labelValues(client.Hostname)
+		ch <- prometheus.MustNewConstMetric(e.totalConnections, prometheus.GaugeValue, float64(client.TotalConnections), values...)
+		ch <- prometheus.MustNewConstMetric(e.totalSessions, prometheus.GaugeValue, float64(client.TotalSessions), values...)
+		ch <- prometheus.MustNewConstMetric(e.totalActiveSessions, prometheus.GaugeValue, float64(client.TotalActiveSessions), values...)
+		ch <- prometheus.MustNewConstMetric(e.totalInactiveSessions, prometheus.GaugeValue, float64(client.TotalInactiveSessions), values...)
+		ch <- prometheus.MustNewConstMetric(e.inflightQueries, prometheus.GaugeValue, float64(client.InflightQueries), values...)
+		ch <- prometheus.MustNewConstMetric(e.totalQueries, prometheus.GaugeValue, float64(client.TotalQueries), values...)
+	}
+	for client, total := range totals {
+		ch <- prometheus.MustNewConstMetric(e.clientQueriesTotal, prometheus.CounterValue, total, labelValues(client)...)
+	}
+	e.collectClientSessionAges(ch, sessions.Sessions, labelMode, labelValues)
+	e.collectOldestSessionAge(ch, server, sessions.Sessions)
+	e.collectExpiredSessions(ch, server, sessions.Sessions)
+	e.collectUserSessions(ch, server, sessions.Sessions)
+	if e.options.CSVExport {
+		e.scrapedData.update(server, func(d *scrapedData) {
+			d.SessionsTime, d.Sessions = time.Now(), sessions.Sessions
+		})
+	}
+	return nil
+}
 
-		// Track total in-flight queries and slow queries by duration
-		ch <- prometheus.MustNewConstMetric(e.inflightQueriesCount, prometheus.GaugeValue, float64(len(queries.InFlightQueries)), server)
+// queriesCollector exports the in-flight, slow and stuck query metrics of /queries, and the query option overrides,
+// slow query profiles and fingerprints of the completed queries
+type queriesCollector struct {
+	e *Exporter
+}
 
-		slowCounts := make(map[int]float64)
-		for _, query := range queries.InFlightQueries {
+// Describe sends the descriptors of the query metrics over to the provided channel
+func (c queriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.inflightQueriesCount
+	ch <- c.e.waitingToCloseQueries
+	ch <- c.e.slowQueries
+	for _, desc := range c.e.legacySlowQueries {
+		ch <- desc
+	}
+	ch <- c.e.stuckQueriesCount
+	ch <- c.e.scanProgressMin
+	ch <- c.e.scanProgressAvg
+	ch <- c.e.scanStalledQueries
+	if c.e.options.QueryExemplars {
+		ch <- c.e.inflightQueryDuration
+	}
+	if c.e.options.QueryInfoLimit > 0 {
+		ch <- c.e.inflightQueryInfo
+	}
+	ch <- c.e.queryOptionOverrides
+	if c.e.options.ProfileThreshold > 0 {
+		ch <- c.e.slowQueryProfiles
+	}
+	if c.e.options.FingerprintLimit > 0 {
+		ch <- c.e.fingerprintQueries
+		ch <- c.e.fingerprintDuration
+	}
+	if c.e.options.QueryRetries {
+		ch <- c.e.retriedQueries
+		ch <- c.e.retriesExhausted
+	}
+	if c.e.options.SpillingQueries {
+		ch <- c.e.spillingQueries
+	}
+}
+
+// Collect fetches the in-flight and completed queries of a server and sends the query metrics over to the provided
+// channel. The queries are counted as they are decoded, so that the thousands a busy coordinator may report are
+// never held in memory. Failing to fetch query profiles does not fail the collector.
+func (c queriesCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	server := target.Name
+	trackCompleted := len(e.options.TrackedQueryOptions) > 0 || e.options.ProfileThreshold > 0 || e.options.FingerprintLimit > 0 ||
+		e.options.QueryRetries
+
+	var inFlight, waiting, stuckCount float64
+	var slowCounts []float64
+	var completed []CompletedQuery
+	var durations *queryDurationHistogram
+	var longest *longestQueries
+	var slowEntries, longEntries []slowQueryEntry
+	var progress *scanProgress
+	var running []string
+	var exportedInFlight []InFlightQuery
+	var exportedCompleted []CompletedQuery
+	previousProgress := e.scanProgress.get(server)
+	err := fetchDecode(ctx, target.Address, "/queries?json", func(r io.Reader) error {
+		inFlight, waiting, stuckCount, slowCounts, completed = 0, 0, 0, make([]float64, len(slowQueryThresholds)), nil
+		exportedInFlight, exportedCompleted = nil, nil
+		durations = newQueryDurationHistogram()
+		longest = &longestQueries{limit: e.options.QueryInfoLimit}
+		slowEntries, longEntries = nil, nil
+		progress = newScanProgress()
+		running = nil
+		return decodeQueries(r, func(query InFlightQuery) {
+			inFlight++
+			if e.options.CSVExport {
+				exported := query
+				exported.Stmt = e.captureStatement(query.Stmt)
+				exportedInFlight = append(exportedInFlight, exported)
+			}
+			if query.Waiting {
+				waiting++
+			} else if e.options.SpillingQueries && query.QueryID != "" {
+				running = append(running, query.QueryID)
+			}
+			progress.observe(query.QueryID, query.Progress, previousProgress)
 			durationSeconds, err := ParseDuration(query.Duration)
 			if err != nil {
-				log.Printf("Error parsing duration: %v", err)
-				continue
+				slog.Debug("Error parsing query duration", "target", server, "endpoint", "/queries?json", "err", err)
+				return
 			}
 
-			for threshold := range e.slowQueriesCount {
-				if durationSeconds > float64(threshold) {
-					slowCounts[threshold]++
+			if durationSeconds >= e.options.StuckMinDuration.Seconds() {
+				if percent, ok := ParseProgress(query.Progress); ok && percent < e.options.StuckProgressPercent {
+					stuckCount++
+				}
+			}
+
+			for i, threshold := range slowQueryThresholds {
+				if durationSeconds > float64(threshold.seconds) {
+					slowCounts[i]++
+				}
+			}
+			durations.observe(query.QueryID, durationSeconds)
+			if longest.limit > 0 {
+				longest.add(query, durationSeconds)
+			}
+			// A query without ID cannot be told apart across scrapes, so it cannot be logged or published once
+			if query.QueryID == "" {
+				return
+			}
+			entry := func() slowQueryEntry {
+				return slowQueryEntry{
+					Time:            time.Now(),
+					ImpalaServer:    server,
+					QueryID:         query.QueryID,
+					User:            query.EffectiveUser,
+					Pool:            query.ResourcePool,
+					DurationSeconds: durationSeconds,
+					Statement:       e.captureStatement(query.Stmt),
 				}
 			}
+			if log := e.options.SlowQueryLog; log != nil && durationSeconds >= log.threshold.Seconds() {
+				slowEntries = append(slowEntries, entry())
+			}
+			if threshold := e.options.QueryEventThreshold; threshold > 0 && durationSeconds >= threshold.Seconds() {
+				longEntries = append(longEntries, entry())
+			}
+		}, func(query CompletedQuery) {
+			// The completed queries are only kept for fetching their profiles
+			if trackCompleted {
+				completed = append(completed, query)
+			}
+			if e.options.CSVExport {
+				exported := query
+				exported.Stmt = e.captureStatement(query.Stmt)
+				exportedCompleted = append(exportedCompleted, exported)
+			}
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if e.options.CSVExport {
+		e.scrapedData.update(server, func(d *scrapedData) {
+			d.QueriesTime, d.InFlight, d.Completed = time.Now(), exportedInFlight, exportedCompleted
+		})
+	}
+
+	// Track total in-flight queries and slow queries by duration
+	ch <- prometheus.MustNewConstMetric(e.inflightQueriesCount, prometheus.GaugeValue, inFlight, server)
+	ch <- prometheus.MustNewConstMetric(e.waitingToCloseQueries, prometheus.GaugeValue, waiting, server)
+	for i, threshold := range slowQueryThresholds {
+		ch <- prometheus.MustNewConstMetric(e.slowQueries, prometheus.GaugeValue, slowCounts[i], server, threshold.label)
+		// The legacy metrics were only exported for thresholds exceeded by at least one query
+		if desc, ok := e.legacySlowQueries[threshold.seconds]; ok && slowCounts[i] > 0 {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, slowCounts[i], server)
 		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.stuckQueriesCount, prometheus.GaugeValue, stuckCount, server)
+	progress.collect(ch, e, server)
+	e.scanProgress.set(server, progress.completed)
+	if e.options.QueryExemplars {
+		ch <- durations.metric(e.inflightQueryDuration, server)
+	}
+	if longest.limit > 0 {
+		longest.collect(ch, e.inflightQueryInfo, server, target.Address)
+	}
+	if e.options.SlowQueryLog != nil {
+		e.options.SlowQueryLog.record(server, slowEntries)
+	}
+	e.publishLongQueries(server, longEntries)
 
-		for threshold, count := range slowCounts {
-			ch <- prometheus.MustNewConstMetric(e.slowQueriesCount[threshold], prometheus.GaugeValue, count, server)
+	e.collectQueryOptions(ctx, ch, target, completed)
+	e.collectFingerprints(ch, server, completed)
+	e.collectQueryRetries(ch, server, completed)
+	e.collectSpillingQueries(ctx, ch, target, running)
+	e.collectSlowProfiles(ctx, ch, target, completed)
+	return nil
+}
+
+// readServers reads a newline-separated list of server addresses, skipping blank lines and # comments
+func readServers(r io.Reader) ([]string, error) {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		servers = append(servers, line)
+	}
+	return servers, scanner.Err()
+}
+
+// dedupeServers returns the servers with surrounding whitespace removed and empty entries and duplicates dropped,
+// keeping the first occurrence.
+// A server must be collected at most once per registry, otherwise the scrape fails on duplicate series.
+func dedupeServers(servers []string) []string {
+	seen := make(map[string]bool, len(servers))
+	unique := make([]string, 0, len(servers))
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" || seen[server] {
+			continue
 		}
+		seen[server] = true
+		unique = append(unique, server)
 	}
+	return unique
+}
+
+// fatal logs msg at error level with the given key/value pairs and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
+	start := time.Now()
 	// Parse the command line arguments to get the list of Impala servers and port number
-	impalaServersFlag := flag.String("impala_servers", "", "Comma-separated list of Impala server addresses (e.g., 10.11.18.16:25000,10.11.18.17:25000)")
+	impalaServersFlag := flag.String("impala_servers", "", "Comma-separated list of Impala server addresses (e.g., 10.11.18.16:25000,10.11.18.17:25000), each optionally prefixed with an alias used as impala_server label (e.g., coord-1=10.11.18.16:25000), or - to read a newline-separated list from stdin")
 	portFlag := flag.String("port", "8080", "The port to expose metrics on")
+	timeoutFlag := flag.Duration("impala_timeout", 3*time.Second, "Timeout for each request to an Impala web UI endpoint")
+	captureDirFlag := flag.String("debug.capture-dir", "", "Directory the raw responses of the Impala web UIs are saved to, per server address and endpoint, to report parsing bugs with the exact payloads; the directory can be served back with -impala.fixture-dir")
+	captureCountFlag := flag.Int("debug.capture-count", 5, "Number of responses kept per server and endpoint in -debug.capture-dir")
+	fixtureDirFlag := flag.String("impala.fixture-dir", "", "Directory of saved Impala responses served instead of contacting the servers, one subdirectory per server address holding a file per endpoint (sessions.json, queries.json, metrics.json, ...), for developing dashboards and alerts without a cluster; the subdirectories are the servers when -impala_servers is unset")
+	clientConfigFlag := flag.String("impala.client-config", "", "Path of a YAML file with the request settings of the Impala servers (headers, scheme, base_path and basic_auth, e.g. for an authenticating reverse proxy or an Apache Knox gateway), for every server and overridden per server address")
+	impalaCertFileFlag := flag.String("impala.cert-file", "", "Path of the PEM client certificate presented to Impala web UIs requiring mutual TLS, along with -impala.key-file; reloaded on every new connection. The servers must be reached with scheme https in -impala.client-config")
+	impalaKeyFileFlag := flag.String("impala.key-file", "", "Path of the PEM private key of -impala.cert-file")
+	impalaCAFileFlag := flag.String("impala.ca-file", "", "Path of the PEM CA certificates the Impala web UI certificates are verified against, instead of the system ones")
+	proxyURLFlag := flag.String("impala.proxy-url", "", "URL of a forward proxy the Impala web UIs are reached through, e.g. http://proxy:3128; when unset the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply")
+	stuckProgressFlag := flag.Float64("stuck_query_progress", 10, "Scan progress percentage below which a long-running query is counted as stuck")
+	stuckDurationFlag := flag.Duration("stuck_query_min_duration", 5*time.Minute, "Minimum running time before a query with low scan progress is counted as stuck")
+	scrubLiteralsFlag := flag.Bool("query.scrub-literals", true, "Replace string and numeric literals with ? in any query statement the exporter captures, such as in the slow query log; set to false to keep statements verbatim")
+	slowLogFileFlag := flag.String("queries.slow-log-file", "", "Path of a file a JSON line is appended to for every in-flight query running longer than -queries.slow-log-threshold, with its query_id, user, pool, duration and the beginning of its statement; disabled when unset")
+	slowLogThresholdFlag := flag.Duration("queries.slow-log-threshold", time.Minute, "Running time after which an in-flight query is written to -queries.slow-log-file, once per query")
+	slowLogMaxSizeFlag := flag.Int64("queries.slow-log-max-size", 100<<20, "Size in bytes beyond which -queries.slow-log-file is rotated; 0 never rotates it")
+	slowLogMaxFilesFlag := flag.Int("queries.slow-log-max-files", 5, "Number of rotated slow query log files kept, as <file>.1 to <file>.N; 0 truncates the file instead")
+	shutdownTimeoutFlag := flag.Duration("web.shutdown-timeout", 15*time.Second, "How long to wait for in-flight scrapes to finish on SIGINT/SIGTERM before exiting")
+	maxRequestsFlag := flag.Int("web.max-requests", 10, "Maximum number of metrics requests served at once across /metrics and the cluster endpoints, the excess is rejected with 503; 0 means no limit")
+	enableKillFlag := flag.Bool("web.enable-kill-action", false, "Serve POST /actions/kill?target=...&query_id=..., which cancels a query through the web UI of its coordinator; requires the API token as bearer token")
+	enableCSVExportFlag := flag.Bool("web.enable-csv-export", false, "Keep the sessions and queries of the last scrape of every server and serve them as CSV on /export/csv (?target=, ?data=sessions or queries), with their users and statements")
+	enableDebugQueriesFlag := flag.Bool("web.enable-debug-queries", false, "Serve /debug/queries, listing the longest running in-flight queries of the coordinators with their user and statement (?target=, ?n=, ?format=html)")
+	enablePprofFlag := flag.Bool("web.enable-pprof", false, "Serve the Go runtime profiling endpoints under /debug/pprof (CPU profiles must stay within the 10s write timeout, e.g. ?seconds=5)")
+	sinkIntervalFlag := flag.Duration("sink.interval", time.Minute, "How often a metrics snapshot is forwarded to the enabled sinks")
+	sinkQueryThresholdFlag := flag.Duration("sink.query-threshold", 10*time.Minute, "Running time after which an in-flight query is published, once, to the sinks notifying about long-running queries such as the webhook sink; 0 publishes none")
+	sinkBufferFlag := flag.Int("sink.buffer-size", 100, "Number of events buffered per sink before the oldest are dropped")
+	scrapeConcurrencyFlag := flag.Int("scrape.max-concurrency", 16, "Maximum number of servers scraped at once; 0 scrapes every server at once")
+	scrapeIntervalFlag := flag.Duration("scrape.interval", 0, "Scrape the Impala servers in the background at this interval and serve the cached result on /metrics; 0 scrapes them on every /metrics request")
+	retriesFlag := flag.Int("scrape.retries", 1, "Number of times a request to an Impala web UI endpoint is retried within a scrape after a connection failure; timeouts are not retried")
+	retryBackoffFlag := flag.Duration("scrape.retry-backoff", 100*time.Millisecond, "Wait before the first retry of a failed request, doubled for each further retry")
+	breakerThresholdFlag := flag.Int("scrape.breaker-threshold", 0, "Number of consecutive failed scrapes of a server after which it is reported down without being scraped, and only probed every -scrape.breaker-probe-interval until it recovers; 0 disables this")
+	breakerProbeFlag := flag.Duration("scrape.breaker-probe-interval", time.Minute, "How often a server whose circuit breaker is open is probed")
+	maxResponseFlag := flag.Int64("scrape.max-response-bytes", 64<<20, "Maximum size of a response of an Impala web UI endpoint; larger responses fail the collector instead of being decoded; 0 means no limit")
+	scrapeTimeoutFlag := flag.Duration("scrape.timeout", 9*time.Second, "Maximum duration of a scrape; servers that have not answered by then are left out, and should stay below the Prometheus scrape timeout")
+	timezoneFlag := flag.String("impala.timezone", "Local", "Time zone of the Impala daemons, in which their web UI renders timestamps such as session start times, as an IANA name like UTC or Europe/Berlin")
+	clientLabelFlag := flag.String("sessions.client-label", "keep", "How client hostnames are exported in the impala_client label: keep, hash (truncated SHA-256), domain (the domain of the hostname, the /24 or /64 network of an address) or drop; clients with the same label are summed")
+	aggregateClientsFlag := flag.Bool("sessions.aggregate-clients", false, "Export the per-client connection, session and query metrics as per-server totals without the impala_client label, for clusters where per-client series are unaffordable; -sessions.client-include and -sessions.client-exclude still select the clients summed")
+	clientIncludeFlag := flag.String("sessions.client-include", "", "Regular expression, anchored at both ends, of the client hostnames exported in the per-client metrics; empty exports all clients")
+	clientExcludeFlag := flag.String("sessions.client-exclude", "", "Regular expression, anchored at both ends, of the client hostnames left out of the per-client metrics, such as short-lived Spark executors")
+	topUsersFlag := flag.Int("sessions.top-users", 20, "Number of users, by active session count, exported in impala_user_active_sessions; 0 disables the metric")
+	queryExemplarsFlag := flag.Bool("queries.exemplars", false, "Export impala_inflight_query_duration_seconds, a histogram of the in-flight query durations whose buckets carry the query_id of their slowest query as OpenMetrics exemplar, and serve OpenMetrics to scrapers asking for it")
+	queryInfoFlag := flag.Int("queries.info-limit", 0, "Number of in-flight queries per coordinator, the longest running ones, exported individually in impala_inflight_query_info with their query_id, user, pool and state; 0 disables the metric")
+	legacySlowFlag := flag.Bool("compat.legacy-slow-query-metrics", false, "Also export the slow query counts under their former per-threshold names (impala_slow10s_queries_count, ...)")
+	namespaceFlag := flag.String("metric.namespace", defaultNamespace, "Prefix of the Impala metric names; the exporter's own impala_exporter_* metrics keep their names")
+	relabelConfigFlag := flag.String("metric.relabel-config", "", "Path of a YAML file with metric_relabel_configs rules (keep, drop, labeldrop and rename) applied in order to every exported series, on /metrics, the cluster endpoints and the sinks")
+	discoveryIntervalFlag := flag.Duration("discovery.refresh-interval", time.Minute, "How often enabled discovery backends are refreshed")
+	updateManifestFlag := flag.String("update.manifest-url", "", "URL of a JSON release manifest ({\"version\": \"x.y.z\"}) checked for newer exporter versions; disabled when unset")
+	updateIntervalFlag := flag.Duration("update.check-interval", 6*time.Hour, "How often the release manifest is checked")
+	versionFlag := flag.Bool("version", false, "Print version information and exit")
+	webConfigFlag := flag.String("web.config.file", "", "Path to an exporter-toolkit web configuration file enabling TLS and/or basic authentication")
+	stateFileFlag := flag.String("state.file", "", "Path of the file persisting targets added through the targets API across restarts")
+	encryptionKeyFileFlag := flag.String("state.encryption-key-file", "", "Path of a file holding a 256-bit AES key (raw, hex or base64) used to encrypt files the exporter writes to disk; when unset the key is read, hex or base64, from $"+encryptionKeyEnv)
+	allowPlaintextFlag := flag.Bool("state.allow-plaintext", false, "Accept unencrypted state and snapshot files although an encryption key is configured, to encrypt files written before encryption was enabled; meant for a single run")
+	snapshotFileFlag := flag.String("state.snapshot-file", "", "Path of a file the last complete scrape of each server is saved to on shutdown and loaded from on startup")
+	snapshotMaxAgeFlag := flag.Duration("state.snapshot-max-age", 15*time.Minute, "How old the last complete scrape of a server may be to be served, with its data age, when a scrape of it times out; 0 disables this")
+	queryOptionUsageFlag := flag.Bool("queries.option-usage", false, "Count, from the profiles of completed queries, how often the tracked query options are overridden")
+	trackedOptionsFlag := flag.String("queries.tracked-options", defaultTrackedQueryOptions, "Comma-separated query options counted by -queries.option-usage")
+	maxProfilesFlag := flag.Int("queries.max-profiles-per-scrape", 20, "Maximum number of query profiles fetched per server and scrape by -queries.option-usage, by -queries.profile-threshold and by -queries.spilling")
+	fingerprintLimitFlag := flag.Int("queries.fingerprint-limit", 0, "Number of statement fingerprints, hashes of the statements stripped of literals, the completed queries of a server are counted by in impala_query_fingerprint_*; further ones are counted as other, and 0 disables these metrics")
+	profileThresholdFlag := flag.Duration("queries.profile-threshold", 0, "Running time above which the profile of a completed query is fetched and its peak memory, bytes scanned, rows produced and scan skew logged; 0 disables this")
+	queryRetriesFlag := flag.Bool("queries.retries", false, "Count the completed queries transparently retried after a node failure (-retry_failed_queries), and the retries that failed too")
+	spillingQueriesFlag := flag.Bool("queries.spilling", false, "Count the running queries of the coordinators that spilled to scratch, from their profiles, at most -queries.max-profiles-per-scrape of them")
+	clusterAggregatesFlag := flag.Bool("cluster.aggregates", false, "Export the in-flight queries and client connections summed over the servers of each cluster, and its membership size with the backends collector, as impala_cluster_*")
+	executorGroupMinSizesFlag := flag.String("executor-groups.min-size", "", "Comma-separated group=size minimum sizes of the executor groups, the size in their -executor_groups; impala_executor_group_healthy is exported for these groups only")
+	flagAllowlistFlag := flag.String("flags.allowlist", defaultFlagAllowlist, "Comma-separated startup flags of the Impala daemons exported in impala_flag_info by the flags collector; only allowlist flags that cannot hold secrets")
+	heartbeatTimeoutFlag := flag.Duration("statestore.heartbeat-timeout", defaultHeartbeatTimeout, "Time since its last heartbeat after which a statestore subscriber is counted in impala_statestore_subscribers_heartbeat_failed")
+	readyAfterScrapeFlag := flag.Bool("web.ready-after-first-scrape", false, "Report /readyz as ready only after a first successful Impala scrape")
+	apiTokenFileFlag := flag.String("api.token-file", "", "Path of a file holding the bearer token required by the targets API; when unset the token is read from $"+apiTokenEnv+", and the API is disabled without either")
+	logLevel := &promslog.AllowedLevel{}
+	_ = logLevel.Set("info")
+	flag.Var(logLevel, "log.level", "Only log messages with the given severity or above: debug, info, warn or error")
+	logFormat := &promslog.AllowedFormat{}
+	_ = logFormat.Set("logfmt")
+	flag.Var(logFormat, "log.format", "Output format of log messages: logfmt or json")
+	var labels labelFlags
+	flag.Var(&labels, "labels", "Constant labels added to every exported series that does not already have them, as name=value,name=value (repeatable)")
+	var clusters clusterFlags
+	flag.Var(&clusters, "cluster", "Named group of Impala servers served on /metrics/<name>, as name=server1,server2 (repeatable)")
 	flag.Parse()
+	slog.SetDefault(promslog.New(&promslog.Config{Level: logLevel, Format: logFormat}))
 
-	if *impalaServersFlag == "" {
-		log.Fatal("Please provide at least one Impala server address using the -impala_servers flag.")
+	if *versionFlag {
+		fmt.Println(versionString())
+		return
+	}
+	httpClient.Timeout = *timeoutFlag
+	transport, err := newImpalaTransport(impalaTransportConfig{
+		proxyURL: *proxyURLFlag,
+		certFile: *impalaCertFileFlag,
+		keyFile:  *impalaKeyFileFlag,
+		caFile:   *impalaCAFileFlag,
+	})
+	if err != nil {
+		fatal("Error configuring requests to Impala", "err", err)
+	}
+	httpClient.Transport = transport
+	var fixtureServerList []string
+	if *fixtureDirFlag != "" {
+		if fixtureServerList, err = fixtureServers(*fixtureDirFlag); err != nil {
+			fatal("Error reading fixture directory", "err", err)
+		}
+		httpClient.Transport = fixtureTransport{dir: *fixtureDirFlag}
+		slog.Warn("Serving the Impala responses saved in the fixture directory, no server is contacted", "dir", *fixtureDirFlag, "servers", fixtureServerList)
+	}
+	// Keeps the session cookies of the web UIs and of gateways in front of them
+	httpClient.Jar, _ = cookiejar.New(nil)
+	if *clientConfigFlag != "" {
+		if clientConfig, err = loadImpalaClientConfig(*clientConfigFlag); err != nil {
+			fatal("Error loading Impala client configuration", "err", err)
+		}
+	}
+	enableOpenMetrics = *queryExemplarsFlag
+	if *relabelConfigFlag != "" {
+		if relabelRules, err = loadRelabelConfig(*relabelConfigFlag); err != nil {
+			fatal("Error loading relabel configuration", "err", err)
+		}
+	}
+	fetchRetries, fetchBackoff = max(*retriesFlag, 0), *retryBackoffFlag
+	maxResponseBytes = max(*maxResponseFlag, 0)
+	if *captureDirFlag != "" {
+		if *captureCountFlag <= 0 {
+			fatal("-debug.capture-count must be positive")
+		}
+		responseCapture = &captureStore{dir: *captureDirFlag, keep: *captureCountFlag}
+		slog.Warn("Saving the raw Impala responses, which hold user names and statements", "dir", *captureDirFlag)
+	}
+
+	discoverers, err := buildDiscoverers()
+	if err != nil {
+		fatal("Error configuring discovery", "err", err)
+	}
+	_, apiTokenFromEnv := os.LookupEnv(apiTokenEnv)
+	if *impalaServersFlag == "" && len(clusters) == 0 && *apiTokenFileFlag == "" && !apiTokenFromEnv && len(discoverers) == 0 && len(fixtureServerList) == 0 {
+		fatal("Please provide at least one Impala server address using the -impala_servers or -cluster flag, enable the targets API or a discovery backend.")
 	}
 
 	// Split the comma-separated string into a slice of server addresses
-	impalaServers := strings.Split(*impalaServersFlag, ",")
+	var impalaServers []string
+	if *impalaServersFlag == "-" {
+		servers, err := readServers(os.Stdin)
+		if err != nil {
+			fatal("Error reading Impala server addresses from stdin", "err", err)
+		}
+		if len(servers) == 0 {
+			fatal("Please provide at least one Impala server address on stdin.")
+		}
+		impalaServers = servers
+	} else if *impalaServersFlag != "" {
+		impalaServers = strings.Split(*impalaServersFlag, ",")
+	} else {
+		// Without servers configured, every server of the fixture directory is scraped
+		impalaServers = fixtureServerList
+	}
 
-	exporter := NewExporter(impalaServers)
-	prometheus.MustRegister(exporter)
+	// /metrics covers every configured server, including those of named clusters
+	for _, cluster := range clusters {
+		impalaServers = append(impalaServers, cluster.Servers...)
+	}
+	impalaServers = dedupeServers(impalaServers)
+	if err := checkTargetNames(impalaServers); err != nil {
+		fatal("Invalid Impala server list", "err", err)
+	}
+
+	if !slices.Contains(clientLabelModes, *clientLabelFlag) {
+		fatal("Invalid client label mode", "mode", *clientLabelFlag)
+	}
+	if *aggregateClientsFlag && *clientLabelFlag != "keep" {
+		fatal("-sessions.aggregate-clients and -sessions.client-label are mutually exclusive")
+	}
+	timezone, err := time.LoadLocation(*timezoneFlag)
+	if err != nil {
+		fatal("Invalid time zone", "err", err)
+	}
+	clientInclude, err := compileClientPattern(*clientIncludeFlag)
+	if err != nil {
+		fatal("Invalid client include pattern", "err", err)
+	}
+	clientExclude, err := compileClientPattern(*clientExcludeFlag)
+	if err != nil {
+		fatal("Invalid client exclude pattern", "err", err)
+	}
+	if !model.IsValidLegacyMetricName(*namespaceFlag) {
+		fatal("Invalid metric namespace", "namespace", *namespaceFlag)
+	}
+	canary, err := canaryConfigFromFlags()
+	if err != nil {
+		fatal("Invalid canary configuration", "err", err)
+	}
+	clientPorts, err := clientPortsConfigFromFlags(canary.auth)
+	if err != nil {
+		fatal("Invalid client ports", "err", err)
+	}
+	executorGroupMinSizes, err := parseExecutorGroupMinSizes(*executorGroupMinSizesFlag)
+	if err != nil {
+		fatal("Invalid executor group minimum sizes", "err", err)
+	}
+	var slowLog *slowQueryLog
+	if *slowLogFileFlag != "" {
+		if slowLog, err = newSlowQueryLog(*slowLogFileFlag, *slowLogThresholdFlag, *slowLogMaxSizeFlag, *slowLogMaxFilesFlag); err != nil {
+			fatal("Error opening slow query log", "err", err)
+		}
+		defer slowLog.Close()
+	}
+	options := ExporterOptions{
+		StuckProgressPercent:   *stuckProgressFlag,
+		StuckMinDuration:       *stuckDurationFlag,
+		ScrubLiterals:          *scrubLiteralsFlag,
+		SlowQueryLog:           slowLog,
+		QueryEventThreshold:    *sinkQueryThresholdFlag,
+		Canary:                 canary,
+		ClientPorts:            clientPorts,
+		TopUsers:               *topUsersFlag,
+		ClientLabel:            *clientLabelFlag,
+		QueryExemplars:         *queryExemplarsFlag,
+		QueryInfoLimit:         *queryInfoFlag,
+		AggregateClients:       *aggregateClientsFlag,
+		ClusterAggregates:      *clusterAggregatesFlag,
+		HeartbeatTimeout:       *heartbeatTimeoutFlag,
+		FlagAllowlist:          dedupeServers(strings.Split(*flagAllowlistFlag, ",")),
+		ExecutorGroupMinSizes:  executorGroupMinSizes,
+		Timezone:               timezone,
+		ClientInclude:          clientInclude,
+		ClientExclude:          clientExclude,
+		ScrapeTimeout:          *scrapeTimeoutFlag,
+		ScrapeInterval:         *scrapeIntervalFlag,
+		MaxConcurrentTargets:   *scrapeConcurrencyFlag,
+		Collectors:             enabledCollectors(),
+		Namespace:              *namespaceFlag,
+		LegacySlowQueryMetrics: *legacySlowFlag,
+		SnapshotMaxAge:         *snapshotMaxAgeFlag,
+		BreakerThreshold:       *breakerThresholdFlag,
+		BreakerProbeInterval:   *breakerProbeFlag,
+		MaxProfilesPerScrape:   *maxProfilesFlag,
+		ProfileThreshold:       *profileThresholdFlag,
+		QueryRetries:           *queryRetriesFlag,
+		SpillingQueries:        *spillingQueriesFlag,
+		CSVExport:              *enableCSVExportFlag,
+		FingerprintLimit:       *fingerprintLimitFlag,
+	}
+	if *queryOptionUsageFlag {
+		options.TrackedQueryOptions = dedupeServers(strings.Split(strings.ToUpper(*trackedOptionsFlag), ","))
+	}
+	exporter := NewExporter(impalaServers, options)
+	exporter.SetClusters(clusters)
+	// The exporter is gathered per /metrics request, in the context of the request, see metricsHandler
+	prometheus.MustRegister(newBuildInfoCollector(), sinkEventsDropped, sinkQueueLength, fetchRetriesTotal, unexpectedResponses, metricsRequestsRejected)
+	prometheus.MustRegister(discoveryCollectors...)
+
+	// Background loops run under a supervisor restarting any that wedge
+	sup := newSupervisor(10 * time.Second)
+	defer sup.Close()
+	prometheus.MustRegister(sup)
+
+	if *updateManifestFlag != "" {
+		prometheus.MustRegister(updateAvailable, latestVersionInfo)
+		updates := startUpdateChecker(sup, *updateManifestFlag, *updateIntervalFlag)
+		defer updates.Close()
+	}
+
+	discoveryMgr := startDiscovery(sup, discoverers, exporter, *discoveryIntervalFlag)
+	defer discoveryMgr.Close()
+
+	var ready atomic.Bool
+	mux := http.NewServeMux()
+	mux.Handle("GET /{$}", landingHandler(exporter, clusters))
+	mux.Handle("/healthz", healthzHandler())
+	mux.Handle("/readyz", readyzHandler(&ready, exporter, *readyAfterScrapeFlag))
+	mux.Handle("GET /api/v1/capacity", capacityHandler(exporter))
+	registerStatusAPI(mux, exporter, start)
+	// Every metrics endpoint draws from the same slots, each request scrapes servers that may be shared
+	var metricsSlots chan struct{}
+	if *maxRequestsFlag > 0 {
+		metricsSlots = make(chan struct{}, *maxRequestsFlag)
+	}
+	mux.Handle("/metrics", limitRequests(metricsSlots, metricsHandler(prometheus.DefaultRegisterer, prometheus.DefaultGatherer, exporter, labels)))
+	exporters := map[string]*Exporter{"scrape": exporter}
+	for _, cluster := range clusters {
+		handler, clusterExporter := clusterHandler(cluster, options, labels)
+		mux.Handle("/metrics/"+cluster.Name, limitRequests(metricsSlots, handler))
+		exporters["scrape/"+cluster.Name] = clusterExporter
+	}
+	if options.ScrapeInterval > 0 {
+		for name, e := range exporters {
+			loop := startBackgroundScrape(sup, name, e, options.ScrapeInterval)
+			defer loop.Stop()
+		}
+	}
+
+	var atRest *atRestCipher
+	var keyErr error
+	if *encryptionKeyFileFlag != "" {
+		atRest, keyErr = loadAtRestCipher(*encryptionKeyFileFlag)
+	} else if key, ok := os.LookupEnv(encryptionKeyEnv); ok {
+		atRest, keyErr = newAtRestCipher([]byte(key))
+	}
+	if keyErr != nil {
+		fatal("Error loading encryption key", "err", keyErr)
+	}
+	if atRest != nil {
+		atRest.allowPlaintext = *allowPlaintextFlag
+	}
+	if *snapshotFileFlag != "" {
+		if err := exporter.LoadSnapshots(*snapshotFileFlag, atRest); err != nil {
+			slog.Warn("Error loading snapshots, starting without them", "file", *snapshotFileFlag, "err", err)
+		}
+	}
+
+	if token, ok, err := readSecret(*apiTokenFileFlag, apiTokenEnv); err != nil {
+		fatal("Error reading API token file", "err", err)
+	} else if ok {
+		if token == "" {
+			fatal("The API token is empty.")
+		}
+		store, err := NewTargetStore(*stateFileFlag, atRest, exporter)
+		if err != nil {
+			fatal("Error initializing targets API", "err", err)
+		}
+		registerTargetsAPI(mux, store, token)
+		if *enableKillFlag {
+			registerActionsAPI(mux, exporter, token)
+		}
+	} else if *enableKillFlag {
+		fatal("-web.enable-kill-action needs the API token, see -api.token-file")
+	}
+	if *enablePprofFlag {
+		registerPprof(mux)
+	}
+	if *enableDebugQueriesFlag {
+		mux.Handle("GET /debug/queries", debugQueriesHandler(exporter))
+	}
+	if *enableCSVExportFlag {
+		mux.Handle("GET /export/csv", csvExportHandler(exporter))
+	}
+
+	sinks, err := buildSinks()
+	if err != nil {
+		fatal("Error configuring sinks", "err", err)
+	}
+	gatherer := withRelabeling(withLabels(exporterGatherer(prometheus.DefaultGatherer, exporter, context.Background()), labels), relabelRules)
+	sinkMgr, err := startSinks(sup, sinks, gatherer, *sinkIntervalFlag, *sinkBufferFlag)
+	if err != nil {
+		fatal("Error starting sinks", "err", err)
+	}
+	defer sinkMgr.Close()
+	if len(sinks) > 0 {
+		exporter.SetEventPublisher(sinkMgr.Publish)
+	}
 
-	http.Handle("/metrics", promhttp.Handler())
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", *portFlag),
+		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{fmt.Sprintf(":%s", *portFlag)},
+		WebSystemdSocket:   new(bool),
+		WebConfigFile:      webConfigFlag,
+	}
 
-	fmt.Printf("Starting server on %s/metrics\n", srv.Addr)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Error starting HTTP server: %v", err)
+	// Stop accepting new scrapes on SIGINT/SIGTERM and give in-flight ones time to finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- web.ListenAndServe(srv, webFlags, slog.Default())
+	}()
+
+	ready.Store(true)
+	slog.Info("Starting server", "address", ":"+*portFlag, "version", version)
+	select {
+	case err := <-errCh:
+		fatal("Error starting HTTP server", "err", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for in-flight scrapes", "timeout", *shutdownTimeoutFlag)
+	ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeoutFlag)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down HTTP server", "err", err)
+	}
+	if *snapshotFileFlag != "" {
+		if err := exporter.SaveSnapshots(*snapshotFileFlag, atRest); err != nil {
+			slog.Error("Error saving snapshots", "file", *snapshotFileFlag, "err", err)
+		}
 	}
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestReadServers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"single", "10.11.18.16:25000\n", []string{"10.11.18.16:25000"}},
+		{"no trailing newline", "a:25000\nb:25000", []string{"a:25000", "b:25000"}},
+		{"blank lines", "\na:25000\n\n\nb:25000\n\n", []string{"a:25000", "b:25000"}},
+		{"comments", "# coordinators\na:25000\n#b:25000\n", []string{"a:25000"}},
+		{"whitespace", "  a:25000\t\n\t b:25000  \n   \n", []string{"a:25000", "b:25000"}},
+		{"indented comment", "   # disabled\na:25000\n", []string{"a:25000"}},
+		{"empty", "", nil},
+		{"only comments and blanks", "# nothing\n\n  \n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readServers(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("readServers(%q) returned error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readServers(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{"500ms", 0.5, false},
+		{"800ms", 0.8, false},
+		{"1s500ms", 1.5, false},
+		{"35s500ms", 35.5, false},
+		{"12m3s", 723, false},
+		{"2h3m", 7380, false},
+		{"1h2m3s4ms", 3723.004, false},
+		{"1.2s", 1.2, false},
+		{"2d3h", 183600, false},
+		{"1d0h0m5s", 86405, false},
+		{"1.5d", 129600, false},
+		{"345.678us", 0.000345678, false},
+		{"120ns", 0.00000012, false},
+		{"10m", 600, false},
+		{" 3s ", 3, false},
+		{"N/A", 0, true},
+		{"", 0, true},
+		{"5x", 0, true},
+		{"3s garbage", 0, true},
+		{"garbage 3s", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   float64
+		wantOK bool
+	}{
+		{"3 / 10 ( 30%)", 30, true},
+		{"0 / 10 (  0%)", 0, true},
+		{"10 / 10 (100%)", 100, true},
+		{"1 / 100 ( 1%)", 1, true},
+		{"0 / 0 (  0%)", 0, false},
+		{"N/A", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseProgress(tt.input)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ParseProgress(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExporterNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      string
+	}{
+		{"", "impala_total_connections"},
+		{"impala", "impala_total_connections"},
+		{"impala_prod", "impala_prod_total_connections"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			e := NewExporter(nil, ExporterOptions{Namespace: tt.namespace})
+			if got := e.totalConnections.String(); !strings.Contains(got, `fqName: "`+tt.want+`"`) {
+				t.Errorf("namespace %q: got descriptor %s, want name %q", tt.namespace, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectSkipsSlowTargets(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sessions":
+			w.Write([]byte(`{"client_hosts": [{"hostname": "client", "total_sessions": 2}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer fast.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	fastAddr := strings.TrimPrefix(fast.URL, "http://")
+	slowAddr := strings.TrimPrefix(slow.URL, "http://")
+	e := NewExporter([]string{"fast=" + fastAddr, "slow=" + slowAddr}, ExporterOptions{ScrapeTimeout: 200 * time.Millisecond})
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.Collect(ch)
+		close(ch)
+	}()
+	servers := make(map[string]bool)
+	infos := make(map[string]bool)
+	up := make(map[string]float64)
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatalf("writing metric: %v", err)
+		}
+		for _, label := range metric.Label {
+			if label.GetName() != "impala_server" {
+				continue
+			}
+			switch m.Desc() {
+			case e.targetInfo:
+				infos[label.GetValue()] = true
+			case e.up:
+				up[label.GetValue()] = metric.GetGauge().GetValue()
+			default:
+				servers[label.GetValue()] = true
+			}
+		}
+	}
+	if !servers["fast"] {
+		t.Errorf("no metrics from the fast target")
+	}
+	if servers["slow"] {
+		t.Errorf("got metrics from the slow target, want it skipped")
+	}
+	if !infos["fast"] || !infos["slow"] {
+		t.Errorf("got target info for %v, want both targets", infos)
+	}
+	if want := map[string]float64{"fast": 1, "slow": 0}; !maps.Equal(up, want) {
+		t.Errorf("got up %v, want %v", up, want)
+	}
+}
+
+func TestSlowQueryMetrics(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sessions":
+			w.Write([]byte(`{}`))
+		case "/queries":
+			w.Write([]byte(`{"in_flight_queries": [{"duration": "45s"}, {"duration": "2m30s"}, {"duration": "800ms"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer impala.Close()
+	server := strings.TrimPrefix(impala.URL, "http://")
+
+	tests := []struct {
+		name   string
+		legacy bool
+		want   map[string]float64
+	}{
+		{
+			name: "consolidated",
+			want: map[string]float64{
+				`impala_slow_queries{threshold="10s"}`: 2,
+				`impala_slow_queries{threshold="30s"}`: 2,
+				`impala_slow_queries{threshold="1m"}`:  1,
+				`impala_slow_queries{threshold="2m"}`:  1,
+				`impala_slow_queries{threshold="3m"}`:  0,
+				`impala_slow_queries{threshold="5m"}`:  0,
+				`impala_slow_queries{threshold="10m"}`: 0,
+			},
+		},
+		{
+			name:   "legacy",
+			legacy: true,
+			want: map[string]float64{
+				`impala_slow_queries{threshold="30s"}`: 2,
+				`impala_slow30s_queries_count`:         2,
+				`impala_slow2m_queries_count`:          1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(NewExporter([]string{server}, ExporterOptions{LegacySlowQueryMetrics: tt.legacy}))
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather: %v", err)
+			}
+			got := make(map[string]float64)
+			for _, family := range families {
+				if !strings.HasPrefix(family.GetName(), "impala_slow") {
+					continue
+				}
+				for _, m := range family.Metric {
+					key := family.GetName()
+					for _, label := range m.Label {
+						if label.GetName() == "threshold" {
+							key += `{threshold="` + label.GetValue() + `"}`
+						}
+					}
+					got[key] = m.GetGauge().GetValue()
+				}
+			}
+			for key, want := range tt.want {
+				if v, ok := got[key]; !ok || v != want {
+					t.Errorf("%s = %v (present %v), want %v", key, v, ok, want)
+				}
+			}
+			if _, ok := got["impala_slow3m_queries_count"]; ok {
+				t.Errorf("legacy metric for a threshold no query exceeded was exported")
+			}
+			if !tt.legacy {
+				if _, ok := got["impala_slow30s_queries_count"]; ok {
+					t.Errorf("legacy metrics exported without LegacySlowQueryMetrics")
+				}
+			}
+		})
+	}
+}
+
+func TestWaitingToCloseQueries(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"num_waiting_queries": 2, "in_flight_queries": [
+			{"duration": "1s", "executing": true, "waiting": false},
+			{"duration": "5m", "executing": false, "waiting": true},
+			{"duration": "1h", "executing": false, "waiting": true}
+		]}`))
+	}))
+	defer impala.Close()
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (queriesCollector{e}).Collect(context.Background(), ch, Target{Name: "coord", Address: strings.TrimPrefix(impala.URL, "http://")}); err != nil {
+			t.Error(err)
+		}
+	})
+	if got["impala_inflight_queries_count"] != 3 || got["impala_waiting_to_close_queries"] != 2 {
+		t.Errorf("in-flight = %v, waiting to close = %v, want 3 and 2", got["impala_inflight_queries_count"], got["impala_waiting_to_close_queries"])
+	}
+}
+
+// descNameRe extracts the metric name from the string form of a descriptor
+var descNameRe = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// collectValues runs collect and returns the values it sent, keyed by metric name and labels other than
+// impala_server, e.g. impala_rpc_calls_total{method="X",service="Y"}
+func collectValues(t *testing.T, e *Exporter, collect func(ch chan<- prometheus.Metric)) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatalf("writing metric: %v", err)
+		}
+		var labels []string
+		for _, label := range metric.Label {
+			if label.GetName() != "impala_server" {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+		}
+		key := e.descMeta[m.Desc()].name
+		if key == "" {
+			// Descriptors of the exporter's own metrics are package-level and not recorded in descMeta
+			key = descNameRe.FindStringSubmatch(m.Desc().String())[1]
+		}
+		if len(labels) > 0 {
+			key += "{" + strings.Join(labels, ",") + "}"
+		}
+		values[key] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+	}
+	return values
+}
+
+func TestCollectLimitsConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	})
+	var servers []string
+	for range 6 {
+		impala := httptest.NewServer(handler)
+		defer impala.Close()
+		servers = append(servers, strings.TrimPrefix(impala.URL, "http://"))
+	}
+
+	e := NewExporter(servers, ExporterOptions{MaxConcurrentTargets: 2})
+	got := collectValues(t, e, e.Collect)
+	if _, ok := got["impala_target_data_age_seconds"]; !ok {
+		t.Errorf("no server was scraped completely")
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("%d servers were scraped at once, want at most 2", p)
+	}
+}
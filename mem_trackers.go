@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// memTrackerAttrRe matches an attribute of a memory tracker in the breakdown of /memz, e.g. "Total=1.25 GB"
+var memTrackerAttrRe = regexp.MustCompile(`\b(\w+)=(-?[0-9.]+(?: [KMGTP]?B)?)`)
+
+// memTracker is a memory tracker of the breakdown of /memz
+type memTracker struct {
+	name string
+	// depth is 0 for the process tracker, 1 for its children and so on
+	depth       int
+	consumption ByteSize
+}
+
+// parseMemTrackers parses the memory tracker breakdown of /memz, one tracker per line indented by two spaces per
+// level, e.g.
+//
+//	Process: Limit=8.00 GB Total=1.25 GB Peak=2.00 GB
+//	  Buffer Pool: Free Buffers: Total=0
+//	  RequestPool=root.default: Total=512.00 MB Peak=1.00 GB
+//	    Query(4a4c...): Reservation=8.00 MB Limit=2.00 GB Total=500.00 MB Peak=700.00 MB
+//
+// Lines without a Total, such as the JVM heap summary, are left out.
+func parseMemTrackers(detailed string) []memTracker {
+	var trackers []memTracker
+	for _, line := range strings.Split(detailed, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		attrs := memTrackerAttrRe.FindAllStringSubmatchIndex(trimmed, -1)
+		// The name ends at the first attribute preceded by a space: pool trackers are named RequestPool=<pool>
+		nameEnd := -1
+		var total string
+		for _, attr := range attrs {
+			if attr[0] == 0 || trimmed[attr[0]-1] != ' ' {
+				continue
+			}
+			if nameEnd < 0 {
+				nameEnd = attr[0]
+			}
+			if trimmed[attr[2]:attr[3]] == "Total" {
+				total = trimmed[attr[4]:attr[5]]
+			}
+		}
+		if nameEnd < 0 || total == "" {
+			continue
+		}
+		consumption, err := parseByteSize(total)
+		if err != nil {
+			continue
+		}
+		trackers = append(trackers, memTracker{
+			name:        strings.TrimSuffix(strings.TrimSpace(trimmed[:nameEnd]), ":"),
+			depth:       (len(line) - len(trimmed)) / 2,
+			consumption: consumption,
+		})
+	}
+	return trackers
+}
+
+// memTrackersCollector exports the consumption of the process memory tracker of a daemon and of its children, such
+// as the buffer pool or the request pools, so that it can be told what is using its memory. The trackers of queries
+// and fragments below them are left out, as one series each would be unbounded.
+type memTrackersCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_mem_tracker_consumption_bytes over to the provided channel
+func (c memTrackersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.memTrackerConsumption
+}
+
+// Collect fetches the memory tracker breakdown of a daemon and sends the consumption of its top-level trackers over
+// to the provided channel
+func (c memTrackersCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var memz MemzResponse
+	if err := fetchJSON(ctx, target.Address, "/memz?json", &memz); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, tracker := range parseMemTrackers(memz.Detailed) {
+		// A tracker name repeated among the children would be a duplicate series failing the scrape
+		if tracker.depth > 1 || seen[tracker.name] {
+			continue
+		}
+		seen[tracker.name] = true
+		ch <- prometheus.MustNewConstMetric(e.memTrackerConsumption, prometheus.GaugeValue, float64(tracker.consumption), target.Name, tracker.name)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const memzDetailed = `Process: Limit=8.00 GB Total=1.25 GB Peak=2.00 GB
+  JVM: max heap size: Total=4.00 GB
+  Buffer Pool: Free Buffers: Total=16.00 MB
+  Buffer Pool: Clean Pages: Total=0
+  Untracked Memory: Total=100.00 MB
+  RequestPool=root.default: Total=512.00 MB Peak=1.00 GB
+    Query(4a4c3b2a1f0e9d8c:7b6a5c4d00000000): Reservation=8.00 MB ReservationLimit=6.40 GB OtherMemory=4.00 MB Total=500.00 MB Peak=700.00 MB
+      Fragment 4a4c3b2a1f0e9d8c:7b6a5c4d00000001: Reservation=8.00 MB OtherMemory=2.00 MB Total=10.00 MB Peak=12.00 MB
+`
+
+func TestParseMemTrackers(t *testing.T) {
+	got := parseMemTrackers(memzDetailed)
+	want := []memTracker{
+		{"Process", 0, 1.25 * (1 << 30)},
+		{"JVM: max heap size", 1, 4 << 30},
+		{"Buffer Pool: Free Buffers", 1, 16 << 20},
+		{"Buffer Pool: Clean Pages", 1, 0},
+		{"Untracked Memory", 1, 100 << 20},
+		{"RequestPool=root.default", 1, 512 << 20},
+		{"Query(4a4c3b2a1f0e9d8c:7b6a5c4d00000000)", 2, 500 << 20},
+		{"Fragment 4a4c3b2a1f0e9d8c:7b6a5c4d00000001", 3, 10 << 20},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseMemTrackers() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tracker %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMemTrackersCollector(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"mem_limit": "8.00 GB", "consumption": "1.25 GB", "detailed": ` + strconv.Quote(memzDetailed) + `}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (memTrackersCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	// The query and fragment trackers are left out
+	want := map[string]float64{
+		`impala_mem_tracker_consumption_bytes{tracker="Process"}`:                   1.25 * (1 << 30),
+		`impala_mem_tracker_consumption_bytes{tracker="JVM: max heap size"}`:        4 << 30,
+		`impala_mem_tracker_consumption_bytes{tracker="Buffer Pool: Free Buffers"}`: 16 << 20,
+		`impala_mem_tracker_consumption_bytes{tracker="Buffer Pool: Clean Pages"}`:  0,
+		`impala_mem_tracker_consumption_bytes{tracker="Untracked Memory"}`:          100 << 20,
+		`impala_mem_tracker_consumption_bytes{tracker="RequestPool=root.default"}`:  512 << 20,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// contextCollector collects an Exporter within the context of a single scrape
+type contextCollector struct {
+	exporter *Exporter
+	ctx      context.Context
+}
+
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.exporter.Describe(ch)
+}
+
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.CollectContext(c.ctx, ch)
+}
+
+// exporterGatherer gathers base along with exporter collected within ctx, its series labeled by the role of their
+// server when detected
+func exporterGatherer(base prometheus.Gatherer, exporter *Exporter, ctx context.Context) prometheus.Gatherer {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(contextCollector{exporter: exporter, ctx: ctx})
+	return prometheus.Gatherers{base, withRoleLabels(reg, exporter)}
+}
+
+// enableOpenMetrics serves the OpenMetrics format to the scrapers asking for it, which exemplars need; it is only set
+// with -queries.exemplars since OpenMetrics renames counters not ending in _total
+var enableOpenMetrics bool
+
+// metricsHandler serves the metrics of base and exporter with the given constant labels and relabelRules applied,
+// instrumented on reg.
+// The exporter is collected within the context of each request, so when Prometheus gives up on a scrape the
+// requests to the Impala servers are cancelled instead of running on for nobody.
+func metricsHandler(reg prometheus.Registerer, base prometheus.Gatherer, exporter *Exporter, labels map[string]string) http.Handler {
+	return promhttp.InstrumentMetricHandler(reg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gatherer := withRelabeling(withLabels(exporterGatherer(base, exporter, r.Context()), labels), relabelRules)
+		promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: enableOpenMetrics}).ServeHTTP(w, r)
+	}))
+}
+
+var metricsRequestsRejected = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "impala_exporter_metrics_requests_rejected_total",
+	Help: "Number of metrics requests rejected with 503 because -web.max-requests were already being served",
+})
+
+// limitRequests serves next with at most cap(slots) requests at once across every handler sharing slots, rejecting
+// the excess with 503 Service Unavailable; a nil slots channel does not limit the requests
+func limitRequests(slots chan struct{}, next http.Handler) http.Handler {
+	if slots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		default:
+			metricsRequestsRejected.Inc()
+			http.Error(w, fmt.Sprintf("Limit of concurrent requests reached (%d), try again later.", cap(slots)), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsHandlerCancelsFetchesWithRequest(t *testing.T) {
+	started := make(chan struct{}, 10)
+	cancelled := make(chan struct{}, 10)
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+	}))
+	defer impala.Close()
+
+	e := NewExporter([]string{impala.Listener.Addr().String()}, ExporterOptions{})
+	reg := prometheus.NewRegistry()
+	srv := httptest.NewServer(metricsHandler(reg, reg, e, nil))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	go func() {
+		<-started
+		cancel()
+	}()
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request to the Impala server not cancelled with the scrape")
+	}
+}
+
+func TestMetricsHandlerServesExporterAndBase(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+
+	e := NewExporter([]string{"coord=" + impala.Listener.Addr().String()}, ExporterOptions{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "base_metric", Help: "help"}))
+
+	rec := httptest.NewRecorder()
+	metricsHandler(reg, reg, e, map[string]string{"dc": "eu1"}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`base_metric{dc="eu1"} 0`, `impala_up{dc="eu1",impala_server="coord"} 1`, "promhttp_metric_handler_requests_total"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	slots := make(chan struct{}, 1)
+	handler := limitRequests(slots, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		done <- rec.Code
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("concurrent request got %d, want 503", rec.Code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first request got %d, want 200", code)
+	}
+
+	// The slot is released once the first request is done
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("request after the first got %d, want 200", rec.Code)
+	}
+}
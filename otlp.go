@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const otlpTokenEnv = "IMPALA_EXPORTER_OTLP_TOKEN"
+
+// otlpGRPCMethod is the path of the gRPC method the metrics are exported with
+const otlpGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+var (
+	otlpEndpointFlag  = flag.String("otlp.endpoint", "", "URL of an OpenTelemetry Collector OTLP receiver the metrics are exported to every -sink.interval, e.g. http://otel-collector:4318, or http://otel-collector:4317 with -otlp.protocol=grpc; enables the otlp sink")
+	otlpProtocolFlag  = flag.String("otlp.protocol", "http/protobuf", "Protocol of -otlp.endpoint, http/protobuf, exporting to its /v1/metrics path, or grpc, over TLS with an https endpoint")
+	otlpTokenFileFlag = flag.String("otlp.token-file", "", "Path of a file holding the bearer token sent to -otlp.endpoint; when unset the token is read from $"+otlpTokenEnv+", and none is sent without either")
+	otlpTimeoutFlag   = flag.Duration("otlp.timeout", 30*time.Second, "Timeout of an export to -otlp.endpoint")
+)
+
+func init() {
+	RegisterSink("otlp", func() (Sink, error) {
+		if *otlpEndpointFlag == "" {
+			return nil, nil
+		}
+		endpoint, err := url.Parse(*otlpEndpointFlag)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q", *otlpEndpointFlag)
+		}
+		token, _, err := readSecret(*otlpTokenFileFlag, otlpTokenEnv)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		s := &otlpSink{token: token, grpc: *otlpProtocolFlag == "grpc"}
+		switch *otlpProtocolFlag {
+		case "http/protobuf":
+			s.url = endpoint.JoinPath("/v1/metrics").String()
+			s.client = &http.Client{Timeout: *otlpTimeoutFlag}
+		case "grpc":
+			s.url = endpoint.JoinPath(otlpGRPCMethod).String()
+			transport := &http2.Transport{}
+			if endpoint.Scheme == "http" {
+				// gRPC without TLS speaks HTTP/2 over cleartext from the first byte
+				transport.AllowHTTP = true
+				transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, network, addr)
+				}
+			}
+			s.client = &http.Client{Transport: transport, Timeout: *otlpTimeoutFlag}
+		default:
+			return nil, fmt.Errorf("unknown protocol %q, want http/protobuf or grpc", *otlpProtocolFlag)
+		}
+		return s, nil
+	})
+}
+
+// otlpSink exports every metrics snapshot to an OpenTelemetry Collector, or any other OTLP receiver, over OTLP/HTTP
+// with a protobuf body or over OTLP/gRPC
+type otlpSink struct {
+	url    string
+	token  string
+	grpc   bool
+	client *http.Client
+}
+
+func (s *otlpSink) Name() string {
+	return "otlp"
+}
+
+func (s *otlpSink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit exports the metrics of a snapshot event, skipping the other events. A snapshot the receiver rejects is
+// dropped rather than retried, the next one supersedes it.
+func (s *otlpSink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 {
+		return nil
+	}
+	body := encodeExportMetricsRequest(event.Metrics, event.Time)
+	contentType := "application/x-protobuf"
+	if s.grpc {
+		// A gRPC message is framed by an uncompressed flag byte and its length
+		frame := make([]byte, 5, 5+len(body))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+		body, contentType = append(frame, body...), "application/grpc"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.grpc {
+		req.Header.Set("TE", "trailers")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP receiver responded with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	if s.grpc {
+		// The status of a gRPC call comes in the trailers, or in the headers of a response without a body
+		status, msg := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+		if status == "" {
+			status, msg = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+		}
+		if status != "0" {
+			return fmt.Errorf("OTLP receiver responded with gRPC status %q: %s", status, msg)
+		}
+	}
+	return nil
+}
+
+func (s *otlpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// encodeExportMetricsRequest encodes the gathered families as an ExportMetricsServiceRequest protobuf message of a
+// single resource and instrumentation scope, both named after the exporter:
+//
+//	message ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	message ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	message Resource { repeated KeyValue attributes = 1; }
+//	message ScopeMetrics { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	message InstrumentationScope { string name = 1; }
+//
+// Counters become monotonic cumulative sums, gauges and untyped metrics gauges, and histograms and summaries keep
+// their type. The points carry the timestamp of their metric, or now when it has none.
+func encodeExportMetricsRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendBytes(scope, protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "impala_exporter"))
+	for _, family := range families {
+		if metric := encodeOTLPMetric(family, now); metric != nil {
+			scope = protowire.AppendTag(scope, 2, protowire.BytesType)
+			scope = protowire.AppendBytes(scope, metric)
+		}
+	}
+
+	var resource []byte
+	resource = protowire.AppendTag(resource, 1, protowire.BytesType)
+	resource = protowire.AppendBytes(resource, encodeKeyValue("service.name", "impala_exporter"))
+
+	var resourceMetrics []byte
+	resourceMetrics = protowire.AppendTag(resourceMetrics, 1, protowire.BytesType)
+	resourceMetrics = protowire.AppendBytes(resourceMetrics, resource)
+	resourceMetrics = protowire.AppendTag(resourceMetrics, 2, protowire.BytesType)
+	resourceMetrics = protowire.AppendBytes(resourceMetrics, scope)
+
+	var request []byte
+	request = protowire.AppendTag(request, 1, protowire.BytesType)
+	return protowire.AppendBytes(request, resourceMetrics)
+}
+
+// encodeOTLPMetric encodes a family as a Metric protobuf message, or returns nil for a type OTLP has no match for:
+//
+//	message Metric { string name = 1; string description = 2; Gauge gauge = 5; Sum sum = 7; Histogram histogram = 9; Summary summary = 11; }
+//	message Gauge { repeated NumberDataPoint data_points = 1; }
+//	message Sum { repeated NumberDataPoint data_points = 1; AggregationTemporality aggregation_temporality = 2; bool is_monotonic = 3; }
+//	message Histogram { repeated HistogramDataPoint data_points = 1; AggregationTemporality aggregation_temporality = 2; }
+//	message Summary { repeated SummaryDataPoint data_points = 1; }
+func encodeOTLPMetric(family *dto.MetricFamily, now time.Time) []byte {
+	var field protowire.Number
+	var data []byte
+	for _, m := range family.Metric {
+		var point []byte
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			field, point = 5, encodeNumberDataPoint(m, m.GetGauge().GetValue(), now)
+		case dto.MetricType_UNTYPED:
+			field, point = 5, encodeNumberDataPoint(m, m.GetUntyped().GetValue(), now)
+		case dto.MetricType_COUNTER:
+			field, point = 7, encodeNumberDataPoint(m, m.GetCounter().GetValue(), now)
+		case dto.MetricType_HISTOGRAM:
+			field, point = 9, encodeHistogramDataPoint(m, now)
+		case dto.MetricType_SUMMARY:
+			field, point = 11, encodeSummaryDataPoint(m, now)
+		default:
+			return nil
+		}
+		data = protowire.AppendTag(data, 1, protowire.BytesType)
+		data = protowire.AppendBytes(data, point)
+	}
+	if data == nil {
+		return nil
+	}
+	if field == 7 || field == 9 {
+		// AGGREGATION_TEMPORALITY_CUMULATIVE
+		data = protowire.AppendTag(data, 2, protowire.VarintType)
+		data = protowire.AppendVarint(data, 2)
+	}
+	if field == 7 {
+		data = protowire.AppendTag(data, 3, protowire.VarintType)
+		data = protowire.AppendVarint(data, protowire.EncodeBool(true))
+	}
+
+	var metric []byte
+	metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+	metric = protowire.AppendString(metric, family.GetName())
+	if family.GetHelp() != "" {
+		metric = protowire.AppendTag(metric, 2, protowire.BytesType)
+		metric = protowire.AppendString(metric, family.GetHelp())
+	}
+	metric = protowire.AppendTag(metric, field, protowire.BytesType)
+	return protowire.AppendBytes(metric, data)
+}
+
+// encodeNumberDataPoint encodes a NumberDataPoint protobuf message:
+//
+//	message NumberDataPoint { repeated KeyValue attributes = 7; fixed64 time_unix_nano = 3; double as_double = 4; }
+func encodeNumberDataPoint(m *dto.Metric, value float64, now time.Time) []byte {
+	point := encodeAttributes(nil, 7, m)
+	point = appendTimeUnixNano(point, m, now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	return protowire.AppendFixed64(point, math.Float64bits(value))
+}
+
+// encodeHistogramDataPoint encodes a HistogramDataPoint protobuf message, turning the cumulative bucket counts of
+// Prometheus into the per-bucket counts of OTLP, the last bucket counting the samples above the highest bound:
+//
+//	message HistogramDataPoint {
+//	  repeated KeyValue attributes = 9; fixed64 time_unix_nano = 3; fixed64 count = 4; double sum = 5;
+//	  repeated fixed64 bucket_counts = 6; repeated double explicit_bounds = 7;
+//	}
+func encodeHistogramDataPoint(m *dto.Metric, now time.Time) []byte {
+	h := m.GetHistogram()
+	var counts, bounds []byte
+	previous := uint64(0)
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, b.GetCumulativeCount()-previous)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(b.GetUpperBound()))
+		previous = b.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, h.GetSampleCount()-previous)
+
+	point := encodeAttributes(nil, 9, m)
+	point = appendTimeUnixNano(point, m, now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, h.GetSampleCount())
+	point = protowire.AppendTag(point, 5, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(h.GetSampleSum()))
+	point = protowire.AppendTag(point, 6, protowire.BytesType)
+	point = protowire.AppendBytes(point, counts)
+	if len(bounds) > 0 {
+		point = protowire.AppendTag(point, 7, protowire.BytesType)
+		point = protowire.AppendBytes(point, bounds)
+	}
+	return point
+}
+
+// encodeSummaryDataPoint encodes a SummaryDataPoint protobuf message:
+//
+//	message SummaryDataPoint {
+//	  repeated KeyValue attributes = 7; fixed64 time_unix_nano = 3; fixed64 count = 4; double sum = 5;
+//	  repeated ValueAtQuantile quantile_values = 6;
+//	}
+//	message ValueAtQuantile { double quantile = 1; double value = 2; }
+func encodeSummaryDataPoint(m *dto.Metric, now time.Time) []byte {
+	s := m.GetSummary()
+	point := encodeAttributes(nil, 7, m)
+	point = appendTimeUnixNano(point, m, now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, s.GetSampleCount())
+	point = protowire.AppendTag(point, 5, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(s.GetSampleSum()))
+	for _, q := range s.Quantile {
+		var v []byte
+		v = protowire.AppendTag(v, 1, protowire.Fixed64Type)
+		v = protowire.AppendFixed64(v, math.Float64bits(q.GetQuantile()))
+		v = protowire.AppendTag(v, 2, protowire.Fixed64Type)
+		v = protowire.AppendFixed64(v, math.Float64bits(q.GetValue()))
+		point = protowire.AppendTag(point, 6, protowire.BytesType)
+		point = protowire.AppendBytes(point, v)
+	}
+	return point
+}
+
+// encodeAttributes appends the labels of a metric as the KeyValue attributes in field num of a data point
+func encodeAttributes(b []byte, num protowire.Number, m *dto.Metric) []byte {
+	for _, l := range m.Label {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeKeyValue(l.GetName(), l.GetValue()))
+	}
+	return b
+}
+
+// appendTimeUnixNano appends the time_unix_nano field, 3 in every data point, of a metric timestamped now unless it
+// carries its own timestamp
+func appendTimeUnixNano(b []byte, m *dto.Metric, now time.Time) []byte {
+	t := now
+	if m.TimestampMs != nil {
+		t = time.UnixMilli(m.GetTimestampMs())
+	}
+	b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(t.UnixNano()))
+}
+
+// encodeKeyValue encodes a KeyValue protobuf message of a string value:
+//
+//	message KeyValue { string key = 1; AnyValue value = 2; }
+//	message AnyValue { string string_value = 1; }
+func encodeKeyValue(key, value string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, key)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	return protowire.AppendBytes(b, protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), value))
+}
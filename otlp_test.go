@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpFields decodes a protobuf message into its fields by number, keeping the raw bytes of length-delimited fields
+// and the values of fixed64 ones
+func otlpFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		value := b[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(b)
+		}
+		fields[num] = append(fields[num], value)
+		b = b[n:]
+	}
+	return fields
+}
+
+// otlpMetrics decodes an ExportMetricsServiceRequest into its Metric messages by name
+func otlpMetrics(t *testing.T, request []byte) map[string]map[protowire.Number][][]byte {
+	t.Helper()
+	metrics := make(map[string]map[protowire.Number][][]byte)
+	for _, resourceMetrics := range otlpFields(t, request)[1] {
+		for _, scope := range otlpFields(t, resourceMetrics)[2] {
+			for _, metric := range otlpFields(t, scope)[2] {
+				fields := otlpFields(t, metric)
+				metrics[string(fields[1][0])] = fields
+			}
+		}
+	}
+	return metrics
+}
+
+func gatherOTLPTestFamilies(t *testing.T) []*dto.MetricFamily {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up", Help: "Up"}, []string{"impala_server"})
+	up.WithLabelValues("coord:25000").Set(1)
+	retried := prometheus.NewCounter(prometheus.CounterOpts{Name: "impala_retried_queries_total", Help: "Retried"})
+	retried.Add(3)
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "impala_query_duration_seconds", Help: "Duration", Buckets: []float64{1, 10}})
+	for _, v := range []float64{0.5, 5, 5, 50} {
+		duration.Observe(v)
+	}
+	registry.MustRegister(up, retried, duration)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
+
+func TestEncodeExportMetricsRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	metrics := otlpMetrics(t, encodeExportMetricsRequest(gatherOTLPTestFamilies(t), now))
+	if len(metrics) != 3 {
+		t.Fatalf("encoded %d metrics, want 3", len(metrics))
+	}
+
+	gauge := otlpFields(t, metrics["impala_up"][5][0])
+	point := otlpFields(t, gauge[1][0])
+	if value := math.Float64frombits(binary.LittleEndian.Uint64(point[4][0])); value != 1 {
+		t.Errorf("impala_up = %v, want 1", value)
+	}
+	if ts := binary.LittleEndian.Uint64(point[3][0]); ts != uint64(now.UnixNano()) {
+		t.Errorf("impala_up time = %d, want %d", ts, now.UnixNano())
+	}
+	attribute := otlpFields(t, point[7][0])
+	if key, value := string(attribute[1][0]), string(otlpFields(t, attribute[2][0])[1][0]); key != "impala_server" || value != "coord:25000" {
+		t.Errorf("impala_up attribute = %s=%s, want impala_server=coord:25000", key, value)
+	}
+
+	sum := otlpFields(t, metrics["impala_retried_queries_total"][7][0])
+	if len(sum[2]) != 1 || len(sum[3]) != 1 {
+		t.Errorf("impala_retried_queries_total is not a cumulative monotonic sum: %v", sum)
+	}
+
+	histogram := otlpFields(t, metrics["impala_query_duration_seconds"][9][0])
+	point = otlpFields(t, histogram[1][0])
+	var counts []uint64
+	for b := point[6][0]; len(b) > 0; b = b[8:] {
+		counts = append(counts, binary.LittleEndian.Uint64(b))
+	}
+	if len(counts) != 3 || counts[0] != 1 || counts[1] != 2 || counts[2] != 1 {
+		t.Errorf("impala_query_duration_seconds bucket counts = %v, want [1 2 1]", counts)
+	}
+	if len(point[7][0]) != 16 {
+		t.Errorf("impala_query_duration_seconds has %d bytes of bounds, want 2 bounds", len(point[7][0]))
+	}
+}
+
+func TestOTLPSinkHTTP(t *testing.T) {
+	var path, contentType string
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	s := &otlpSink{url: receiver.URL + "/v1/metrics", client: receiver.Client()}
+	if err := s.Emit(context.Background(), Event{Time: time.Now(), Metrics: gatherOTLPTestFamilies(t)}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if path != "/v1/metrics" || contentType != "application/x-protobuf" || len(otlpMetrics(t, body)) != 3 {
+		t.Errorf("exported %d bytes of %s to %s, want the metrics to /v1/metrics", len(body), contentType, path)
+	}
+}
+
+func TestOTLPSinkGRPC(t *testing.T) {
+	var path string
+	var body []byte
+	status := "0"
+	receiver := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Grpc-Status", status)
+	}), &http2.Server{}))
+	defer receiver.Close()
+
+	*otlpEndpointFlag, *otlpProtocolFlag = receiver.URL, "grpc"
+	defer func() { *otlpEndpointFlag, *otlpProtocolFlag = "", "http/protobuf" }()
+	sink, err := sinkFactories["otlp"]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.Emit(context.Background(), Event{Time: time.Now(), Metrics: gatherOTLPTestFamilies(t)}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if path != otlpGRPCMethod || len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		t.Fatalf("exported %d bytes to %s, want a gRPC message to %s", len(body), path, otlpGRPCMethod)
+	}
+	if got := len(otlpMetrics(t, body[5:])); got != 3 {
+		t.Errorf("exported %d metrics, want 3", got)
+	}
+
+	status = "14"
+	if err := sink.Emit(context.Background(), Event{Time: time.Now(), Metrics: gatherOTLPTestFamilies(t)}); err == nil {
+		t.Error("Emit() succeeded on a gRPC error status")
+	}
+}
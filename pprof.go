@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof on the given mux
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	pushGatewayURLFlag = flag.String("push.gateway-url", "", "URL of a Prometheus Pushgateway the metrics are pushed to every -sink.interval, for exporters that cannot be scraped, e.g. behind a NAT; enables the pushgateway sink")
+	pushJobFlag        = flag.String("push.job", "impala_exporter", "Job label of the metrics pushed to -push.gateway-url")
+	pushInstanceFlag   = flag.String("push.instance", "", "Instance label of the metrics pushed to -push.gateway-url, to tell several exporters pushing to the same gateway apart")
+)
+
+func init() {
+	RegisterSink("pushgateway", func() (Sink, error) {
+		if *pushGatewayURLFlag == "" {
+			return nil, nil
+		}
+		if u, err := url.Parse(*pushGatewayURLFlag); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid gateway URL %q", *pushGatewayURLFlag)
+		}
+		if *pushJobFlag == "" {
+			return nil, fmt.Errorf("empty job")
+		}
+		return &pushgatewaySink{
+			url:      *pushGatewayURLFlag,
+			job:      *pushJobFlag,
+			instance: *pushInstanceFlag,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	})
+}
+
+// pushgatewaySink pushes every metrics snapshot to a Pushgateway, replacing the metrics of the previous push
+type pushgatewaySink struct {
+	url      string
+	job      string
+	instance string
+	client   *http.Client
+}
+
+func (s *pushgatewaySink) Name() string {
+	return "pushgateway"
+}
+
+func (s *pushgatewaySink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit pushes the metrics of a snapshot event, skipping the other events. The whole group is replaced, so that the
+// series of a server that went away do not linger on the gateway.
+func (s *pushgatewaySink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 {
+		return nil
+	}
+	pusher := push.New(s.url, s.job).
+		Client(s.client).
+		Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return event.Metrics, nil }))
+	if s.instance != "" {
+		pusher = pusher.Grouping("instance", s.instance)
+	}
+	return pusher.PushContext(ctx)
+}
+
+func (s *pushgatewaySink) Close() error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPushgatewaySink(t *testing.T) {
+	var method, path string
+	var body []byte
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up"}, []string{"impala_server"})
+	up.WithLabelValues("coord").Set(1)
+	registry.MustRegister(up)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &pushgatewaySink{url: gateway.URL, job: "impala_exporter", instance: "dc1", client: gateway.Client()}
+	// Events without metrics are skipped
+	if err := s.Emit(context.Background(), Event{Time: time.Now(), LongQuery: &slowQueryEntry{QueryID: "q1"}}); err != nil || method != "" {
+		t.Errorf("Emit() of a query event = %v, pushed %s %s", err, method, path)
+	}
+	if err := s.Emit(context.Background(), Event{Time: time.Now(), Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	// PUT replaces the whole group
+	if method != http.MethodPut || path != "/metrics/job/impala_exporter/instance/dc1" || len(body) == 0 {
+		t.Errorf("pushed %s %s with %d bytes, want a PUT of the metrics to the group", method, path, len(body))
+	}
+}
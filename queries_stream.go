@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// decodeQueries streams the /queries?json document of r, calling inFlight and completed for each query of its
+// in_flight_queries and completed_queries arrays in turn. Only one query is decoded at a time, and the other
+// members of the document are skipped token by token, so memory stays flat however many queries are reported.
+func decodeQueries(r io.Reader, inFlight func(InFlightQuery), completed func(CompletedQuery)) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "in_flight_queries":
+			err = decodeArray(dec, inFlight)
+		case "completed_queries":
+			err = decodeArray(dec, completed)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray decodes the JSON array at the position of dec one element at a time, calling each for every element.
+// A null array has no elements.
+func decodeArray[T any](dec *json.Decoder, each func(T)) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: reflect.TypeFor[[]T](), Offset: dec.InputOffset()}
+	}
+	for dec.More() {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		each(item)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// skipValue reads past the JSON value at the position of dec without holding it in memory
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// expectDelim reads the delimiter delim of the /queries?json document from dec
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if tok != delim {
+		return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: reflect.TypeFor[map[string]any](), Offset: dec.InputOffset()}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeQueries(t *testing.T) {
+	doc := `{
+		"num_executing_queries": 2,
+		"in_flight_queries": [
+			{"query_id": "a", "duration": "1m", "progress": "1 / 10 ( 10%)", "plan": {"nodes": [[1, 2], {"x": null}]}},
+			{"query_id": "b", "duration": "2s", "progress": "0 / 0 ( 0%)", "executing": false, "waiting": true}
+		],
+		"completed_log_size": 25,
+		"completed_queries": [{"query_id": "c"}, {"query_id": "d"}],
+		"query_locations": null
+	}`
+	var inFlight []InFlightQuery
+	var completed []string
+	err := decodeQueries(strings.NewReader(doc), func(q InFlightQuery) {
+		inFlight = append(inFlight, q)
+	}, func(q CompletedQuery) {
+		completed = append(completed, q.QueryID)
+	})
+	if err != nil {
+		t.Fatalf("decodeQueries() error = %v", err)
+	}
+	want := []InFlightQuery{{QueryID: "a", Duration: "1m", Progress: "1 / 10 ( 10%)"}, {QueryID: "b", Duration: "2s", Progress: "0 / 0 ( 0%)", Waiting: true}}
+	if !slices.Equal(inFlight, want) {
+		t.Errorf("in-flight queries = %v, want %v", inFlight, want)
+	}
+	if !slices.Equal(completed, []string{"c", "d"}) {
+		t.Errorf("completed queries = %q, want c, d", completed)
+	}
+}
+
+func TestDecodeQueriesNullArrays(t *testing.T) {
+	calls := 0
+	err := decodeQueries(strings.NewReader(`{"in_flight_queries": null, "completed_queries": []}`),
+		func(InFlightQuery) { calls++ }, func(CompletedQuery) { calls++ })
+	if err != nil || calls != 0 {
+		t.Errorf("decodeQueries() = %v with %d calls, want no error and no calls", err, calls)
+	}
+}
+
+func TestDecodeQueriesErrors(t *testing.T) {
+	for _, doc := range []string{`<html>`, `[]`, `{"in_flight_queries": {}}`, `{"in_flight_queries": [{"duration": 1}]}`} {
+		err := decodeQueries(strings.NewReader(doc), func(InFlightQuery) {}, func(CompletedQuery) {})
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+			t.Errorf("decodeQueries(%s) error = %v, want a JSON syntax or type error", doc, err)
+		}
+	}
+	if err := decodeQueries(strings.NewReader(`{"in_flight_queries": [{"duration": "1s"}`), func(InFlightQuery) {}, func(CompletedQuery) {}); err == nil {
+		t.Error("decodeQueries() of a truncated document succeeded")
+	}
+}
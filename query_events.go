@@ -0,0 +1,46 @@
+package main
+
+import "sync"
+
+// queryTracker remembers, per server, which in-flight queries were above a threshold as of the previous scrape, so
+// that every query crossing it is handled once
+type queryTracker struct {
+	mu   sync.Mutex
+	seen map[string]map[string]bool
+}
+
+// report calls handle for the queries of server above the threshold not handled by a previous scrape. A query
+// handle fails for is offered again on the next scrape; queries no longer listed are forgotten.
+func (t *queryTracker) report(server string, queries []slowQueryEntry, handle func(slowQueryEntry) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seen == nil {
+		t.seen = make(map[string]map[string]bool)
+	}
+	previous := t.seen[server]
+	current := make(map[string]bool, len(queries))
+	for _, query := range queries {
+		if previous[query.QueryID] || handle(query) {
+			current[query.QueryID] = true
+		}
+	}
+	t.seen[server] = current
+}
+
+// SetEventPublisher makes the exporter publish an event for every in-flight query running longer than
+// ExporterOptions.QueryEventThreshold, once per query. Until it is called no query event is published.
+func (e *Exporter) SetEventPublisher(publish func(Event)) {
+	e.publish.Store(&publish)
+}
+
+// publishLongQueries publishes an event for each of the queries of server not published by a previous scrape
+func (e *Exporter) publishLongQueries(server string, queries []slowQueryEntry) {
+	publish := e.publish.Load()
+	if publish == nil {
+		return
+	}
+	e.longQueries.report(server, queries, func(query slowQueryEntry) bool {
+		(*publish)(Event{Time: query.Time, LongQuery: &query})
+		return true
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queryDurationHistogram accumulates the durations of the in-flight queries of a server over the slow query
+// thresholds, keeping the slowest query of each bucket as its exemplar
+type queryDurationHistogram struct {
+	// counts and slowest are per bucket, the last one above the highest threshold
+	counts  []uint64
+	slowest []exemplarQuery
+	sum     float64
+}
+
+// exemplarQuery is the slowest query of a bucket
+type exemplarQuery struct {
+	id      string
+	seconds float64
+}
+
+func newQueryDurationHistogram() *queryDurationHistogram {
+	return &queryDurationHistogram{
+		counts:  make([]uint64, len(slowQueryThresholds)+1),
+		slowest: make([]exemplarQuery, len(slowQueryThresholds)+1),
+	}
+}
+
+// observe records a query that has been running for seconds
+func (h *queryDurationHistogram) observe(queryID string, seconds float64) {
+	i := len(slowQueryThresholds)
+	for j, threshold := range slowQueryThresholds {
+		if seconds <= float64(threshold.seconds) {
+			i = j
+			break
+		}
+	}
+	h.counts[i]++
+	h.sum += seconds
+	if queryID != "" && (h.slowest[i].id == "" || seconds > h.slowest[i].seconds) {
+		h.slowest[i] = exemplarQuery{id: queryID, seconds: seconds}
+	}
+}
+
+// metric returns the histogram of server with the query_id of the slowest query of each bucket as exemplar.
+// The bucket up to the lowest threshold holds no slow query and gets none.
+func (h *queryDurationHistogram) metric(desc *prometheus.Desc, server string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(slowQueryThresholds))
+	var cumulative uint64
+	for i, threshold := range slowQueryThresholds {
+		cumulative += h.counts[i]
+		buckets[float64(threshold.seconds)] = cumulative
+	}
+	count := cumulative + h.counts[len(slowQueryThresholds)]
+	histogram := prometheus.MustNewConstHistogram(desc, count, h.sum, buckets, server)
+
+	var exemplars []prometheus.Exemplar
+	for _, query := range h.slowest[1:] {
+		if query.id != "" {
+			exemplars = append(exemplars, prometheus.Exemplar{Value: query.seconds, Labels: prometheus.Labels{"query_id": query.id}})
+		}
+	}
+	if len(exemplars) == 0 {
+		return histogram
+	}
+	return prometheus.MustNewMetricWithExemplars(histogram, exemplars...)
+}
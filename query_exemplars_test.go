@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestQueryDurationHistogram(t *testing.T) {
+	h := newQueryDurationHistogram()
+	h.observe("fast", 2)
+	h.observe("a", 45)
+	h.observe("b", 50)
+	h.observe("", 55)
+	h.observe("old", 900)
+	desc := prometheus.NewDesc("impala_inflight_query_duration_seconds", "", []string{"impala_server"}, nil)
+	var m dto.Metric
+	if err := h.metric(desc, "coord").Write(&m); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	hist := m.GetHistogram()
+	if hist.GetSampleCount() != 5 || hist.GetSampleSum() != 1052 {
+		t.Errorf("count, sum = %d, %v, want 5, 1052", hist.GetSampleCount(), hist.GetSampleSum())
+	}
+	counts := make(map[float64]uint64)
+	exemplars := make(map[float64]string)
+	for _, b := range hist.Bucket {
+		counts[b.GetUpperBound()] = b.GetCumulativeCount()
+		for _, l := range b.GetExemplar().GetLabel() {
+			exemplars[b.GetUpperBound()] = l.GetValue()
+		}
+	}
+	if counts[10] != 1 || counts[30] != 1 || counts[60] != 4 || counts[600] != 4 {
+		t.Errorf("bucket counts = %v", counts)
+	}
+	// The query up to 10s is not slow, the unnamed one is slower than b but cannot serve as exemplar
+	if len(exemplars) != 2 || exemplars[60] != "b" || exemplars[math.Inf(1)] != "old" {
+		t.Errorf("exemplars = %v, want b in le=60 and old in le=+Inf", exemplars)
+	}
+}
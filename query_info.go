@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runningQuery is an in-flight query with its parsed duration
+type runningQuery struct {
+	InFlightQuery
+	seconds float64
+}
+
+// longestQueries keeps the longest running in-flight queries of a server, up to limit, while the queries are
+// decoded, so that only about twice the limit is ever held
+type longestQueries struct {
+	limit   int
+	queries []runningQuery
+}
+
+// add records a query, dropping the shortest running ones once twice the limit is held
+func (l *longestQueries) add(query InFlightQuery, seconds float64) {
+	l.queries = append(l.queries, runningQuery{InFlightQuery: query, seconds: seconds})
+	if len(l.queries) >= 2*l.limit {
+		l.truncate()
+	}
+}
+
+// truncate keeps the limit longest running queries, longest first
+func (l *longestQueries) truncate() {
+	slices.SortStableFunc(l.queries, func(a, b runningQuery) int {
+		switch {
+		case a.seconds > b.seconds:
+			return -1
+		case a.seconds < b.seconds:
+			return 1
+		}
+		return 0
+	})
+	if len(l.queries) > l.limit {
+		l.queries = l.queries[:l.limit]
+	}
+}
+
+// collect sends an info metric per kept query of server, reachable at coordinator, with its duration as value
+func (l *longestQueries) collect(ch chan<- prometheus.Metric, desc *prometheus.Desc, server, coordinator string) {
+	l.truncate()
+	for _, q := range l.queries {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, q.seconds, server, q.QueryID, q.EffectiveUser, q.ResourcePool, q.State, coordinator)
+	}
+}
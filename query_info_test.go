@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectQueryInfo(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"in_flight_queries": [
+			{"query_id": "q1", "effective_user": "alice", "resource_pool": "root.etl", "state": "RUNNING", "duration": "5s"},
+			{"query_id": "q2", "effective_user": "bob", "resource_pool": "root.adhoc", "state": "RUNNING", "duration": "3m"},
+			{"query_id": "q3", "effective_user": "carol", "resource_pool": "root.adhoc", "state": "CREATED", "duration": "1s"},
+			{"query_id": "q4", "effective_user": "alice", "resource_pool": "root.etl", "state": "RUNNING", "duration": "40s"},
+			{"query_id": "q5", "effective_user": "dave", "resource_pool": "root.etl", "state": "FINISHED", "duration": "2s"}
+		]}`))
+	}))
+	defer impala.Close()
+
+	address := strings.TrimPrefix(impala.URL, "http://")
+	e := NewExporter(nil, ExporterOptions{QueryInfoLimit: 2})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (queriesCollector{e}).Collect(context.Background(), ch, newTarget(address, "")); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	info := make(map[string]float64)
+	for key, v := range got {
+		if strings.HasPrefix(key, "impala_inflight_query_info{") {
+			info[key] = v
+		}
+	}
+	coordinator := `coordinator="` + address + `"`
+	want := map[string]float64{
+		`impala_inflight_query_info{` + coordinator + `,pool="root.adhoc",query_id="q2",state="RUNNING",user="bob"}`: 180,
+		`impala_inflight_query_info{` + coordinator + `,pool="root.etl",query_id="q4",state="RUNNING",user="alice"}`: 40,
+	}
+	if !maps.Equal(info, want) {
+		t.Errorf("got %v, want %v", info, want)
+	}
+}
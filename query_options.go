@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultTrackedQueryOptions are the query options whose overrides are counted unless -queries.tracked-options says otherwise
+const defaultTrackedQueryOptions = "MEM_LIMIT,DISABLE_CODEGEN,NUM_NODES,MT_DOP,EXEC_TIME_LIMIT_S,QUERY_TIMEOUT_S,REQUEST_POOL,RUNTIME_FILTER_MODE,SCRATCH_LIMIT,BUFFER_POOL_LIMIT"
+
+// queryOptionsRe matches the profile line listing the query options set by the client or session
+var queryOptionsRe = regexp.MustCompile(`(?m)^\s*Query Options \(set by configuration\): (.*)$`)
+
+// queryOptionNameRe matches a query option name, telling a new option apart from a value containing a comma
+var queryOptionNameRe = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// CompletedQuery represents a single completed query in the JSON response from Impala for /queries
+type CompletedQuery struct {
+	QueryID       string `json:"query_id"`
+	EffectiveUser string `json:"effective_user"`
+	ResourcePool  string `json:"resource_pool"`
+	Duration      string `json:"duration"`
+	Stmt          string `json:"stmt"`
+	State         string `json:"state"`
+	// OriginalQueryID is set on the retry of a query, to the id of the query it retries
+	OriginalQueryID string `json:"original_query_id"`
+}
+
+// QueryProfileResponse represents the structure of the JSON response from Impala for /query_profile
+type QueryProfileResponse struct {
+	Profile string `json:"profile"`
+}
+
+// parseQueryOptions returns the names of the query options set by configuration in a query profile
+func parseQueryOptions(profile string) []string {
+	matches := queryOptionsRe.FindStringSubmatch(profile)
+	if matches == nil {
+		return nil
+	}
+	var options []string
+	for _, part := range strings.Split(matches[1], ",") {
+		name, _, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if ok && queryOptionNameRe.MatchString(name) && !slices.Contains(options, name) {
+			options = append(options, name)
+		}
+	}
+	return options
+}
+
+// queryProfileBudget bounds the time spent fetching query profiles in a scrape of a server
+const queryProfileBudget = 2 * time.Second
+
+// completedQueries tracks which completed queries of a server had their profile accounted for
+type completedQueries struct {
+	// counted holds the completed queries already accounted for; nil until the first scrape
+	counted map[string]bool
+}
+
+// pendingQueries returns up to limit completed queries whose profile has not been counted yet, and forgets the
+// counted queries Impala no longer lists, so the set stays as bounded as Impala's log of completed queries.
+// On the first scrape the whole log is taken as counted, so that a restart does not count old queries again.
+func (u *completedQueries) pendingQueries(queries []CompletedQuery, limit int) []CompletedQuery {
+	first := u.counted == nil
+	counted := make(map[string]bool, len(queries))
+	var pending []CompletedQuery
+	for _, q := range queries {
+		if first || u.counted[q.QueryID] {
+			counted[q.QueryID] = true
+		} else if len(pending) < limit {
+			pending = append(pending, q)
+		}
+	}
+	u.counted = counted
+	return pending
+}
+
+// queryOptionUsage accumulates, per server, how many completed queries overrode each tracked option
+type queryOptionUsage struct {
+	completedQueries
+	counts map[string]float64
+}
+
+// profileFetchBudget returns the context query profiles of a scrape are fetched in: within queryProfileBudget, and
+// at most half the time left to the scrape, so slow profiles cannot make the scrape of the server time out
+func profileFetchBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	budget := queryProfileBudget
+	if deadline, ok := ctx.Deadline(); ok {
+		budget = min(budget, time.Until(deadline)/2)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// collectQueryOptions fetches the profiles of newly completed queries and sends the query option usage counters of a
+// server over to the provided channel.
+// It runs after the other metrics of the server within profileFetchBudget. Queries whose profile could not be
+// fetched in time are retried in the next scrape.
+func (e *Exporter) collectQueryOptions(ctx context.Context, ch chan<- prometheus.Metric, target Target, completed []CompletedQuery) {
+	if len(e.options.TrackedQueryOptions) == 0 {
+		return
+	}
+	server := target.Name
+
+	e.queryOptionsMu.Lock()
+	usage, ok := e.queryOptionUsage[server]
+	if !ok {
+		usage = &queryOptionUsage{counts: make(map[string]float64)}
+		e.queryOptionUsage[server] = usage
+	}
+	pending := usage.pendingQueries(completed, e.options.MaxProfilesPerScrape)
+	e.queryOptionsMu.Unlock()
+
+	ctx, cancel := profileFetchBudget(ctx)
+	defer cancel()
+
+	for _, query := range pending {
+		id := query.QueryID
+		var resp QueryProfileResponse
+		if err := fetchJSON(ctx, target.Address, "/query_profile?json&query_id="+url.QueryEscape(id), &resp); err != nil {
+			slog.Debug("Error fetching query profile", "target", server, "endpoint", "/query_profile?json", "query_id", id, "err", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		e.queryOptionsMu.Lock()
+		for _, option := range parseQueryOptions(resp.Profile) {
+			if slices.Contains(e.options.TrackedQueryOptions, option) {
+				usage.counts[option]++
+			}
+		}
+		usage.counted[id] = true
+		e.queryOptionsMu.Unlock()
+	}
+
+	e.queryOptionsMu.Lock()
+	defer e.queryOptionsMu.Unlock()
+	for _, option := range e.options.TrackedQueryOptions {
+		ch <- prometheus.MustNewConstMetric(e.queryOptionOverrides, prometheus.CounterValue, usage.counts[option], server, option)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQueryOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    []string
+	}{
+		{
+			name: "options set",
+			profile: `Query (id=5a4d5b2c8e1f7a3b:9c0d1e2f00000000):
+  Summary:
+    Session Type: HIVESERVER2
+    Query Options (set by configuration): MEM_LIMIT=2147483648,DISABLE_CODEGEN=1,CLIENT_IDENTIFIER=Impala Shell v4.1.0, (build abc)
+    Query Options (set by configuration and planner): MEM_LIMIT=2147483648,DISABLE_CODEGEN=1,MT_DOP=0
+`,
+			want: []string{"MEM_LIMIT", "DISABLE_CODEGEN", "CLIENT_IDENTIFIER"},
+		},
+		{name: "no options line", profile: "Query (id=1:2):\n  Summary:\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseQueryOptions(tt.profile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQueryOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingQueries(t *testing.T) {
+	u := &queryOptionUsage{}
+	queries := func(ids ...string) []CompletedQuery {
+		var queries []CompletedQuery
+		for _, id := range ids {
+			queries = append(queries, CompletedQuery{QueryID: id})
+		}
+		return queries
+	}
+	if got := u.pendingQueries(queries("a", "b"), 10); got != nil {
+		t.Errorf("first scrape returned %v, want nothing", got)
+	}
+	got := u.pendingQueries(queries("e", "d", "c", "b"), 2)
+	if want := queries("e", "d"); !reflect.DeepEqual(got, want) {
+		t.Errorf("limited scrape returned %v, want %v", got, want)
+	}
+	// Only "e" was fetched, "d" and "c" are still pending
+	u.counted["e"] = true
+	got = u.pendingQueries(queries("f", "e", "d", "c"), 10)
+	if want := queries("f", "d", "c"); !reflect.DeepEqual(got, want) {
+		t.Errorf("next scrape returned %v, want %v", got, want)
+	}
+	if u.counted["b"] {
+		t.Errorf("query b is still tracked after Impala stopped listing it")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QueryProfileEncodedResponse represents the structure of the JSON response from Impala for /query_profile_encoded:
+// the profile as a base64 encoded, zlib compressed TRuntimeProfileTree in the Thrift compact protocol
+type QueryProfileEncodedResponse struct {
+	Contents string `json:"contents"`
+}
+
+// profileNode is a node of a query profile with its counters
+type profileNode struct {
+	name     string
+	counters map[string]int64
+	children []*profileNode
+}
+
+// decodeProfile decodes an archived query profile as served by /query_profile_encoded into its tree of nodes
+func decodeProfile(archive string) (*profileNode, error) {
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(archive))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tree, err := newCompactReader(limitBody(zr, maxResponseBytes)).readStruct()
+	if err != nil {
+		return nil, err
+	}
+	// The nodes of TRuntimeProfileTree are listed depth first, each followed by its num_children children
+	nodes, _ := tree[1].v.([]thriftValue)
+	root, rest, err := profileTree(nodes)
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing nodes in query profile")
+	}
+	return root, err
+}
+
+// profileTree builds the node at the start of nodes and its descendants, returning the nodes that follow them
+func profileTree(nodes []thriftValue) (*profileNode, []thriftValue, error) {
+	if len(nodes) == 0 {
+		return nil, nil, errors.New("truncated query profile")
+	}
+	fields, _ := nodes[0].v.(thriftFields)
+	node := &profileNode{name: fields.str(1), counters: make(map[string]int64)}
+	counters, _ := fields[3].v.([]thriftValue)
+	for _, counter := range counters {
+		c, _ := counter.v.(thriftFields)
+		value, _ := c[3].v.(int64)
+		node.counters[c.str(1)] = value
+	}
+	numChildren, _ := fields.i32(2)
+	rest := nodes[1:]
+	for range numChildren {
+		child, next, err := profileTree(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.children = append(node.children, child)
+		rest = next
+	}
+	return node, rest, nil
+}
+
+// scanNodeRe matches the profile node of a scan operator, e.g. HDFS_SCAN_NODE (id=0)
+var scanNodeRe = regexp.MustCompile(`^[A-Z_]*SCAN_NODE \(id=\d+\)`)
+
+// queryProfileSummary holds the key counters of a query profile
+type queryProfileSummary struct {
+	// PeakMemoryBytes is the highest peak memory usage of a fragment instance
+	PeakMemoryBytes int64
+	// ScannedBytes is the number of bytes read by all scans
+	ScannedBytes int64
+	// RowsProduced is the number of rows the query returned to its client
+	RowsProduced int64
+	// ScanSkew is, for the most skewed scan operator, the ratio of the bytes read by its busiest instance to the
+	// average over its instances; 1 when no scan is skewed, 0 without scans
+	ScanSkew float64
+}
+
+// summarizeProfile extracts the key counters of the profile tree rooted at root. The operators of the fragment
+// instances, under the Instance nodes, are accounted for, not the averaged fragments.
+func summarizeProfile(root *profileNode) queryProfileSummary {
+	var summary queryProfileSummary
+	scans := make(map[string][]int64)
+	var walk func(node *profileNode, inInstance bool)
+	walk = func(node *profileNode, inInstance bool) {
+		if strings.HasPrefix(node.name, "Instance ") {
+			inInstance = true
+			summary.PeakMemoryBytes = max(summary.PeakMemoryBytes, node.counters["PeakMemoryUsage"])
+		}
+		if inInstance {
+			if scan := scanNodeRe.FindString(node.name); scan != "" {
+				summary.ScannedBytes += node.counters["BytesRead"]
+				scans[scan] = append(scans[scan], node.counters["BytesRead"])
+			}
+		}
+		summary.RowsProduced = max(summary.RowsProduced, node.counters["NumRowsFetched"])
+		for _, child := range node.children {
+			walk(child, inInstance)
+		}
+	}
+	walk(root, false)
+	for _, instances := range scans {
+		var total, busiest int64
+		for _, bytes := range instances {
+			total += bytes
+			busiest = max(busiest, bytes)
+		}
+		if total > 0 {
+			summary.ScanSkew = max(summary.ScanSkew, float64(busiest)*float64(len(instances))/float64(total))
+		}
+	}
+	return summary
+}
+
+// slowQueryProfiles tracks, per server, the slow completed queries whose profile was fetched
+type slowQueryProfiles struct {
+	completedQueries
+	profiled float64
+}
+
+// collectSlowProfiles fetches the profiles of the queries of a server that completed since the previous scrape and
+// ran longer than ProfileThreshold, logging their key counters, and sends the number of profiled queries over to
+// the provided channel. Like collectQueryOptions, it runs within profileFetchBudget and retries the profiles it
+// could not fetch in the next scrape.
+func (e *Exporter) collectSlowProfiles(ctx context.Context, ch chan<- prometheus.Metric, target Target, completed []CompletedQuery) {
+	threshold := e.options.ProfileThreshold
+	if threshold <= 0 {
+		return
+	}
+	server := target.Name
+
+	var slow []CompletedQuery
+	for _, query := range completed {
+		if seconds, err := ParseDuration(query.Duration); err == nil && seconds >= threshold.Seconds() {
+			slow = append(slow, query)
+		}
+	}
+	e.slowProfilesMu.Lock()
+	profiles, ok := e.slowProfiles[server]
+	if !ok {
+		profiles = &slowQueryProfiles{}
+		e.slowProfiles[server] = profiles
+	}
+	pending := profiles.pendingQueries(slow, e.options.MaxProfilesPerScrape)
+	e.slowProfilesMu.Unlock()
+
+	ctx, cancel := profileFetchBudget(ctx)
+	defer cancel()
+
+	for _, query := range pending {
+		summary, err := fetchProfileSummary(ctx, target.Address, query.QueryID)
+		if err != nil {
+			slog.Debug("Error fetching query profile", "target", server, "endpoint", "/query_profile_encoded", "query_id", query.QueryID, "err", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		seconds, _ := ParseDuration(query.Duration)
+		slog.Info("Profile of slow query", "target", server, "query_id", query.QueryID, "user", query.EffectiveUser,
+			"pool", query.ResourcePool, "duration_seconds", seconds, "peak_memory_bytes", summary.PeakMemoryBytes,
+			"scanned_bytes", summary.ScannedBytes, "rows_produced", summary.RowsProduced, "scan_skew", summary.ScanSkew)
+		e.slowProfilesMu.Lock()
+		profiles.profiled++
+		profiles.counted[query.QueryID] = true
+		e.slowProfilesMu.Unlock()
+	}
+
+	e.slowProfilesMu.Lock()
+	defer e.slowProfilesMu.Unlock()
+	ch <- prometheus.MustNewConstMetric(e.slowQueryProfiles, prometheus.CounterValue, profiles.profiled, server)
+}
+
+// fetchProfileSummary fetches the archived profile of a query and extracts its key counters
+func fetchProfileSummary(ctx context.Context, address, queryID string) (queryProfileSummary, error) {
+	var resp QueryProfileEncodedResponse
+	if err := fetchJSON(ctx, address, "/query_profile_encoded?json&query_id="+url.QueryEscape(queryID), &resp); err != nil {
+		return queryProfileSummary{}, err
+	}
+	root, err := decodeProfile(resp.Contents)
+	if err != nil {
+		return queryProfileSummary{}, err
+	}
+	return summarizeProfile(root), nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// compactTypeIDs maps the binary protocol types to those of the compact protocol
+var compactTypeIDs = map[byte]byte{
+	thriftBool: compactBoolTrue, thriftByte: 3, thriftI16: 4, thriftI32: 5, thriftI64: 6, thriftDouble: 7,
+	thriftString: 8, thriftList: compactList, thriftSet: compactSet, thriftMap: compactMap, thriftStruct: compactStruct,
+}
+
+func appendZigzag(b []byte, n int64) []byte {
+	return binary.AppendUvarint(b, uint64(n<<1^n>>63))
+}
+
+// appendCompactStruct encodes f in the Thrift compact protocol, as Impala archives query profiles
+func appendCompactStruct(b []byte, f thriftFields) []byte {
+	ids := make([]int16, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var last int16
+	for _, id := range ids {
+		typ := compactTypeIDs[f[id].typ]
+		if v, ok := f[id].v.(bool); ok && !v {
+			typ = compactBoolFalse
+		}
+		if delta := id - last; delta > 0 && delta <= 15 {
+			b = append(b, byte(delta)<<4|typ)
+		} else {
+			b = appendZigzag(append(b, typ), int64(id))
+		}
+		last = id
+		if f[id].typ != thriftBool {
+			b = appendCompactValue(b, f[id])
+		}
+	}
+	return append(b, thriftStop)
+}
+
+func appendCompactValue(b []byte, v thriftValue) []byte {
+	switch x := v.v.(type) {
+	case bool:
+		if x {
+			return append(b, compactBoolTrue)
+		}
+		return append(b, compactBoolFalse)
+	case int8:
+		return append(b, byte(x))
+	case int16:
+		return appendZigzag(b, int64(x))
+	case int32:
+		return appendZigzag(b, int64(x))
+	case int64:
+		return appendZigzag(b, x)
+	case float64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(x))
+	case []byte:
+		return append(binary.AppendUvarint(b, uint64(len(x))), x...)
+	case thriftFields:
+		return appendCompactStruct(b, x)
+	case []thriftValue:
+		if len(x) < 15 {
+			b = append(b, byte(len(x))<<4|compactTypeIDs[v.elem[0]])
+		} else {
+			b = binary.AppendUvarint(append(b, 0xf0|compactTypeIDs[v.elem[0]]), uint64(len(x)))
+		}
+		for _, item := range x {
+			b = appendCompactValue(b, item)
+		}
+		return b
+	case [][2]thriftValue:
+		b = binary.AppendUvarint(b, uint64(len(x)))
+		if len(x) > 0 {
+			b = append(b, compactTypeIDs[v.elem[0]]<<4|compactTypeIDs[v.elem[1]])
+		}
+		for _, entry := range x {
+			b = appendCompactValue(appendCompactValue(b, entry[0]), entry[1])
+		}
+		return b
+	}
+	panic("unsupported Thrift value")
+}
+
+func TestCompactReaderRoundTrip(t *testing.T) {
+	var items []thriftValue
+	for i := range 20 {
+		items = append(items, thriftI64Value(int64(i)-10))
+	}
+	want := thriftFields{
+		1:   thriftStringValue("name"),
+		2:   thriftBoolValue(true),
+		3:   thriftBoolValue(false),
+		4:   {typ: thriftByte, v: int8(-3)},
+		5:   {typ: thriftI16, v: int16(-300)},
+		6:   thriftI32Value(-70000),
+		7:   thriftI64Value(1 << 40),
+		8:   {typ: thriftDouble, v: 2.5},
+		9:   {typ: thriftList, elem: [2]byte{thriftI64}, v: items},
+		10:  {typ: thriftList, elem: [2]byte{thriftBool}, v: []thriftValue{thriftBoolValue(true), thriftBoolValue(false)}},
+		11:  {typ: thriftMap, elem: [2]byte{thriftString, thriftI32}, v: [][2]thriftValue{{thriftStringValue("k"), thriftI32Value(1)}}},
+		12:  {typ: thriftMap, v: [][2]thriftValue{}},
+		100: thriftStructValue(thriftFields{1: thriftStringValue("nested")}),
+	}
+	got, err := newCompactReader(bytes.NewReader(appendCompactStruct(nil, want))).readStruct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readStruct() = %v, want %v", got, want)
+	}
+}
+
+// testProfileNode encodes a TRuntimeProfileNode with the given counters
+func testProfileNode(name string, children int32, counters map[string]int64) thriftValue {
+	var list []thriftValue
+	for name, value := range counters {
+		list = append(list, thriftStructValue(thriftFields{1: thriftStringValue(name), 2: thriftI32Value(0), 3: thriftI64Value(value)}))
+	}
+	return thriftStructValue(thriftFields{
+		1: thriftStringValue(name),
+		2: thriftI32Value(children),
+		3: {typ: thriftList, elem: [2]byte{thriftStruct}, v: list},
+		4: thriftI64Value(-1),
+		5: thriftBoolValue(true),
+	})
+}
+
+// testProfileArchive archives nodes as /query_profile_encoded serves them
+func testProfileArchive(t *testing.T, nodes ...thriftValue) string {
+	t.Helper()
+	tree := thriftFields{1: {typ: thriftList, elem: [2]byte{thriftStruct}, v: nodes}}
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(appendCompactStruct(nil, tree))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeProfileSummary(t *testing.T) {
+	archive := testProfileArchive(t,
+		testProfileNode("Query (id=1:2)", 3, nil),
+		testProfileNode("Summary", 0, nil),
+		testProfileNode("ImpalaServer", 0, map[string]int64{"NumRowsFetched": 1000}),
+		testProfileNode("Execution Profile 1:2", 2, nil),
+		testProfileNode("Averaged Fragment F00", 1, map[string]int64{"PeakMemoryUsage": 1 << 40}),
+		testProfileNode("HDFS_SCAN_NODE (id=0)", 0, map[string]int64{"BytesRead": 1 << 40}),
+		testProfileNode("Fragment F00", 3, nil),
+		testProfileNode("Instance 1:3 (host=a:27000)", 1, map[string]int64{"PeakMemoryUsage": 300}),
+		testProfileNode("HDFS_SCAN_NODE (id=0)", 0, map[string]int64{"BytesRead": 600}),
+		testProfileNode("Instance 1:4 (host=b:27000)", 1, map[string]int64{"PeakMemoryUsage": 500}),
+		testProfileNode("HDFS_SCAN_NODE (id=0)", 0, map[string]int64{"BytesRead": 200}),
+		testProfileNode("Instance 1:5 (host=c:27000)", 1, map[string]int64{"PeakMemoryUsage": 100}),
+		testProfileNode("KUDU_SCAN_NODE (id=1) [dop=1]", 0, map[string]int64{"BytesRead": 100}),
+	)
+	root, err := decodeProfile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summarizeProfile(root)
+	want := queryProfileSummary{PeakMemoryBytes: 500, ScannedBytes: 900, RowsProduced: 1000, ScanSkew: 1.5}
+	if got != want {
+		t.Errorf("summarizeProfile() = %+v, want %+v", got, want)
+	}
+
+	if _, err := decodeProfile(testProfileArchive(t, testProfileNode("Query (id=1:2)", 2, nil))); err == nil {
+		t.Error("decodeProfile() of a truncated tree succeeded")
+	}
+	if _, err := decodeProfile("not base64!"); err == nil {
+		t.Error("decodeProfile() of garbage succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// retriedQueryState is the state /queries reports for a query that failed on a node failure and was transparently
+// retried, under a new query id, with -retry_failed_queries
+const retriedQueryState = "RETRIED"
+
+// queryRetries accumulates, per server, the completed queries that were retried and the retries that failed in turn
+type queryRetries struct {
+	completedQueries
+	retried, exhausted float64
+}
+
+// collectQueryRetries accounts for the queries of a server completed since the previous scrape and sends the
+// counts of retried queries and of queries whose retry failed too over to the provided channel. A query is retried
+// at most once, a retry, which carries the id of the query it retries, ending in an error exhausted its retries.
+func (e *Exporter) collectQueryRetries(ch chan<- prometheus.Metric, server string, completed []CompletedQuery) {
+	if !e.options.QueryRetries {
+		return
+	}
+	e.queryRetriesMu.Lock()
+	defer e.queryRetriesMu.Unlock()
+	retries, ok := e.queryRetries[server]
+	if !ok {
+		retries = &queryRetries{}
+		e.queryRetries[server] = retries
+	}
+	for _, query := range retries.pendingQueries(completed, len(completed)) {
+		retries.counted[query.QueryID] = true
+		switch {
+		case query.State == retriedQueryState:
+			retries.retried++
+		case query.OriginalQueryID != "" && query.State == "EXCEPTION":
+			retries.exhausted++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.retriedQueries, prometheus.CounterValue, retries.retried, server)
+	ch <- prometheus.MustNewConstMetric(e.retriesExhausted, prometheus.CounterValue, retries.exhausted, server)
+}
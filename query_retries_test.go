@@ -0,0 +1,37 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectQueryRetries(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{QueryRetries: true})
+	collect := func(completed []CompletedQuery) map[string]float64 {
+		return collectValues(t, e, func(ch chan<- prometheus.Metric) { e.collectQueryRetries(ch, "coord", completed) })
+	}
+	// The queries completed before the first scrape are not counted
+	completed := []CompletedQuery{{QueryID: "1:0", State: retriedQueryState}}
+	want := map[string]float64{"impala_retried_queries_total": 0, "impala_query_retries_exhausted_total": 0}
+	if got := collect(completed); !maps.Equal(got, want) {
+		t.Errorf("first scrape = %v, want %v", got, want)
+	}
+
+	completed = append(completed,
+		CompletedQuery{QueryID: "2:0", State: retriedQueryState},
+		CompletedQuery{QueryID: "3:0", State: "FINISHED", OriginalQueryID: "2:0"},
+		CompletedQuery{QueryID: "4:0", State: retriedQueryState},
+		CompletedQuery{QueryID: "5:0", State: "EXCEPTION", OriginalQueryID: "4:0"},
+		CompletedQuery{QueryID: "6:0", State: "EXCEPTION"},
+	)
+	want = map[string]float64{"impala_retried_queries_total": 2, "impala_query_retries_exhausted_total": 1}
+	if got := collect(completed); !maps.Equal(got, want) {
+		t.Errorf("second scrape = %v, want %v", got, want)
+	}
+	// Queries are counted once
+	if got := collect(completed); !maps.Equal(got, want) {
+		t.Errorf("third scrape = %v, want %v", got, want)
+	}
+}
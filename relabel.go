@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// relabelConfig is the -metric.relabel-config file, laid out like the metric_relabel_configs of a Prometheus scrape
+// config so that rules can be moved over from there
+type relabelConfig struct {
+	Rules []relabelRule `yaml:"metric_relabel_configs"`
+}
+
+// relabelRule is a rule of the -metric.relabel-config file. keep and drop keep or drop the series whose source
+// labels, joined with ";", match Regex; labeldrop removes the labels whose name matches Regex; rename renames the
+// single source label to TargetLabel. The metric name can be matched as the __name__ label.
+type relabelRule struct {
+	Action       string   `yaml:"action"`
+	SourceLabels []string `yaml:"source_labels"`
+	Regex        string   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+
+	re *regexp.Regexp
+}
+
+// relabelRules are applied in order to every gathered series, loaded from -metric.relabel-config
+var relabelRules []relabelRule
+
+// loadRelabelConfig reads and validates a -metric.relabel-config file
+func loadRelabelConfig(path string) ([]relabelRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config relabelConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i := range config.Rules {
+		if err := config.Rules[i].check(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return config.Rules, nil
+}
+
+// check reports an error for a rule that cannot be applied, and compiles its regex
+func (r *relabelRule) check() error {
+	switch r.Action {
+	case "keep", "drop":
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("%s needs source_labels", r.Action)
+		}
+	case "labeldrop":
+		if r.Regex == "" {
+			return errors.New("labeldrop needs a regex")
+		}
+	case "rename":
+		if len(r.SourceLabels) != 1 || r.SourceLabels[0] == model.MetricNameLabel {
+			return errors.New("rename needs a single source label other than __name__")
+		}
+		if !model.LabelName(r.TargetLabel).IsValidLegacy() || strings.HasPrefix(r.TargetLabel, "__") {
+			return fmt.Errorf("invalid target label %q", r.TargetLabel)
+		}
+	default:
+		return fmt.Errorf("invalid action %q, want keep, drop, labeldrop or rename", r.Action)
+	}
+	regex := r.Regex
+	if regex == "" {
+		regex = ".*"
+	}
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	r.re = re
+	return nil
+}
+
+// apply applies the rule to a series of the family name and reports whether the series is kept
+func (r *relabelRule) apply(name string, metric *dto.Metric) bool {
+	value := func(label string) string {
+		if label == model.MetricNameLabel {
+			return name
+		}
+		for _, pair := range metric.Label {
+			if pair.GetName() == label {
+				return pair.GetValue()
+			}
+		}
+		return ""
+	}
+	switch r.Action {
+	case "keep", "drop":
+		values := make([]string, len(r.SourceLabels))
+		for i, label := range r.SourceLabels {
+			values[i] = value(label)
+		}
+		return r.re.MatchString(strings.Join(values, ";")) == (r.Action == "keep")
+	case "labeldrop":
+		metric.Label = slices.DeleteFunc(metric.Label, func(l *dto.LabelPair) bool { return r.re.MatchString(l.GetName()) })
+	case "rename":
+		i := slices.IndexFunc(metric.Label, func(l *dto.LabelPair) bool { return l.GetName() == r.SourceLabels[0] })
+		if i < 0 {
+			return true
+		}
+		pair := metric.Label[i]
+		metric.Label = slices.DeleteFunc(metric.Label, func(l *dto.LabelPair) bool { return l.GetName() == r.TargetLabel })
+		pair.Name = &r.TargetLabel
+		sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+	}
+	return true
+}
+
+// relabelingGatherer applies relabel rules to every series of the wrapped Gatherer
+type relabelingGatherer struct {
+	gatherer prometheus.Gatherer
+	rules    []relabelRule
+}
+
+// withRelabeling wraps a Gatherer so that the rules are applied to every gathered series, leaving out the series
+// dropped by a rule and the families left without series.
+// Rules removing or renaming labels may make series of a family collide, which Prometheus rejects.
+func withRelabeling(gatherer prometheus.Gatherer, rules []relabelRule) prometheus.Gatherer {
+	if len(rules) == 0 {
+		return gatherer
+	}
+	return &relabelingGatherer{gatherer: gatherer, rules: rules}
+}
+
+func (g *relabelingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	kept := families[:0]
+	for _, family := range families {
+		family.Metric = slices.DeleteFunc(family.Metric, func(metric *dto.Metric) bool {
+			for i := range g.rules {
+				if !g.rules[i].apply(family.GetName(), metric) {
+					return true
+				}
+			}
+			return false
+		})
+		if len(family.Metric) > 0 {
+			kept = append(kept, family)
+		}
+	}
+	return kept, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func writeRelabelConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "relabel.yml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRelabelingGatherer(t *testing.T) {
+	rules, err := loadRelabelConfig(writeRelabelConfig(t, `
+metric_relabel_configs:
+  - action: drop
+    source_labels: [__name__]
+    regex: impala_total_.*
+  - action: drop
+    source_labels: [__name__, impala_client]
+    regex: impala_inflight_queries;spark-.*
+  - action: labeldrop
+    regex: pool
+  - action: rename
+    source_labels: [impala_client]
+    target_label: client
+`))
+	if err != nil {
+		t.Fatalf("loadRelabelConfig() error = %v", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	total := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_total_sessions"}, []string{"impala_client"})
+	total.WithLabelValues("ws1").Set(1)
+	inflight := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_inflight_queries"}, []string{"impala_client", "pool"})
+	inflight.WithLabelValues("ws1", "default").Set(2)
+	inflight.WithLabelValues("spark-exec-1", "default").Set(3)
+	reg.MustRegister(total, inflight)
+
+	families, err := withRelabeling(reg, rules).Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	var got []string
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var labels []string
+			for _, label := range m.Label {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			got = append(got, family.GetName()+"{"+strings.Join(labels, ",")+"}")
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"impala_inflight_queries{client=ws1}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got series %v, want %v", got, want)
+	}
+}
+
+func TestLoadRelabelConfigErrors(t *testing.T) {
+	for _, config := range []string{
+		"metric_relabel_configs: [{action: replace}]",
+		"metric_relabel_configs: [{action: keep}]",
+		"metric_relabel_configs: [{action: labeldrop}]",
+		"metric_relabel_configs: [{action: drop, source_labels: [a], regex: '('}]",
+		"metric_relabel_configs: [{action: rename, source_labels: [__name__], target_label: b}]",
+		"metric_relabel_configs: [{action: rename, source_labels: [a], target_label: __b}]",
+		"metric_relabel_configs: [{action: drop, source_labels: [a], unknown: 1}]",
+	} {
+		if _, err := loadRelabelConfig(writeRelabelConfig(t, config)); err == nil {
+			t.Errorf("loadRelabelConfig(%q) succeeded, want an error", config)
+		}
+	}
+}
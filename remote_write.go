@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const remoteWriteTokenEnv = "IMPALA_EXPORTER_REMOTE_WRITE_TOKEN"
+
+var (
+	remoteWriteURLFlag       = flag.String("remote-write.url", "", "URL of a Prometheus remote write receiver, such as Prometheus, Mimir or Thanos, the metrics are sent to every -sink.interval, to run without a local Prometheus; enables the remote-write sink")
+	remoteWriteTokenFileFlag = flag.String("remote-write.token-file", "", "Path of a file holding the bearer token sent to -remote-write.url; when unset the token is read from $"+remoteWriteTokenEnv+", and none is sent without either")
+	remoteWriteTimeoutFlag   = flag.Duration("remote-write.timeout", 30*time.Second, "Timeout of a request to -remote-write.url")
+)
+
+func init() {
+	RegisterSink("remote-write", func() (Sink, error) {
+		if *remoteWriteURLFlag == "" {
+			return nil, nil
+		}
+		if u, err := url.Parse(*remoteWriteURLFlag); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid URL %q", *remoteWriteURLFlag)
+		}
+		token, _, err := readSecret(*remoteWriteTokenFileFlag, remoteWriteTokenEnv)
+		if err != nil {
+			return nil, fmt.Errorf("reading token: %w", err)
+		}
+		return &remoteWriteSink{
+			url:    *remoteWriteURLFlag,
+			token:  token,
+			client: &http.Client{Timeout: *remoteWriteTimeoutFlag},
+		}, nil
+	})
+}
+
+// remoteWriteSink sends every metrics snapshot to a receiver of the Prometheus remote write protocol 1.0
+type remoteWriteSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *remoteWriteSink) Name() string {
+	return "remote-write"
+}
+
+func (s *remoteWriteSink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit sends the metrics of a snapshot event as a snappy-compressed WriteRequest, skipping the other events. A
+// snapshot the receiver rejects is dropped rather than retried, the next one supersedes it.
+func (s *remoteWriteSink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 {
+		return nil
+	}
+	body := s2.EncodeSnappy(nil, encodeWriteRequest(familySamples(event.Metrics, event.Time)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write receiver responded with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (s *remoteWriteSink) Close() error {
+	return nil
+}
+
+// encodeWriteRequest encodes samples as a prometheus.WriteRequest protobuf message, one time series per sample:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(samples []sinkSample) []byte {
+	var request []byte
+	for _, sample := range samples {
+		// The receivers require the labels sorted by name, __name__ included
+		labels := append([]*dto.LabelPair{labelPair("__name__", sample.Name)}, sample.Labels...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+		var series []byte
+		for _, l := range labels {
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, encodeLabel(l.GetName(), l.GetValue()))
+		}
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+		s = protowire.AppendTag(s, 2, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(sample.Time.UnixMilli()))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, s)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return request
+}
+
+// encodeLabel encodes a prometheus.Label protobuf message
+func encodeLabel(name, value string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, value)
+	return b
+}
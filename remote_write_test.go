@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes a WriteRequest into name{label="value",...} value@timestamp lines
+func decodeWriteRequest(t *testing.T, b []byte) []string {
+	t.Helper()
+	fields := func(b []byte, visit func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			n = visit(num, typ, b)
+			if n < 0 {
+				t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+	var series []string
+	fields(b, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		ts, n := protowire.ConsumeBytes(b)
+		var labels []string
+		var sample string
+		fields(ts, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			m, n := protowire.ConsumeBytes(b)
+			if num == 1 {
+				var name, value string
+				fields(m, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					if num == 1 {
+						name = s
+					} else {
+						value = s
+					}
+					return n
+				})
+				labels = append(labels, name+`="`+value+`"`)
+				return n
+			}
+			var value float64
+			var timestamp uint64
+			fields(m, func(num protowire.Number, typ protowire.Type, b []byte) int {
+				if num == 1 {
+					v, n := protowire.ConsumeFixed64(b)
+					value = math.Float64frombits(v)
+					return n
+				}
+				v, n := protowire.ConsumeVarint(b)
+				timestamp = v
+				return n
+			})
+			sample = formatBound(value) + "@" + strconv.FormatUint(timestamp, 10)
+			return n
+		})
+		series = append(series, "{"+strings.Join(labels, ",")+"} "+sample)
+		return n
+	})
+	return series
+}
+
+func TestRemoteWriteSink(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		compressed, _ := io.ReadAll(r.Body)
+		var err error
+		if body, err = s2.Decode(nil, compressed); err != nil {
+			t.Errorf("decoding snappy body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up"}, []string{"impala_server", "Zone"})
+	up.WithLabelValues("coord", "a").Set(1)
+	registry.MustRegister(up)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &remoteWriteSink{url: receiver.URL, token: "secret", client: receiver.Client()}
+	if err := s.Emit(context.Background(), Event{Time: time.UnixMilli(1700000000000), Metrics: families}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if headers.Get("Content-Encoding") != "snappy" || headers.Get("Authorization") != "Bearer secret" || headers.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("headers = %v", headers)
+	}
+	// The labels are sorted by name, __name__ after the uppercase Zone
+	got := decodeWriteRequest(t, body)
+	want := `{Zone="a",__name__="impala_up",impala_server="coord"} 1@1700000000000`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("series = %q, want [%s]", got, want)
+	}
+}
+
+func TestRemoteWriteSinkError(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "impala_up"}))
+	families, _ := registry.Gather()
+	s := &remoteWriteSink{url: receiver.URL, client: receiver.Client()}
+	err := s.Emit(context.Background(), Event{Time: time.Now(), Metrics: families})
+	if err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("Emit() error = %v, want the receiver's message", err)
+	}
+}
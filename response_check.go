@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var unexpectedResponses = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "impala_exporter_unexpected_responses_total",
+		Help: "Number of responses of an Impala web UI endpoint that were not the JSON document asked for, by reason: status for a non-200 status, html for an HTML page such as a login form",
+	},
+	[]string{"endpoint", "reason"},
+)
+
+// unexpectedResponseError is an answer of the Impala web UI that is not the JSON document asked for
+type unexpectedResponseError struct {
+	// reason is status or html, as counted in impala_exporter_unexpected_responses_total
+	reason string
+	status int
+	url    string
+}
+
+func (e *unexpectedResponseError) Error() string {
+	if e.reason == "html" {
+		return fmt.Sprintf("got an HTML page instead of JSON from %s, the web UI may require authentication", e.url)
+	}
+	return fmt.Sprintf("unexpected HTTP status %d %s from %s", e.status, http.StatusText(e.status), e.url)
+}
+
+// checkResponse returns the body of resp for decoding, or an unexpectedResponseError when the web UI answered with
+// a non-200 status or an HTML page, e.g. an error page or the login form a redirect led to
+func checkResponse(resp *http.Response, path string) (io.Reader, error) {
+	endpoint, _, _ := strings.Cut(path, "?")
+	// The final URL, after any redirect
+	url := resp.Request.URL.Redacted()
+	if resp.StatusCode != http.StatusOK {
+		unexpectedResponses.WithLabelValues(endpoint, "status").Inc()
+		return nil, &unexpectedResponseError{reason: "status", status: resp.StatusCode, url: url}
+	}
+
+	// The body is sniffed rather than the content type checked: a JSON document never starts with <
+	body := bufio.NewReader(resp.Body)
+	for {
+		b, err := body.Peek(1)
+		if err != nil || !bytes.ContainsAny(b, " \t\r\n") {
+			if err == nil && b[0] == '<' {
+				unexpectedResponses.WithLabelValues(endpoint, "html").Inc()
+				return nil, &unexpectedResponseError{reason: "html", status: resp.StatusCode, url: url}
+			}
+			return body, nil
+		}
+		body.Discard(1)
+	}
+}
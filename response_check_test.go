@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestFetchJSONDetectsUnexpectedResponses(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	mux.HandleFunc("/error-page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("\n  <!DOCTYPE html><html><body>Error</body></html>"))
+	})
+	mux.HandleFunc("/protected", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><form></form></html>"))
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("  \n{\"version\": \"impalad version 4.1.0\"}"))
+	})
+	impala := httptest.NewServer(mux)
+	defer impala.Close()
+	address := strings.TrimPrefix(impala.URL, "http://")
+
+	tests := []struct {
+		path       string
+		wantReason string
+		wantInErr  string
+	}{
+		{"/forbidden?json", "status", "403 Forbidden"},
+		{"/error-page?json", "html", "/error-page"},
+		{"/protected?json", "html", "/login"},
+		{"/json?json", "", ""},
+	}
+	for _, tt := range tests {
+		endpoint, _, _ := strings.Cut(tt.path, "?")
+		before := counterValue(unexpectedResponses.WithLabelValues(endpoint, tt.wantReason))
+
+		var root RootResponse
+		err := fetchJSON(context.Background(), address, tt.path, &root)
+		if tt.wantReason == "" {
+			if err != nil || root.Version == "" {
+				t.Errorf("fetchJSON(%s) = %v, %+v, want the decoded document", tt.path, err, root)
+			}
+			continue
+		}
+
+		var respErr *unexpectedResponseError
+		if !errors.As(err, &respErr) || respErr.reason != tt.wantReason || !strings.Contains(err.Error(), tt.wantInErr) {
+			t.Errorf("fetchJSON(%s) error = %v, want a %s error mentioning %q", tt.path, err, tt.wantReason, tt.wantInErr)
+		}
+		if got := counterValue(unexpectedResponses.WithLabelValues(endpoint, tt.wantReason)) - before; got != 1 {
+			t.Errorf("fetchJSON(%s) counted %v unexpected responses, want 1", tt.path, got)
+		}
+	}
+}
+
+// counterValue returns the current value of c
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
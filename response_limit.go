@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxResponseBytes bounds the size of a response of the Impala web UI read by the exporter; 0 means no bound
+var maxResponseBytes int64 = 64 << 20
+
+// errResponseTooLarge is returned when reading an Impala response beyond maxResponseBytes
+var errResponseTooLarge = errors.New("response too large")
+
+// limitedBody reads at most limit bytes of a response body, failing with errResponseTooLarge past them.
+// Unlike an io.LimitReader, a cut response is told apart from a complete one instead of surfacing as truncated JSON.
+type limitedBody struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+// limitBody wraps r so that reading more than limit bytes from it fails; a limit of 0 returns r as is
+func limitBody(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitedBody{r: r, limit: limit, remaining: limit}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// The body may end right at the limit
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w, more than %d bytes", errResponseTooLarge, l.limit)
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
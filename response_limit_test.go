@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		body    string
+		limit   int64
+		wantErr bool
+	}{
+		{"0123456789", 0, false},
+		{"0123456789", 20, false},
+		{"0123456789", 10, false},
+		{"0123456789", 9, true},
+		{"", 1, false},
+	}
+	for _, tt := range tests {
+		got, err := io.ReadAll(limitBody(strings.NewReader(tt.body), tt.limit))
+		if tt.wantErr {
+			if !errors.Is(err, errResponseTooLarge) {
+				t.Errorf("reading %q with limit %d: error = %v, want errResponseTooLarge", tt.body, tt.limit, err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.body {
+			t.Errorf("reading %q with limit %d = %q, %v", tt.body, tt.limit, got, err)
+		}
+	}
+}
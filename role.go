@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// VarzFlag represents a single command line flag as rendered by Impala's /varz?json page
+type VarzFlag struct {
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Default string `json:"default"`
+}
+
+// VarzResponse represents the structure of the JSON response from Impala for /varz
+type VarzResponse struct {
+	Flags []VarzFlag `json:"flags"`
+}
+
+// flag returns the current value of the named flag, reporting false when the daemon does not have it
+func (v VarzResponse) flag(name string) (string, bool) {
+	i := slices.IndexFunc(v.Flags, func(f VarzFlag) bool { return f.Name == name })
+	if i < 0 {
+		return "", false
+	}
+	return v.Flags[i].Current, true
+}
+
+// roleRefresh is how long a detected role is reused; it only changes when the daemon restarts
+const roleRefresh = 10 * time.Minute
+
+// cachedRole is the last role detected for a server
+type cachedRole struct {
+	role    string
+	fetched time.Time
+}
+
+// impaladRole returns the role of an impalad from its -is_coordinator and -is_executor flags, both true by default
+func impaladRole(varz VarzResponse) string {
+	isCoordinator, isExecutor := true, true
+	if v, ok := varz.flag("is_coordinator"); ok {
+		isCoordinator, _ = strconv.ParseBool(v)
+	}
+	if v, ok := varz.flag("is_executor"); ok {
+		isExecutor, _ = strconv.ParseBool(v)
+	}
+	switch {
+	case isCoordinator && isExecutor:
+		return "coordinator_executor"
+	case isCoordinator:
+		return "coordinator"
+	case isExecutor:
+		return "executor"
+	}
+	return "none"
+}
+
+// roleCollector detects from /varz whether an impalad is a coordinator, an executor or both. Besides exporting
+// impala_daemon_role_info, the detected roles label the metrics of every server, see withRoleLabels.
+type roleCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_daemon_role_info over to the provided channel
+func (c roleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.daemonRoleInfo
+}
+
+// Collect sends the role of a server over to the provided channel, fetching /varz only when the cached role is
+// older than roleRefresh. A role cached before a failed refresh is still sent.
+func (c roleCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	e.rolesMu.Lock()
+	cached, ok := e.roles[target.Name]
+	e.rolesMu.Unlock()
+
+	var err error
+	if !ok || time.Since(cached.fetched) > roleRefresh {
+		var varz VarzResponse
+		if err = fetchJSON(ctx, target.Address, "/varz?json", &varz); err == nil {
+			cached = cachedRole{role: impaladRole(varz), fetched: time.Now()}
+			ok = true
+			e.rolesMu.Lock()
+			e.roles[target.Name] = cached
+			e.rolesMu.Unlock()
+		}
+	}
+	if ok {
+		ch <- prometheus.MustNewConstMetric(e.daemonRoleInfo, prometheus.GaugeValue, 1, target.Name, cached.role)
+	}
+	return err
+}
+
+// serverRoles returns the role of the servers by name: the one detected for an impalad, the daemon role for the
+// statestore and catalog
+func (e *Exporter) serverRoles() map[string]string {
+	roles := make(map[string]string)
+	for _, target := range e.Targets() {
+		if target.Role == "statestored" || target.Role == "catalogd" {
+			roles[target.Name] = target.Role
+		}
+	}
+	e.rolesMu.Lock()
+	defer e.rolesMu.Unlock()
+	for name, cached := range e.roles {
+		roles[name] = cached.role
+	}
+	return roles
+}
+
+// roleLabelingGatherer adds the role of their server to the series of the wrapped Gatherer
+type roleLabelingGatherer struct {
+	gatherer prometheus.Gatherer
+	exporter *Exporter
+}
+
+// withRoleLabels wraps a Gatherer of exporter so that every series of a server whose role is known carries it as
+// role label, unless it already has one, like impala_target_info. It returns gatherer as is when the role
+// collector is disabled.
+func withRoleLabels(gatherer prometheus.Gatherer, exporter *Exporter) prometheus.Gatherer {
+	if !exporter.collectorEnabled("role") {
+		return gatherer
+	}
+	return &roleLabelingGatherer{gatherer: gatherer, exporter: exporter}
+}
+
+func (g *roleLabelingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	roles := g.exporter.serverRoles()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			var server string
+			hasRole := false
+			for _, label := range metric.Label {
+				switch label.GetName() {
+				case "impala_server":
+					server = label.GetValue()
+				case "role":
+					hasRole = true
+				}
+			}
+			role, ok := roles[server]
+			if !ok || hasRole {
+				continue
+			}
+			name := "role"
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &role})
+			sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+		}
+	}
+	return families, err
+}
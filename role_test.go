@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestImpaladRole(t *testing.T) {
+	tests := []struct {
+		flags []VarzFlag
+		want  string
+	}{
+		{nil, "coordinator_executor"},
+		{[]VarzFlag{{Name: "is_coordinator", Current: "true"}, {Name: "is_executor", Current: "false"}}, "coordinator"},
+		{[]VarzFlag{{Name: "is_coordinator", Current: "false"}}, "executor"},
+		{[]VarzFlag{{Name: "is_coordinator", Current: "false"}, {Name: "is_executor", Current: "false"}}, "none"},
+	}
+	for _, tt := range tests {
+		if got := impaladRole(VarzResponse{Flags: tt.flags}); got != tt.want {
+			t.Errorf("impaladRole(%v) = %s, want %s", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestRoleLabels(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/varz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"flags": [
+			{"name": "is_coordinator", "type": "bool", "default": "true", "current": "true"},
+			{"name": "is_executor", "type": "bool", "default": "true", "current": "false"}
+		]}`))
+	}))
+	defer impala.Close()
+	e := NewExporter([]string{"coord=" + strings.TrimPrefix(impala.URL, "http://"), "ss=127.0.0.1:25010"}, ExporterOptions{
+		Collectors: map[string]bool{"role": true},
+	})
+	families, err := exporterGatherer(prometheus.NewRegistry(), e, context.Background()).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			var labels []string
+			for _, label := range metric.Label {
+				if label.GetName() == "impala_server" || label.GetName() == "role" {
+					labels = append(labels, label.GetName()+"="+label.GetValue())
+				}
+			}
+			got[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = true
+		}
+	}
+	for _, want := range []string{
+		"impala_daemon_role_info{impala_server=coord,role=coordinator}",
+		"impala_up{impala_server=coord,role=coordinator}",
+		"impala_up{impala_server=ss,role=statestored}",
+		// A series with a role label of its own keeps it
+		"impala_target_info{impala_server=coord,role=unknown}",
+	} {
+		if !got[want] {
+			t.Errorf("missing %s in %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RPCHistogram represents a histogram as rendered by Impala's /rpcz?json page
+type RPCHistogram struct {
+	Units         string  `json:"units"`
+	Count         float64 `json:"count"`
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max"`
+	Percentile25  float64 `json:"25th %-ile"`
+	Percentile50  float64 `json:"50th %-ile"`
+	Percentile75  float64 `json:"75th %-ile"`
+	Percentile90  float64 `json:"90th %-ile"`
+	Percentile95  float64 `json:"95th %-ile"`
+	Percentile999 float64 `json:"99.9th %-ile"`
+}
+
+// RPCMethodMetrics represents the metrics of a single KRPC method
+type RPCMethodMetrics struct {
+	MethodName     string       `json:"method_name"`
+	HandlerLatency RPCHistogram `json:"handler_latency"`
+}
+
+// RPCService represents a single KRPC service (e.g. DataStreamService, ControlService)
+type RPCService struct {
+	ServiceName       string             `json:"service_name"`
+	QueueSize         float64            `json:"queue_size"`
+	IdleThreads       float64            `json:"idle_threads"`
+	RPCsQueueOverflow float64            `json:"rpcs_queue_overflow"`
+	RPCMethodMetrics  []RPCMethodMetrics `json:"rpc_method_metrics"`
+}
+
+// RPCZResponse represents the structure of the JSON response from Impala for /rpcz
+type RPCZResponse struct {
+	Services []RPCService `json:"services"`
+}
+
+// rpcLatencyPercentiles maps the percentile label value to the matching histogram field
+var rpcLatencyPercentiles = []struct {
+	percentile string
+	value      func(h RPCHistogram) float64
+}{
+	{"25", func(h RPCHistogram) float64 { return h.Percentile25 }},
+	{"50", func(h RPCHistogram) float64 { return h.Percentile50 }},
+	{"75", func(h RPCHistogram) float64 { return h.Percentile75 }},
+	{"90", func(h RPCHistogram) float64 { return h.Percentile90 }},
+	{"95", func(h RPCHistogram) float64 { return h.Percentile95 }},
+	{"99.9", func(h RPCHistogram) float64 { return h.Percentile999 }},
+}
+
+// rpcUnitSeconds returns the factor converting a histogram unit into seconds.
+// Impala records KRPC handler latencies in microseconds unless stated otherwise.
+func rpcUnitSeconds(units string) float64 {
+	switch units {
+	case "TIME_NS":
+		return 1e-9
+	case "TIME_MS":
+		return 1e-3
+	case "TIME_S":
+		return 1
+	default:
+		return 1e-6
+	}
+}
+
+// rpczCollector exports the KRPC service metrics of /rpcz
+type rpczCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the KRPC metrics over to the provided channel
+func (c rpczCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.rpcCalls
+	ch <- c.e.rpcHandlerLatency
+	ch <- c.e.rpcHandlerLatencyMax
+	ch <- c.e.rpcQueueOverflows
+	ch <- c.e.rpcQueueSize
+	ch <- c.e.rpcIdleThreads
+}
+
+// Collect fetches the KRPC service metrics of a server and sends them over to the provided channel
+func (c rpczCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	server := target.Name
+	var rpcz RPCZResponse
+	if err := fetchJSON(ctx, target.Address, "/rpcz?json", &rpcz); err != nil {
+		return err
+	}
+
+	for _, service := range rpcz.Services {
+		ch <- prometheus.MustNewConstMetric(e.rpcQueueOverflows, prometheus.CounterValue, service.RPCsQueueOverflow, server, service.ServiceName)
+		ch <- prometheus.MustNewConstMetric(e.rpcQueueSize, prometheus.GaugeValue, service.QueueSize, server, service.ServiceName)
+		ch <- prometheus.MustNewConstMetric(e.rpcIdleThreads, prometheus.GaugeValue, service.IdleThreads, server, service.ServiceName)
+
+		for _, method := range service.RPCMethodMetrics {
+			latency := method.HandlerLatency
+			ch <- prometheus.MustNewConstMetric(e.rpcCalls, prometheus.CounterValue, latency.Count, server, service.ServiceName, method.MethodName)
+
+			scale := rpcUnitSeconds(latency.Units)
+			for _, p := range rpcLatencyPercentiles {
+				ch <- prometheus.MustNewConstMetric(e.rpcHandlerLatency, prometheus.GaugeValue, p.value(latency)*scale, server, service.ServiceName, method.MethodName, p.percentile)
+			}
+			ch <- prometheus.MustNewConstMetric(e.rpcHandlerLatencyMax, prometheus.GaugeValue, latency.Max*scale, server, service.ServiceName, method.MethodName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const rpczJSON = `{
+  "services": [
+    {
+      "service_name": "impala.DataStreamService",
+      "queue_size": 4,
+      "idle_threads": 12,
+      "rpcs_queue_overflow": 7,
+      "rpc_method_metrics": [
+        {
+          "method_name": "TransmitData",
+          "handler_latency": {
+            "units": "TIME_US", "count": 1500, "min": 10, "max": 250000,
+            "25th %-ile": 100, "50th %-ile": 200, "75th %-ile": 400,
+            "90th %-ile": 1000, "95th %-ile": 2000, "99.9th %-ile": 50000
+          }
+        },
+        {
+          "method_name": "EndDataStream",
+          "handler_latency": {"units": "TIME_MS", "count": 3, "max": 2, "50th %-ile": 1}
+        }
+      ]
+    }
+  ]
+}`
+
+func TestCollectRPCZ(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(rpczJSON))
+	}))
+	defer impala.Close()
+
+	e := NewExporter(nil, ExporterOptions{})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (rpczCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+
+	const service = `service="impala.DataStreamService"`
+	want := map[string]float64{
+		`impala_rpc_queue_overflows_total{` + service + `}`: 7,
+		`impala_rpc_queue_size{` + service + `}`:            4,
+		`impala_rpc_idle_threads{` + service + `}`:          12,
+
+		`impala_rpc_calls_total{method="TransmitData",` + service + `}`:                               1500,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="25",` + service + `}`:   0.0001,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="50",` + service + `}`:   0.0002,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="75",` + service + `}`:   0.0004,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="90",` + service + `}`:   0.001,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="95",` + service + `}`:   0.002,
+		`impala_rpc_handler_latency_seconds{method="TransmitData",percentile="99.9",` + service + `}`: 0.05,
+		`impala_rpc_handler_latency_max_seconds{method="TransmitData",` + service + `}`:               0.25,
+
+		`impala_rpc_calls_total{method="EndDataStream",` + service + `}`:                             3,
+		`impala_rpc_handler_latency_seconds{method="EndDataStream",percentile="50",` + service + `}`: 0.001,
+		`impala_rpc_handler_latency_max_seconds{method="EndDataStream",` + service + `}`:             0.002,
+	}
+	for key, want := range want {
+		if v, ok := got[key]; !ok || math.Abs(v-want) > 1e-12 {
+			t.Errorf("%s = %v (present %v), want %v", key, v, ok, want)
+		}
+	}
+}
+
+func TestRPCUnitSeconds(t *testing.T) {
+	tests := map[string]float64{"TIME_NS": 1e-9, "TIME_US": 1e-6, "TIME_MS": 1e-3, "TIME_S": 1, "": 1e-6}
+	for units, want := range tests {
+		if got := rpcUnitSeconds(units); got != want {
+			t.Errorf("rpcUnitSeconds(%q) = %v, want %v", units, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// parseScanRanges parses a progress string such as "3 / 10 ( 30%)" into its completed and total scan ranges.
+// It reports false when the query has no scan ranges to track.
+func parseScanRanges(progress string) (completed, total int, ok bool) {
+	matches := progressRe.FindStringSubmatch(progress)
+	if matches == nil {
+		return 0, 0, false
+	}
+	completed, _ = strconv.Atoi(matches[1])
+	total, _ = strconv.Atoi(matches[2])
+	return completed, total, total > 0
+}
+
+// scanProgress accumulates the scan progress of the in-flight queries of a server in a scrape
+type scanProgress struct {
+	minRatio, sumRatio float64
+	queries            int
+	// stalled counts the unfinished queries whose completed scan ranges did not change since the previous scrape
+	stalled float64
+	// completed holds the completed scan ranges of the unfinished queries, by query ID
+	completed map[string]int
+}
+
+func newScanProgress() *scanProgress {
+	return &scanProgress{minRatio: 1, completed: make(map[string]int)}
+}
+
+// observe records the progress of a query, comparing it with previous, the completed scan ranges of the queries of
+// the server as of the previous scrape
+func (p *scanProgress) observe(queryID, progress string, previous map[string]int) {
+	completed, total, ok := parseScanRanges(progress)
+	if !ok {
+		return
+	}
+	ratio := float64(completed) / float64(total)
+	p.minRatio = min(p.minRatio, ratio)
+	p.sumRatio += ratio
+	p.queries++
+	if completed >= total || queryID == "" {
+		return
+	}
+	if before, ok := previous[queryID]; ok && before == completed {
+		p.stalled++
+	}
+	p.completed[queryID] = completed
+}
+
+// scanProgressTracker keeps, per server, the completed scan ranges of the unfinished queries as of the previous scrape
+type scanProgressTracker struct {
+	mu       sync.Mutex
+	previous map[string]map[string]int
+}
+
+// get returns the completed scan ranges of the queries of server as of the previous scrape
+func (t *scanProgressTracker) get(server string) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.previous[server]
+}
+
+// set records the completed scan ranges of the queries of server of this scrape, forgetting the finished ones
+func (t *scanProgressTracker) set(server string, completed map[string]int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.previous == nil {
+		t.previous = make(map[string]map[string]int)
+	}
+	t.previous[server] = completed
+}
+
+// collect sends the scan progress metrics of server; the ratios are left out while no query tracks scan ranges
+func (p *scanProgress) collect(ch chan<- prometheus.Metric, e *Exporter, server string) {
+	if p.queries > 0 {
+		ch <- prometheus.MustNewConstMetric(e.scanProgressMin, prometheus.GaugeValue, p.minRatio, server)
+		ch <- prometheus.MustNewConstMetric(e.scanProgressAvg, prometheus.GaugeValue, p.sumRatio/float64(p.queries), server)
+	}
+	ch <- prometheus.MustNewConstMetric(e.scanStalledQueries, prometheus.GaugeValue, p.stalled, server)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectScanProgress(t *testing.T) {
+	var progress []string
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var queries []string
+		for i, p := range progress {
+			queries = append(queries, fmt.Sprintf(`{"query_id": "0:%d", "duration": "1m", "progress": %q}`, i, p))
+		}
+		fmt.Fprintf(w, `{"in_flight_queries": [%s]}`, strings.Join(queries, ","))
+	}))
+	defer impala.Close()
+	target := Target{Name: "impalad", Address: strings.TrimPrefix(impala.URL, "http://")}
+	e := NewExporter(nil, ExporterOptions{})
+	scrape := func() map[string]float64 {
+		got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+			if err := (queriesCollector{e}).Collect(context.Background(), ch, target); err != nil {
+				t.Error(err)
+			}
+		})
+		for name := range got {
+			if !strings.HasPrefix(name, "impala_inflight_scan") {
+				delete(got, name)
+			}
+		}
+		return got
+	}
+
+	progress = []string{"0 / 0 (  0%)"}
+	if got, want := scrape(), map[string]float64{"impala_inflight_scan_stalled_queries": 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without scan ranges got %v, want %v", got, want)
+	}
+
+	// Nothing can have stalled on the first scrape of a query
+	progress = []string{"2 / 10 ( 20%)", "6 / 10 ( 60%)", "10 / 10 (100%)", "N/A"}
+	want := map[string]float64{
+		"impala_inflight_scan_progress_min_ratio": 0.2,
+		"impala_inflight_scan_progress_avg_ratio": 0.6,
+		"impala_inflight_scan_stalled_queries":    0,
+	}
+	if got := scrape(); !reflect.DeepEqual(got, want) {
+		t.Errorf("first scrape got %v, want %v", got, want)
+	}
+
+	// Query 0:0 made no progress, 0:1 did and 0:2 is done scanning
+	progress = []string{"2 / 10 ( 20%)", "8 / 10 ( 80%)", "10 / 10 (100%)"}
+	want = map[string]float64{
+		"impala_inflight_scan_progress_min_ratio": 0.2,
+		"impala_inflight_scan_progress_avg_ratio": 2.0 / 3,
+		"impala_inflight_scan_stalled_queries":    1,
+	}
+	if got := scrape(); !reflect.DeepEqual(got, want) {
+		t.Errorf("second scrape got %v, want %v", got, want)
+	}
+}
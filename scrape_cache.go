@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var scrapeCacheAge = prometheus.NewDesc(
+	"impala_exporter_scrape_cache_age_seconds",
+	"Seconds since the cached Impala metrics served with -scrape.interval were collected",
+	nil,
+	nil,
+)
+
+// cachedScrape is the result of a background scrape
+type cachedScrape struct {
+	time    time.Time
+	metrics []prometheus.Metric
+}
+
+// refreshCache scrapes every server within ctx and replaces the cached metrics served by Collect
+func (e *Exporter) refreshCache(ctx context.Context) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.collectLive(ctx, ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+
+	e.cacheMu.Lock()
+	e.cache = &cachedScrape{time: time.Now(), metrics: metrics}
+	e.cacheMu.Unlock()
+}
+
+// collectCached sends the metrics of the last background scrape over to the provided channel, along with their age.
+// Nothing is sent before the first background scrape has completed.
+func (e *Exporter) collectCached(ch chan<- prometheus.Metric) {
+	e.cacheMu.RLock()
+	cache := e.cache
+	e.cacheMu.RUnlock()
+	if cache == nil {
+		return
+	}
+	for _, m := range cache.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeCacheAge, prometheus.GaugeValue, time.Since(cache.time).Seconds())
+}
+
+// startBackgroundScrape scrapes the servers of exporter immediately and then every interval, under supervision,
+// so that Collect serves the cached result instead of scraping the servers itself
+func startBackgroundScrape(sup *supervisor, name string, exporter *Exporter, interval time.Duration) *supervisedTask {
+	return sup.Go(name, staleAfter(interval), func(ctx context.Context, heartbeat func()) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			exporter.refreshCache(ctx)
+			heartbeat()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollectServesCachedScrape(t *testing.T) {
+	var requests atomic.Int32
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/queries":
+			w.Write([]byte(`{"in_flight_queries": [{"duration": "45s"}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer impala.Close()
+
+	e := NewExporter([]string{strings.TrimPrefix(impala.URL, "http://")}, ExporterOptions{ScrapeInterval: time.Minute})
+	if got := collectValues(t, e, e.Collect); len(got) != 0 {
+		t.Errorf("Collect before the first background scrape sent %v, want nothing", got)
+	}
+
+	e.refreshCache(context.Background())
+	fetched := requests.Load()
+	for range 3 {
+		got := collectValues(t, e, e.Collect)
+		if got["impala_inflight_queries_count"] != 1 {
+			t.Errorf("cached impala_inflight_queries_count = %v, want 1", got["impala_inflight_queries_count"])
+		}
+		if _, ok := got["impala_exporter_scrape_cache_age_seconds"]; !ok {
+			t.Errorf("no cache age sent")
+		}
+	}
+	if n := requests.Load(); n != fetched {
+		t.Errorf("Collect made %d requests to Impala, want the cached result served", n-fetched)
+	}
+}
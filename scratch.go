@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// memSpecUnits are the multipliers of the size suffixes Impala accepts in memory specifications such as "100GB"
+var memSpecUnits = map[string]float64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+	"t":  1 << 40,
+	"tb": 1 << 40,
+}
+
+// parseMemSpec parses a size as Impala accepts it in its flags, e.g. "100GB", "512m" or "1073741824"
+func parseMemSpec(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := memSpecUnits[s[i:]]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return value * unit, nil
+}
+
+// scratchLimit returns the total limit of the scratch directories of an impalad from its -scratch_dirs flag, a
+// comma-separated list of path[:limit[:priority]] entries, where the path of a remote directory such as
+// hdfs://namenode:8020/tmp may contain colons too. It reports false when a directory has no limit.
+func scratchLimit(scratchDirs string) (float64, bool) {
+	total := 0.0
+	found := false
+	for _, entry := range strings.Split(scratchDirs, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		found = true
+		// The limit follows the path, whose scheme and authority are skipped
+		rest := entry
+		if _, afterScheme, ok := strings.Cut(entry, "://"); ok {
+			if i := strings.Index(afterScheme, "/"); i >= 0 {
+				rest = afterScheme[i:]
+			}
+		}
+		parts := strings.Split(rest, ":")
+		if len(parts) < 2 || parts[1] == "" || parts[1] == "-1" {
+			return 0, false
+		}
+		limit, err := parseMemSpec(parts[1])
+		if err != nil {
+			return 0, false
+		}
+		total += limit
+	}
+	return total, found
+}
+
+// collectScratchMetrics sends the scratch space used by a daemon across its scratch directories over to the provided
+// channel
+func (e *Exporter) collectScratchMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	if value, ok := values["tmp-file-mgr.scratch-space-bytes-used"]; ok {
+		ch <- prometheus.MustNewConstMetric(e.scratchUsed, prometheus.GaugeValue, value, server)
+	}
+}
+
+// scratchLimitCollector exports the scratch space limit of an impalad, so that alerts can fire before its scratch
+// directories fill up and spilling queries start failing
+type scratchLimitCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptor of impala_scratch_limit_bytes over to the provided channel
+func (c scratchLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.scratchLimit
+}
+
+// Collect fetches the flags of an impalad from /varz and sends the total limit of its scratch directories over to
+// the provided channel, unless one of them is unlimited
+func (c scratchLimitCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var varz VarzResponse
+	if err := fetchJSON(ctx, target.Address, "/varz?json", &varz); err != nil {
+		return err
+	}
+	scratchDirs, _ := varz.flag("scratch_dirs")
+	if limit, ok := scratchLimit(scratchDirs); ok {
+		ch <- prometheus.MustNewConstMetric(e.scratchLimit, prometheus.GaugeValue, limit, target.Name)
+	}
+	return nil
+}
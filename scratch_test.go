@@ -0,0 +1,55 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseMemSpec(t *testing.T) {
+	tests := map[string]float64{"100GB": 100 << 30, "512m": 512 << 20, "1.5t": 1.5 * (1 << 40), "1024": 1024}
+	for s, want := range tests {
+		if got, err := parseMemSpec(s); err != nil || got != want {
+			t.Errorf("parseMemSpec(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "GB", "10 parsecs"} {
+		if _, err := parseMemSpec(s); err == nil {
+			t.Errorf("parseMemSpec(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestScratchLimit(t *testing.T) {
+	tests := []struct {
+		scratchDirs string
+		want        float64
+		wantOK      bool
+	}{
+		{"/data1/impala:100GB,/data2/impala:50GB:1", 150 << 30, true},
+		{"hdfs://namenode:8020/tmp:1TB,/data1/impala:10GB", (1 << 40) + (10 << 30), true},
+		{"/data1/impala:100GB,/data2/impala", 0, false},
+		{"/data1/impala:-1", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := scratchLimit(tt.scratchDirs)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("scratchLimit(%q) = %v, %v, want %v, %v", tt.scratchDirs, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCollectScratchMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectScratchMetrics(ch, "executor", map[string]float64{
+			"tmp-file-mgr.scratch-space-bytes-used":                 3 << 30,
+			"tmp-file-mgr.scratch-space-bytes-used-high-water-mark": 7 << 30,
+		})
+	})
+	if want := map[string]float64{"impala_scratch_used_bytes": 3 << 30}; !maps.Equal(got, want) {
+		t.Errorf("collectScratchMetrics() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import "strings"
+
+// ScrubSQL replaces string and numeric literals in a SQL statement with ?, so statements can be
+// captured without the values they filter on. Identifiers (including quoted `identifiers`) and
+// keywords are left untouched; literals are scrubbed inside comments too, which keeps optimizer hints.
+func ScrubSQL(stmt string) string {
+	var b strings.Builder
+	b.Grow(len(stmt))
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+		switch {
+		case c == '\'' || c == '"':
+			i = skipQuoted(stmt, i)
+			b.WriteByte('?')
+		case c == '`':
+			end := strings.IndexByte(stmt[i+1:], '`')
+			if end < 0 {
+				b.WriteString(stmt[i:])
+				return b.String()
+			}
+			b.WriteString(stmt[i : i+end+2])
+			i += end + 2
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"):
+			end := strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				end = len(stmt) - i
+			}
+			b.WriteString("--")
+			b.WriteString(ScrubSQL(stmt[i+2 : i+end]))
+			i += end
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				b.WriteString("/*")
+				b.WriteString(ScrubSQL(stmt[i+2:]))
+				return b.String()
+			}
+			b.WriteString("/*")
+			b.WriteString(ScrubSQL(stmt[i+2 : i+2+end]))
+			b.WriteString("*/")
+			i += end + 4
+		case isDigit(c) && (i == 0 || !isIdentByte(stmt[i-1])):
+			i = skipNumber(stmt, i)
+			b.WriteByte('?')
+		case isIdentByte(c):
+			start := i
+			for i < len(stmt) && isIdentByte(stmt[i]) {
+				i++
+			}
+			b.WriteString(stmt[start:i])
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted returns the index just past the quoted literal starting at i, honouring
+// backslash escapes and doubled quotes
+func skipQuoted(stmt string, i int) int {
+	quote := stmt[i]
+	for i++; i < len(stmt); i++ {
+		switch stmt[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(stmt) && stmt[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(stmt)
+}
+
+// skipNumber returns the index just past the numeric literal starting at i
+func skipNumber(stmt string, i int) int {
+	for i < len(stmt) && (isDigit(stmt[i]) || stmt[i] == '.') {
+		i++
+	}
+	if i < len(stmt) && (stmt[i] == 'e' || stmt[i] == 'E') {
+		j := i + 1
+		if j < len(stmt) && (stmt[j] == '+' || stmt[j] == '-') {
+			j++
+		}
+		if j < len(stmt) && isDigit(stmt[j]) {
+			i = j
+			for i < len(stmt) && isDigit(stmt[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// captureStatement returns stmt as captured by the exporter: scrubbed unless scrubbing was disabled
+func (e *Exporter) captureStatement(stmt string) string {
+	if !e.options.ScrubLiterals {
+		return statementSnippet(stmt)
+	}
+	return statementSnippet(ScrubSQL(stmt))
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestScrubSQL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"SELECT * FROM t WHERE id = 42 AND name = 'bob'", "SELECT * FROM t WHERE id = ? AND name = ?"},
+		{`select "secret", 'it''s', 'a\'b' from t1`, "select ?, ?, ? from t1"},
+		{"SELECT col1, t2.c3 FROM db1.t2 WHERE x IN (1, 2, 3.5, 1e10)", "SELECT col1, t2.c3 FROM db1.t2 WHERE x IN (?, ?, ?, ?)"},
+		{"SELECT `col 1`, `it's` FROM t", "SELECT `col 1`, `it's` FROM t"},
+		{"SELECT /* +SHUFFLE */ a -- note 5\nFROM t LIMIT 10", "SELECT /* +SHUFFLE */ a -- note ?\nFROM t LIMIT ?"},
+		{"SELECT a FROM t -- customer 'bob'", "SELECT a FROM t -- customer ?"},
+		{"SELECT /* id 42, 'x' */ a FROM t /* open 'y'", "SELECT /* id ?, ? */ a FROM t /* open ?"},
+		{"SELECT a FROM t WHERE d > '2024-01-01' AND v = -1.5", "SELECT a FROM t WHERE d > ? AND v = -?"},
+		{"SELECT 'unterminated", "SELECT ?"},
+		{"INSERT INTO t VALUES ('Müller', 7)", "INSERT INTO t VALUES (?, ?)"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ScrubSQL(tt.input); got != tt.want {
+			t.Errorf("ScrubSQL(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
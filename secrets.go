@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables holding the secrets otherwise read from the file given by their flag, so that they can be
+// injected e.g. from a Kubernetes secret without appearing on the command line
+const (
+	cmPasswordEnv    = "IMPALA_EXPORTER_CM_PASSWORD"
+	apiTokenEnv      = "IMPALA_EXPORTER_API_TOKEN"
+	encryptionKeyEnv = "IMPALA_EXPORTER_ENCRYPTION_KEY"
+)
+
+// readSecret returns the secret held by file, or by the environment variable env when file is empty, with
+// surrounding whitespace removed. It reports false when neither is set.
+func readSecret(file, env string) (string, bool, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	value, ok := os.LookupEnv(env)
+	return strings.TrimSpace(value), ok, nil
+}
+
+// secret is a configuration value given inline, or read from a file or an environment variable:
+//
+//	password: inline
+//	password: {file: /etc/secrets/knox-password}
+//	password: {env: KNOX_PASSWORD}
+type secret string
+
+func (s *secret) UnmarshalYAML(unmarshal func(any) error) error {
+	var inline string
+	if err := unmarshal(&inline); err == nil {
+		*s = secret(inline)
+		return nil
+	}
+	var ref struct {
+		File string `yaml:"file"`
+		Env  string `yaml:"env"`
+	}
+	if err := unmarshal(&ref); err != nil {
+		return err
+	}
+	if (ref.File == "") == (ref.Env == "") {
+		return errors.New("a secret needs either a file or an env key")
+	}
+	value, ok, err := readSecret(ref.File, ref.Env)
+	if err != nil {
+		return fmt.Errorf("reading secret: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("environment variable %s of a secret is not set", ref.Env)
+	}
+	*s = secret(value)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestReadSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(file, []byte("from-file\n"), 0o600)
+	t.Setenv("IMPALA_EXPORTER_TEST_SECRET", " from-env ")
+
+	tests := []struct {
+		file, env string
+		want      string
+		wantOK    bool
+	}{
+		{file, "IMPALA_EXPORTER_TEST_SECRET", "from-file", true},
+		{"", "IMPALA_EXPORTER_TEST_SECRET", "from-env", true},
+		{"", "IMPALA_EXPORTER_TEST_UNSET", "", false},
+	}
+	for _, tt := range tests {
+		got, ok, err := readSecret(tt.file, tt.env)
+		if err != nil || got != tt.want || ok != tt.wantOK {
+			t.Errorf("readSecret(%q, %q) = %q, %v, %v, want %q, %v", tt.file, tt.env, got, ok, err, tt.want, tt.wantOK)
+		}
+	}
+	if _, _, err := readSecret(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("readSecret() of a missing file succeeded")
+	}
+}
+
+func TestSecretUnmarshalYAML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(file, []byte("s3cret\n"), 0o600)
+	t.Setenv("IMPALA_EXPORTER_TEST_TOKEN", "t0ken")
+
+	var config struct {
+		Inline secret `yaml:"inline"`
+		File   secret `yaml:"file"`
+		Env    secret `yaml:"env"`
+	}
+	data := "inline: plain\nfile: {file: " + file + "}\nenv: {env: IMPALA_EXPORTER_TEST_TOKEN}\n"
+	if err := yaml.UnmarshalStrict([]byte(data), &config); err != nil {
+		t.Fatalf("UnmarshalStrict() error = %v", err)
+	}
+	if config.Inline != "plain" || config.File != "s3cret" || config.Env != "t0ken" {
+		t.Errorf("got %+v", config)
+	}
+
+	for _, data := range []string{
+		"s: {}\n",
+		"s: {file: a, env: B}\n",
+		"s: {env: IMPALA_EXPORTER_TEST_UNSET}\n",
+		"s: {file: " + filepath.Join(t.TempDir(), "missing") + "}\n",
+	} {
+		var v struct {
+			S secret `yaml:"s"`
+		}
+		if err := yaml.UnmarshalStrict([]byte(data), &v); err == nil {
+			t.Errorf("UnmarshalStrict(%q) error = nil, want an error", data)
+		}
+	}
+}
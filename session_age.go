@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// impalaTimeLayout is how the web UI renders timestamps, in the time zone of the daemon
+const impalaTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// parseImpalaTime parses a timestamp of the web UI rendered in loc
+func parseImpalaTime(s string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(impalaTimeLayout, s, loc)
+}
+
+// sessionHost returns the client host of a session, its network address without port
+func sessionHost(session ImpalaSession) string {
+	if host, _, err := net.SplitHostPort(session.NetworkAddress); err == nil {
+		return host
+	}
+	return session.NetworkAddress
+}
+
+// clientSessionAge is the age of the oldest open session of a client and the idle time of its longest idle one
+type clientSessionAge struct {
+	age, idle float64
+}
+
+// clientSessionAges returns, per client label rendered according to mode, the age and idle time of the open
+// sessions of the clients matching include and exclude, as of now. Sessions whose timestamps cannot be parsed
+// are left out.
+func clientSessionAges(sessions []ImpalaSession, mode string, include, exclude *regexp.Regexp, loc *time.Location, now time.Time) map[string]clientSessionAge {
+	ages := make(map[string]clientSessionAge)
+	for _, session := range sessions {
+		if session.Closed {
+			continue
+		}
+		host := sessionHost(session)
+		if include != nil && !include.MatchString(host) || exclude != nil && exclude.MatchString(host) {
+			continue
+		}
+		start, err := parseImpalaTime(session.StartTime, loc)
+		if err != nil {
+			continue
+		}
+		lastAccessed, err := parseImpalaTime(session.LastAccessed, loc)
+		if err != nil {
+			continue
+		}
+		label := clientLabel(mode, host)
+		a := ages[label]
+		a.age = max(a.age, now.Sub(start).Seconds())
+		a.idle = max(a.idle, now.Sub(lastAccessed).Seconds())
+		ages[label] = a
+	}
+	return ages
+}
+
+// collectClientSessionAges sends the age of the oldest open session and the idle time of the longest idle one of
+// each client of a server over to the provided channel, labeled by labelValues like the other per-client metrics
+func (e *Exporter) collectClientSessionAges(ch chan<- prometheus.Metric, sessions []ImpalaSession, mode string, labelValues func(string) []string) {
+	ages := clientSessionAges(sessions, mode, e.options.ClientInclude, e.options.ClientExclude, e.timezone(), time.Now())
+	for client, a := range ages {
+		ch <- prometheus.MustNewConstMetric(e.clientMaxSessionAge, prometheus.GaugeValue, max(a.age, 0), labelValues(client)...)
+		ch <- prometheus.MustNewConstMetric(e.clientMaxSessionIdle, prometheus.GaugeValue, max(a.idle, 0), labelValues(client)...)
+	}
+}
+
+// oldestSessionAge returns the age of the oldest open session as of now, 0 without any
+func oldestSessionAge(sessions []ImpalaSession, loc *time.Location, now time.Time) float64 {
+	var oldest float64
+	for _, session := range sessions {
+		if session.Closed {
+			continue
+		}
+		if start, err := parseImpalaTime(session.StartTime, loc); err == nil {
+			oldest = max(oldest, now.Sub(start).Seconds())
+		}
+	}
+	return oldest
+}
+
+// collectOldestSessionAge sends the age of the oldest open session of a server, whichever its client, over to the
+// provided channel
+func (e *Exporter) collectOldestSessionAge(ch chan<- prometheus.Metric, server string, sessions []ImpalaSession) {
+	ch <- prometheus.MustNewConstMetric(e.oldestSessionAge, prometheus.GaugeValue, oldestSessionAge(sessions, e.timezone(), time.Now()), server)
+}
+
+// timezone returns the time zone the timestamps of the web UI are rendered in
+func (e *Exporter) timezone() *time.Location {
+	if e.options.Timezone != nil {
+		return e.options.Timezone
+	}
+	return time.Local
+}
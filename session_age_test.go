@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestClientSessionAges(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, loc)
+	sessions := []ImpalaSession{
+		{NetworkAddress: "10.0.0.1:50000", StartTime: "2024-05-01 12:00:00.000000000", LastAccessed: "2024-05-02 11:59:00.000000000"},
+		{NetworkAddress: "10.0.0.1:50001", StartTime: "2024-05-02 11:00:00.000000000", LastAccessed: "2024-05-02 11:30:00.000000000"},
+		{NetworkAddress: "10.0.0.2:50000", StartTime: "2024-05-02 11:58:00.5", LastAccessed: "2024-05-02 11:59:30", Expired: true},
+		// Closed sessions and sessions whose timestamps cannot be read are left out
+		{NetworkAddress: "10.0.0.2:50001", StartTime: "2024-04-01 00:00:00", LastAccessed: "2024-04-01 00:00:00", Closed: true},
+		{NetworkAddress: "10.0.0.3:50000", StartTime: "N/A", LastAccessed: "2024-05-02 11:59:30"},
+		{NetworkAddress: "batch.example.com:50000", StartTime: "2024-05-02 10:00:00", LastAccessed: "2024-05-02 10:00:00"},
+	}
+	got := clientSessionAges(sessions, "keep", nil, regexp.MustCompile(`^batch\..*$`), loc, now)
+	want := map[string]clientSessionAge{
+		"10.0.0.1": {age: 86400, idle: 1800},
+		"10.0.0.2": {age: 119.5, idle: 30},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clientSessionAges() = %v, want %v", got, want)
+	}
+
+	// The oldest session is looked for among all clients
+	if got := oldestSessionAge(sessions, loc, now); got != 86400 {
+		t.Errorf("oldestSessionAge() = %v, want 86400", got)
+	}
+	if got := oldestSessionAge(nil, loc, now); got != 0 {
+		t.Errorf("oldestSessionAge() without sessions = %v, want 0", got)
+	}
+
+	got = clientSessionAges(sessions, "domain", nil, nil, loc, now)
+	want = map[string]clientSessionAge{
+		"10.0.0.0/24": {age: 86400, idle: 1800},
+		"example.com": {age: 7200, idle: 7200},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clientSessionAges() by domain = %v, want %v", got, want)
+	}
+}
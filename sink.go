@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Event is a unit of data forwarded to sinks
+type Event struct {
+	// Time is when the event was produced
+	Time time.Time
+	// Metrics is a snapshot of every gathered metric family, for metric outputs
+	Metrics []*dto.MetricFamily
+	// LongQuery is an in-flight query that has just crossed -sink.query-threshold, for notification outputs.
+	// Sinks skip the events they have no use for.
+	LongQuery *slowQueryEntry
+}
+
+// Sink is an output that collected data is forwarded to, next to the Prometheus endpoint.
+// Emit is only ever called from a single goroutine per sink.
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Start prepares the sink, e.g. by connecting to its backend
+	Start(ctx context.Context) error
+	// Emit delivers a single event
+	Emit(ctx context.Context, event Event) error
+	// Close flushes and releases the sink's resources
+	Close() error
+}
+
+// SinkFactory creates a sink from its command line flags, returning a nil Sink when the sink is not enabled
+type SinkFactory func() (Sink, error)
+
+var (
+	sinkFactoriesMu sync.Mutex
+	sinkFactories   = make(map[string]SinkFactory)
+)
+
+// RegisterSink makes a sink available under the given name. It is meant to be called from
+// an init function in the sink's own file, which also defines the sink's flags, so new outputs
+// can be added without touching the collection code.
+func RegisterSink(name string, factory SinkFactory) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+	if _, ok := sinkFactories[name]; ok {
+		panic(fmt.Sprintf("sink %q registered twice", name))
+	}
+	sinkFactories[name] = factory
+}
+
+// buildSinks creates every registered sink that is enabled by its flags
+func buildSinks() ([]Sink, error) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+
+	names := make([]string, 0, len(sinkFactories))
+	for name := range sinkFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sinks []Sink
+	for _, name := range names {
+		sink, err := sinkFactories[name]()
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		if sink != nil {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks, nil
+}
+
+// sinkIdleHeartbeat is how often a sink worker waiting for events reports that it is alive
+const sinkIdleHeartbeat = time.Minute
+
+// sinkStaleAfter is how long a sink worker may go without a heartbeat, i.e. be stuck emitting an event
+const sinkStaleAfter = 5 * time.Minute
+
+// sinkManager fans events out to the enabled sinks, each behind its own bounded queue and worker
+type sinkManager struct {
+	sinks  []Sink
+	queues []*eventQueue[Event]
+	tasks  []*supervisedTask
+	cancel context.CancelFunc
+}
+
+// startSinks starts the given sinks and, if interval is positive, publishes a metrics snapshot
+// gathered from gatherer every interval. The workers run under the given supervisor.
+func startSinks(sup *supervisor, sinks []Sink, gatherer prometheus.Gatherer, interval time.Duration, bufferSize int) (*sinkManager, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &sinkManager{cancel: cancel}
+	for _, sink := range sinks {
+		if err := sink.Start(ctx); err != nil {
+			cancel()
+			m.Close()
+			return nil, fmt.Errorf("starting sink %s: %w", sink.Name(), err)
+		}
+		m.sinks = append(m.sinks, sink)
+		m.queues = append(m.queues, newEventQueue[Event](sink.Name(), bufferSize))
+	}
+
+	for i, sink := range m.sinks {
+		m.tasks = append(m.tasks, sup.Go("sink/"+sink.Name(), sinkStaleAfter, func(ctx context.Context, heartbeat func()) {
+			m.run(ctx, sink, m.queues[i], heartbeat)
+		}))
+	}
+	if interval > 0 && len(m.sinks) > 0 {
+		m.tasks = append(m.tasks, sup.Go("sink/publisher", staleAfter(interval), func(ctx context.Context, heartbeat func()) {
+			m.publishMetrics(ctx, gatherer, interval, heartbeat)
+		}))
+	}
+	return m, nil
+}
+
+// Publish queues an event for every sink without blocking
+func (m *sinkManager) Publish(event Event) {
+	for _, q := range m.queues {
+		q.Push(event)
+	}
+}
+
+// Close stops the workers and closes every sink
+func (m *sinkManager) Close() {
+	m.cancel()
+	for _, t := range m.tasks {
+		t.Stop()
+	}
+	for _, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			slog.Error("Error closing sink", "sink", sink.Name(), "err", err)
+		}
+	}
+}
+
+// run delivers queued events to a sink until ctx is done
+func (m *sinkManager) run(ctx context.Context, sink Sink, q *eventQueue[Event], heartbeat func()) {
+	for {
+		heartbeat()
+		popCtx, cancel := context.WithTimeout(ctx, sinkIdleHeartbeat)
+		event, ok := q.Pop(popCtx)
+		cancel()
+		if !ok {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if err := sink.Emit(ctx, event); err != nil {
+			slog.Warn("Error emitting to sink", "sink", sink.Name(), "err", err)
+		}
+	}
+}
+
+// publishMetrics gathers and publishes a metrics snapshot every interval until ctx is done
+func (m *sinkManager) publishMetrics(ctx context.Context, gatherer prometheus.Gatherer, interval time.Duration, heartbeat func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		families, err := gatherer.Gather()
+		if err != nil {
+			slog.Error("Error gathering metrics for sinks", "err", err)
+		}
+		if len(families) > 0 {
+			m.Publish(Event{Time: time.Now(), Metrics: families})
+		}
+		heartbeat()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sinkEventsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "impala_exporter_sink_events_dropped_total",
+			Help: "Number of events dropped because a sink's buffer was full",
+		},
+		[]string{"sink"},
+	)
+	sinkQueueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "impala_exporter_sink_queue_length",
+			Help: "Number of events buffered for a sink",
+		},
+		[]string{"sink"},
+	)
+)
+
+// eventQueue is a bounded FIFO buffer between collection and a sink.
+// Push never blocks: when the buffer is full the oldest event is dropped, so a slow or
+// unavailable sink can neither stall collection nor grow memory without bound.
+type eventQueue[T any] struct {
+	mu       sync.Mutex
+	items    []T
+	head     int
+	size     int
+	notify   chan struct{}
+	dropped  prometheus.Counter
+	queueLen prometheus.Gauge
+}
+
+// newEventQueue creates a queue holding at most capacity events for the named sink
+func newEventQueue[T any](sink string, capacity int) *eventQueue[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &eventQueue[T]{
+		items:    make([]T, capacity),
+		notify:   make(chan struct{}, 1),
+		dropped:  sinkEventsDropped.WithLabelValues(sink),
+		queueLen: sinkQueueLength.WithLabelValues(sink),
+	}
+}
+
+// Push appends an event, dropping the oldest buffered event if the queue is full
+func (q *eventQueue[T]) Push(item T) {
+	q.mu.Lock()
+	if q.size == len(q.items) {
+		var zero T
+		q.items[q.head] = zero
+		q.head = (q.head + 1) % len(q.items)
+		q.size--
+		q.dropped.Inc()
+	}
+	q.items[(q.head+q.size)%len(q.items)] = item
+	q.size++
+	q.queueLen.Set(float64(q.size))
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Pop removes and returns the oldest event, waiting until one is available or ctx is done
+func (q *eventQueue[T]) Pop(ctx context.Context) (T, bool) {
+	for {
+		q.mu.Lock()
+		if q.size > 0 {
+			item := q.items[q.head]
+			var zero T
+			q.items[q.head] = zero
+			q.head = (q.head + 1) % len(q.items)
+			q.size--
+			q.queueLen.Set(float64(q.size))
+			q.mu.Unlock()
+			return item, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			var zero T
+			return zero, false
+		}
+	}
+}
+
+// Len returns the number of buffered events
+func (q *eventQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestEventQueueDropsOldest(t *testing.T) {
+	q := newEventQueue[int]("test_drop", 3)
+	for i := 1; i <= 5; i++ {
+		q.Push(i)
+	}
+	if got := q.Len(); got != 3 {
+		t.Fatalf("Len = %d, want 3", got)
+	}
+	var m dto.Metric
+	if err := sinkEventsDropped.WithLabelValues("test_drop").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetCounter().GetValue(); got != 2 {
+		t.Errorf("dropped = %v, want 2", got)
+	}
+
+	ctx := context.Background()
+	for _, want := range []int{3, 4, 5} {
+		got, ok := q.Pop(ctx)
+		if !ok || got != want {
+			t.Errorf("Pop = %d, %v, want %d, true", got, ok, want)
+		}
+	}
+}
+
+func TestEventQueuePopWaits(t *testing.T) {
+	q := newEventQueue[string]("test_wait", 2)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push("event")
+	}()
+	got, ok := q.Pop(context.Background())
+	if !ok || got != "event" {
+		t.Errorf("Pop = %q, %v, want event, true", got, ok)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := q.Pop(ctx); ok {
+		t.Error("Pop on an empty queue with a cancelled context returned an event")
+	}
+}
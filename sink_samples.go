@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sinkSample is a single sample of a gathered metric, as the metric outputs without a notion of metric families
+// need it: histograms and summaries are broken down into their _bucket, _sum and _count series, as Prometheus does
+type sinkSample struct {
+	Name string
+	// Labels are sorted by name
+	Labels []*dto.LabelPair
+	Value  float64
+	// Time is the timestamp of the metric, or the time of the event when it has none
+	Time time.Time
+	// Type is the type of the family the sample belongs to
+	Type dto.MetricType
+}
+
+// familySamples breaks the gathered families of a metrics event down into samples timestamped now, unless they carry
+// their own timestamp
+func familySamples(families []*dto.MetricFamily, now time.Time) []sinkSample {
+	var samples []sinkSample
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.Metric {
+			t := now
+			if m.TimestampMs != nil {
+				t = time.UnixMilli(m.GetTimestampMs())
+			}
+			add := func(suffix string, value float64, extra ...*dto.LabelPair) {
+				labels := append(append([]*dto.LabelPair(nil), m.Label...), extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+				samples = append(samples, sinkSample{Name: name + suffix, Labels: labels, Value: value, Time: t, Type: family.GetType()})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().Quantile {
+					add("", q.GetValue(), labelPair("quantile", formatBound(q.GetQuantile())))
+				}
+				add("_sum", m.GetSummary().GetSampleSum())
+				add("_count", float64(m.GetSummary().GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				infSeen := false
+				for _, b := range m.GetHistogram().Bucket {
+					infSeen = infSeen || math.IsInf(b.GetUpperBound(), 1)
+					add("_bucket", float64(b.GetCumulativeCount()), labelPair("le", formatBound(b.GetUpperBound())))
+				}
+				if !infSeen {
+					add("_bucket", float64(m.GetHistogram().GetSampleCount()), labelPair("le", "+Inf"))
+				}
+				add("_sum", m.GetHistogram().GetSampleSum())
+				add("_count", float64(m.GetHistogram().GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// labelPair returns a label of the given name and value
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: &name, Value: &value}
+}
+
+// formatBound renders a bucket bound or quantile as Prometheus does in its le and quantile labels
+func formatBound(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sampleKeys renders samples as name{label="value",...} value lines
+func sampleKeys(samples []sinkSample) []string {
+	var keys []string
+	for _, s := range samples {
+		var labels []string
+		for _, l := range s.Labels {
+			labels = append(labels, l.GetName()+`="`+l.GetValue()+`"`)
+		}
+		keys = append(keys, s.Name+"{"+strings.Join(labels, ",")+"} "+formatBound(s.Value))
+	}
+	return keys
+}
+
+func TestFamilySamples(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up"}, []string{"impala_server"})
+	up.WithLabelValues("coord").Set(1)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "impala_query_seconds", Buckets: []float64{1, 10}}, []string{"pool"})
+	duration.WithLabelValues("etl").Observe(5)
+	registry.MustRegister(up, duration)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	samples := familySamples(families, now)
+	got := strings.Join(sampleKeys(samples), "\n")
+	want := strings.Join([]string{
+		`impala_query_seconds_bucket{le="1",pool="etl"} 0`,
+		`impala_query_seconds_bucket{le="10",pool="etl"} 1`,
+		`impala_query_seconds_bucket{le="+Inf",pool="etl"} 1`,
+		`impala_query_seconds_sum{pool="etl"} 5`,
+		`impala_query_seconds_count{pool="etl"} 1`,
+		`impala_up{impala_server="coord"} 1`,
+	}, "\n")
+	if got != want {
+		t.Errorf("familySamples() =\n%s\nwant\n%s", got, want)
+	}
+	for _, s := range samples {
+		if !s.Time.Equal(now) {
+			t.Errorf("sample %s at %v, want the event time", s.Name, s.Time)
+		}
+	}
+}
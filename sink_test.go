@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	events  []Event
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	return nil
+}
+
+func (s *recordingSink) Emit(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func TestSinkManagerDeliversEvents(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"}))
+
+	sink := &recordingSink{}
+	sup := newSupervisor(time.Hour)
+	defer sup.Close()
+	m, err := startSinks(sup, []Sink{sink}, reg, 5*time.Millisecond, 10)
+	if err != nil {
+		t.Fatalf("startSinks: %v", err)
+	}
+	m.Publish(Event{Time: time.Now()})
+
+	deadline := time.Now().Add(time.Second)
+	for sink.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	m.Close()
+
+	if !sink.started || !sink.closed {
+		t.Errorf("started = %v, closed = %v, want both true", sink.started, sink.closed)
+	}
+	if sink.count() < 2 {
+		t.Fatalf("got %d events, want the published one and at least one metrics snapshot", sink.count())
+	}
+	var snapshots int
+	for _, event := range sink.events {
+		if len(event.Metrics) > 0 {
+			snapshots++
+		}
+	}
+	if snapshots == 0 {
+		t.Error("no metrics snapshot was delivered")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxStatementSnippet is the number of bytes of a statement written to the slow query log
+const maxStatementSnippet = 512
+
+// slowQueryEntry is a line of the slow query log
+type slowQueryEntry struct {
+	Time            time.Time `json:"time"`
+	ImpalaServer    string    `json:"impala_server"`
+	QueryID         string    `json:"query_id"`
+	User            string    `json:"user"`
+	Pool            string    `json:"pool"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Statement       string    `json:"statement"`
+}
+
+// slowQueryLog appends a JSON line to a file for every in-flight query crossing threshold, once per query.
+// The file is rotated once it would grow beyond maxSize, keeping maxFiles rotated files as path.1, path.2, ...
+type slowQueryLog struct {
+	path      string
+	threshold time.Duration
+	maxSize   int64
+	maxFiles  int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	logged queryTracker
+}
+
+// newSlowQueryLog opens path for appending, creating it if needed
+func newSlowQueryLog(path string, threshold time.Duration, maxSize int64, maxFiles int) (*slowQueryLog, error) {
+	l := &slowQueryLog{path: path, threshold: threshold, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *slowQueryLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// record logs the entries of the queries of server above threshold not logged by a previous scrape
+func (l *slowQueryLog) record(server string, entries []slowQueryEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logged.report(server, entries, func(entry slowQueryEntry) bool {
+		if err := l.write(entry); err != nil {
+			slog.Warn("Error writing slow query log", "path", l.path, "err", err)
+			return false
+		}
+		return true
+	})
+}
+
+func (l *slowQueryLog) write(entry slowQueryEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate shifts the rotated files by one, dropping the oldest, moves the file to path.1 and opens a new one
+func (l *slowQueryLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if l.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(l.path, 0); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Close closes the log file
+func (l *slowQueryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// statementSnippet returns the beginning of a statement, cut at a character boundary
+func statementSnippet(stmt string) string {
+	if len(stmt) <= maxStatementSnippet {
+		return stmt
+	}
+	cut := maxStatementSnippet
+	for cut > 0 && !utf8.RuneStart(stmt[cut]) {
+		cut--
+	}
+	return stmt[:cut] + "..."
+}
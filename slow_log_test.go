@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readSlowLog(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry slowQueryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, entry.QueryID)
+	}
+	return ids
+}
+
+func TestSlowQueryLogOncePerQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	l, err := newSlowQueryLog(path, time.Minute, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.record("coord", []slowQueryEntry{{QueryID: "a"}, {QueryID: "b"}})
+	l.record("coord", []slowQueryEntry{{QueryID: "b"}, {QueryID: "c"}})
+	l.record("other", []slowQueryEntry{{QueryID: "b"}})
+	// a finished and is forgotten, a query ID seen again is logged again
+	l.record("coord", []slowQueryEntry{{QueryID: "a"}})
+	if got, want := strings.Join(readSlowLog(t, path), ","), "a,b,c,b,a"; got != want {
+		t.Errorf("logged %s, want %s", got, want)
+	}
+}
+
+func TestSlowQueryLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	l, err := newSlowQueryLog(path, time.Minute, 150, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// Each line is about 130 bytes, so every line goes to a new file
+	for _, id := range []string{"q1", "q2", "q3", "q4"} {
+		l.record("coord", []slowQueryEntry{{QueryID: id}})
+	}
+	for file, want := range map[string]string{path: "q4", path + ".1": "q3", path + ".2": "q2"} {
+		if got := strings.Join(readSlowLog(t, file), ","); got != want {
+			t.Errorf("%s holds %s, want %s", filepath.Base(file), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 rotated files kept")
+	}
+}
+
+func TestCaptureStatement(t *testing.T) {
+	stmt := "select * from t where name = 'bob'" + strings.Repeat(" ", maxStatementSnippet)
+	e := NewExporter(nil, ExporterOptions{ScrubLiterals: true})
+	if got := e.captureStatement(stmt); !strings.HasPrefix(got, "select * from t where name = ?") || len(got) != maxStatementSnippet+3 {
+		t.Errorf("captureStatement() = %q, want the scrubbed statement cut at %d bytes", got, maxStatementSnippet)
+	}
+	e = NewExporter(nil, ExporterOptions{})
+	if got := e.captureStatement("select 1"); got != "select 1" {
+		t.Errorf("captureStatement() without scrubbing = %q, want the statement as is", got)
+	}
+}
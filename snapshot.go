@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// descMeta is the name and help of a descriptor, recorded so that collected const metrics can be persisted
+type descMeta struct {
+	name string
+	help string
+}
+
+// SnapshotLabel is a label of a persisted metric
+type SnapshotLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SnapshotMetric is a persisted gauge or counter sample
+type SnapshotMetric struct {
+	Name   string          `json:"name"`
+	Help   string          `json:"help"`
+	Type   string          `json:"type"`
+	Labels []SnapshotLabel `json:"labels"`
+	Value  float64         `json:"value"`
+}
+
+// TargetSnapshot holds the metrics of the last complete scrape of a server
+type TargetSnapshot struct {
+	Time    time.Time        `json:"time"`
+	Metrics []SnapshotMetric `json:"metrics"`
+}
+
+// recordSnapshot keeps the metrics of a complete scrape of target, to be served if a later scrape of it times out
+func (e *Exporter) recordSnapshot(target string, metrics []prometheus.Metric) {
+	snapshot := TargetSnapshot{Time: time.Now()}
+	for _, m := range metrics {
+		meta, ok := e.descMeta[m.Desc()]
+		if !ok {
+			continue
+		}
+		var out dto.Metric
+		if err := m.Write(&out); err != nil {
+			continue
+		}
+		s := SnapshotMetric{Name: meta.name, Help: meta.help}
+		switch {
+		case out.Gauge != nil:
+			s.Type, s.Value = "gauge", out.Gauge.GetValue()
+		case out.Counter != nil:
+			s.Type, s.Value = "counter", out.Counter.GetValue()
+		default:
+			continue
+		}
+		for _, l := range out.Label {
+			s.Labels = append(s.Labels, SnapshotLabel{Name: l.GetName(), Value: l.GetValue()})
+		}
+		snapshot.Metrics = append(snapshot.Metrics, s)
+	}
+
+	e.snapshotsMu.Lock()
+	e.snapshots[target] = snapshot
+	e.snapshotsMu.Unlock()
+}
+
+// snapshot returns the last complete scrape of target if it is recent enough to be served
+func (e *Exporter) snapshot(target string) (TargetSnapshot, bool) {
+	e.snapshotsMu.Lock()
+	defer e.snapshotsMu.Unlock()
+	s, ok := e.snapshots[target]
+	if !ok || e.options.SnapshotMaxAge <= 0 || time.Since(s.Time) > e.options.SnapshotMaxAge {
+		return TargetSnapshot{}, false
+	}
+	return s, true
+}
+
+// constMetrics rebuilds the metrics of a snapshot, skipping those the exporter no longer exports
+// since a metric with an undescribed name would fail the whole scrape
+func (e *Exporter) constMetrics(s TargetSnapshot) []prometheus.Metric {
+	names := make(map[string]bool, len(e.descMeta))
+	for _, meta := range e.descMeta {
+		names[meta.name] = true
+	}
+	// Target info is sent by Collect for every server, older snapshots may still hold it
+	names[e.descMeta[e.targetInfo].name] = false
+
+	var metrics []prometheus.Metric
+	for _, sm := range s.Metrics {
+		if !names[sm.Name] {
+			continue
+		}
+		valueType := prometheus.GaugeValue
+		if sm.Type == "counter" {
+			valueType = prometheus.CounterValue
+		}
+		labelNames := make([]string, 0, len(sm.Labels))
+		labelValues := make([]string, 0, len(sm.Labels))
+		for _, l := range sm.Labels {
+			labelNames = append(labelNames, l.Name)
+			labelValues = append(labelValues, l.Value)
+		}
+		m, err := prometheus.NewConstMetric(prometheus.NewDesc(sm.Name, sm.Help, labelNames, nil), valueType, sm.Value, labelValues...)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// SaveSnapshots writes the last complete scrape of every server to path as gzip-compressed JSON,
+// encrypted with c when set
+func (e *Exporter) SaveSnapshots(path string, c *atRestCipher) error {
+	e.snapshotsMu.Lock()
+	data, err := json.Marshal(e.snapshots)
+	e.snapshotsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	sealed, err := c.Seal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed)
+}
+
+// LoadSnapshots reads the snapshots saved by SaveSnapshots, if the file exists.
+// The snapshots keep the time of their scrape, so they are reported with their true data age.
+func (e *Exporter) LoadSnapshots(path string, c *atRestCipher) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if data, err = c.Open(data); err != nil {
+		return err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	var snapshots map[string]TargetSnapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return err
+	}
+	e.snapshotsMu.Lock()
+	defer e.snapshotsMu.Unlock()
+	maps.Copy(e.snapshots, snapshots)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSnapshotServedAfterRestart(t *testing.T) {
+	var hang atomic.Bool
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			<-r.Context().Done()
+			return
+		}
+		switch r.URL.Path {
+		case "/sessions":
+			w.Write([]byte(`{"client_hosts": [{"hostname": "client", "total_sessions": 7}]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer impala.Close()
+	server := strings.TrimPrefix(impala.URL, "http://")
+	options := ExporterOptions{ScrapeTimeout: 200 * time.Millisecond, SnapshotMaxAge: time.Hour}
+
+	gather := func(e *Exporter) map[string]float64 {
+		t.Helper()
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(e)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		values := make(map[string]float64)
+		for _, family := range families {
+			for _, m := range family.Metric {
+				values[family.GetName()] = m.GetGauge().GetValue()
+			}
+		}
+		return values
+	}
+
+	before := NewExporter([]string{server}, options)
+	if got := gather(before); got["impala_total_sessions"] != 7 || got["impala_target_data_age_seconds"] != 0 {
+		t.Fatalf("live scrape: total_sessions = %v, data age = %v", got["impala_total_sessions"], got["impala_target_data_age_seconds"])
+	}
+	path := filepath.Join(t.TempDir(), "snapshots.gz")
+	if err := before.SaveSnapshots(path, nil); err != nil {
+		t.Fatalf("SaveSnapshots: %v", err)
+	}
+
+	hang.Store(true)
+	after := NewExporter([]string{server}, options)
+	if err := after.LoadSnapshots(path, nil); err != nil {
+		t.Fatalf("LoadSnapshots: %v", err)
+	}
+	got := gather(after)
+	if got["impala_total_sessions"] != 7 {
+		t.Errorf("timed out scrape: total_sessions = %v, want 7 from the snapshot", got["impala_total_sessions"])
+	}
+	if age, ok := got["impala_target_data_age_seconds"]; !ok || age <= 0 {
+		t.Errorf("timed out scrape: data age = %v (present %v), want > 0", age, ok)
+	}
+
+	expired := NewExporter([]string{server}, ExporterOptions{ScrapeTimeout: 200 * time.Millisecond})
+	if err := expired.LoadSnapshots(path, nil); err != nil {
+		t.Fatalf("LoadSnapshots: %v", err)
+	}
+	if got := gather(expired); got["impala_total_sessions"] != 0 {
+		t.Errorf("snapshot served with SnapshotMaxAge 0")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scratchBytesWrittenRe matches the bytes an operator spilled to scratch in a query profile, e.g.
+// "ScratchBytesWritten: 1.00 MB (1048576)", rendered as "ScratchBytesWritten: 0" until it spills
+var scratchBytesWrittenRe = regexp.MustCompile(`ScratchBytesWritten: (\S+)`)
+
+// profileSpills reports whether any operator of a query profile wrote to scratch
+func profileSpills(profile string) bool {
+	for _, m := range scratchBytesWrittenRe.FindAllStringSubmatch(profile, -1) {
+		if m[1] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSpillMetrics sends the bytes a daemon spilled to its scratch directories since it started over to the
+// provided channel
+func (e *Exporter) collectSpillMetrics(ch chan<- prometheus.Metric, server string, values map[string]float64) {
+	if value, ok := values["impala-server.io-mgr.bytes-written"]; ok {
+		ch <- prometheus.MustNewConstMetric(e.spilledBytes, prometheus.CounterValue, value, server)
+	}
+}
+
+// collectSpillingQueries fetches the profiles of the running queries of a coordinator and sends the number of them
+// that spilled to scratch over to the provided channel. Only MaxProfilesPerScrape profiles are fetched, within the
+// profile budget of the scrape, so on a busy coordinator the count is a lower bound.
+func (e *Exporter) collectSpillingQueries(ctx context.Context, ch chan<- prometheus.Metric, target Target, running []string) {
+	if !e.options.SpillingQueries {
+		return
+	}
+	ctx, cancel := profileFetchBudget(ctx)
+	defer cancel()
+
+	spilling := 0
+	for _, id := range running[:min(len(running), e.options.MaxProfilesPerScrape)] {
+		var resp QueryProfileResponse
+		if err := fetchJSON(ctx, target.Address, "/query_profile?json&query_id="+url.QueryEscape(id), &resp); err != nil {
+			slog.Debug("Error fetching query profile", "target", target.Name, "endpoint", "/query_profile?json", "query_id", id, "err", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if profileSpills(resp.Profile) {
+			spilling++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.spillingQueries, prometheus.GaugeValue, float64(spilling), target.Name)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestProfileSpills(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    bool
+	}{
+		{"HASH_JOIN_NODE (id=2):\n  ScratchBytesWritten: 0\nAGGREGATION_NODE (id=3):\n  ScratchBytesWritten: 0\n", false},
+		{"HASH_JOIN_NODE (id=2):\n  ScratchBytesWritten: 0\nSORT_NODE (id=4):\n  ScratchBytesWritten: 1.00 MB (1048576)\n", true},
+		{"SCAN_HDFS_NODE (id=0):\n  BytesRead: 1.00 GB (1073741824)\n", false},
+	}
+	for _, tt := range tests {
+		if got := profileSpills(tt.profile); got != tt.want {
+			t.Errorf("profileSpills(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestCollectSpillingQueries(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/queries":
+			w.Write([]byte(`{"in_flight_queries": [
+				{"query_id": "1:0", "duration": "1m"},
+				{"query_id": "2:0", "duration": "2m"},
+				{"query_id": "3:0", "duration": "3m", "waiting": true}
+			]}`))
+		case "/query_profile":
+			scratch := "0"
+			if id := r.URL.Query().Get("query_id"); id != "1:0" {
+				scratch = "16.00 MB (16777216)"
+			}
+			w.Write([]byte(`{"profile": "SORT_NODE (id=1):\n  ScratchBytesWritten: ` + scratch + `\n"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer impala.Close()
+
+	// The query waiting to be closed is done executing, its profile is not fetched
+	e := NewExporter(nil, ExporterOptions{SpillingQueries: true, MaxProfilesPerScrape: 10})
+	target := newTarget(strings.TrimPrefix(impala.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (queriesCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	if got["impala_spilling_queries"] != 1 {
+		t.Errorf("impala_spilling_queries = %v, want 1", got["impala_spilling_queries"])
+	}
+}
+
+func TestCollectSpillMetrics(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectSpillMetrics(ch, "executor", map[string]float64{"impala-server.io-mgr.bytes-written": 5 << 30})
+	})
+	if want := map[string]float64{"impala_spilled_bytes_total": 5 << 30}; !maps.Equal(got, want) {
+		t.Errorf("collectSpillMetrics() = %v, want %v", got, want)
+	}
+}
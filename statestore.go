@@ -0,0 +1,85 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultHeartbeatTimeout is how long a subscriber may go without a heartbeat by default: the statestore's own
+// -statestore_max_missed_heartbeats of its -statestore_heartbeat_frequency_ms, 10 heartbeats 1s apart
+const defaultHeartbeatTimeout = 10 * time.Second
+
+// Seconds is a duration in seconds that the statestore renders either as a JSON number or as a string such as "0.523"
+type Seconds float64
+
+// UnmarshalJSON accepts a number of seconds or a string holding one
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = Seconds(n)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("invalid seconds %s", data)
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return fmt.Errorf("invalid seconds %q: %w", str, err)
+	}
+	*s = Seconds(n)
+	return nil
+}
+
+// StatestoreSubscriber represents a daemon registered with the statestore, as listed on its /subscribers page
+type StatestoreSubscriber struct {
+	ID                 string  `json:"id"`
+	Address            string  `json:"address"`
+	SecsSinceHeartbeat Seconds `json:"secs_since_heartbeat"`
+}
+
+// SubscribersResponse represents the structure of the JSON response from the statestore for /subscribers
+type SubscribersResponse struct {
+	Subscribers []StatestoreSubscriber `json:"subscribers"`
+}
+
+// statestoreCollector exports the membership of the cluster as the statestore sees it, so that subscribers flapping
+// in and out of it show up without grepping its logs
+type statestoreCollector struct {
+	e *Exporter
+}
+
+// Describe sends the descriptors of the statestore subscriber metrics over to the provided channel
+func (c statestoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.e.statestoreSubscribers
+	ch <- c.e.statestoreHeartbeatFailed
+	ch <- c.e.statestoreSinceHeartbeat
+}
+
+// Collect fetches the subscribers of a statestore and sends their number, the number of them that missed their
+// heartbeats for longer than HeartbeatTimeout and the time since the last heartbeat of each over to the provided
+// channel
+func (c statestoreCollector) Collect(ctx context.Context, ch chan<- prometheus.Metric, target Target) error {
+	e := c.e
+	var resp SubscribersResponse
+	if err := fetchJSON(ctx, target.Address, "/subscribers?json", &resp); err != nil {
+		return err
+	}
+	timeout := cmp.Or(e.options.HeartbeatTimeout, defaultHeartbeatTimeout).Seconds()
+	failed := 0
+	for _, s := range resp.Subscribers {
+		if float64(s.SecsSinceHeartbeat) > timeout {
+			failed++
+		}
+		ch <- prometheus.MustNewConstMetric(e.statestoreSinceHeartbeat, prometheus.GaugeValue, float64(s.SecsSinceHeartbeat), target.Name, cmp.Or(s.ID, s.Address))
+	}
+	ch <- prometheus.MustNewConstMetric(e.statestoreSubscribers, prometheus.GaugeValue, float64(len(resp.Subscribers)), target.Name)
+	ch <- prometheus.MustNewConstMetric(e.statestoreHeartbeatFailed, prometheus.GaugeValue, float64(failed), target.Name)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStatestoreCollector(t *testing.T) {
+	statestore := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscribers" {
+			http.NotFound(w, r)
+			return
+		}
+		// Older statestores render the time since the last heartbeat as a string
+		w.Write([]byte(`{"subscribers": [
+			{"id": "impalad@coord:22000", "address": "coord:23000", "num_topics": 3, "secs_since_heartbeat": "0.512"},
+			{"id": "impalad@exec-1:22000", "address": "exec-1:23000", "num_topics": 3, "secs_since_heartbeat": 42.5},
+			{"id": "catalog-server@catalog:26000", "address": "catalog:23020", "num_topics": 1, "secs_since_heartbeat": 1}
+		]}`))
+	}))
+	defer statestore.Close()
+
+	e := NewExporter(nil, ExporterOptions{HeartbeatTimeout: 30 * time.Second})
+	target := newTarget(strings.TrimPrefix(statestore.URL, "http://"), "")
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		if err := (statestoreCollector{e}).Collect(context.Background(), ch, target); err != nil {
+			t.Errorf("Collect() error = %v", err)
+		}
+	})
+	want := map[string]float64{
+		"impala_statestore_subscribers":                                                                   3,
+		"impala_statestore_subscribers_heartbeat_failed":                                                  1,
+		`impala_statestore_subscriber_seconds_since_heartbeat{subscriber="impalad@coord:22000"}`:          0.512,
+		`impala_statestore_subscriber_seconds_since_heartbeat{subscriber="impalad@exec-1:22000"}`:         42.5,
+		`impala_statestore_subscriber_seconds_since_heartbeat{subscriber="catalog-server@catalog:26000"}`: 1,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSecondsUnmarshal(t *testing.T) {
+	for input, want := range map[string]Seconds{`1.5`: 1.5, `"0.250"`: 0.25, `0`: 0} {
+		var s Seconds
+		if err := s.UnmarshalJSON([]byte(input)); err != nil || s != want {
+			t.Errorf("UnmarshalJSON(%s) = %v, %v, want %v", input, s, err, want)
+		}
+	}
+	var s Seconds
+	if err := s.UnmarshalJSON([]byte(`"soon"`)); err == nil {
+		t.Errorf("UnmarshalJSON accepted a non-numeric string")
+	}
+}
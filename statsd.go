@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	statsdAddressFlag   = flag.String("statsd.address", "", "host:port of a StatsD or DogStatsD agent a subset of the metrics, -statsd.metrics, is sent to over UDP every -sink.interval; enables the statsd sink")
+	statsdPrefixFlag    = flag.String("statsd.prefix", "", "Prefix of the metric names sent to -statsd.address, e.g. impala_exporter.")
+	statsdMetricsFlag   = flag.String("statsd.metrics", "impala_up,impala_inflight_queries_count,impala_slow_queries", "Comma-separated names of the metrics sent to -statsd.address")
+	statsdDogStatsDFlag = flag.Bool("statsd.dogstatsd", false, "Send the labels as DogStatsD tags rather than as name components holding the label values")
+)
+
+// statsdMaxPacket is the size the datagrams sent to StatsD are kept under, so that they are not fragmented on an
+// Ethernet network
+const statsdMaxPacket = 1432
+
+func init() {
+	RegisterSink("statsd", func() (Sink, error) {
+		if *statsdAddressFlag == "" {
+			return nil, nil
+		}
+		if _, _, err := net.SplitHostPort(*statsdAddressFlag); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", *statsdAddressFlag, err)
+		}
+		metrics := make(map[string]bool)
+		for _, name := range strings.Split(*statsdMetricsFlag, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				metrics[name] = true
+			}
+		}
+		if len(metrics) == 0 {
+			return nil, fmt.Errorf("empty -statsd.metrics")
+		}
+		return &statsdSink{
+			address:   *statsdAddressFlag,
+			prefix:    *statsdPrefixFlag,
+			metrics:   metrics,
+			dogstatsd: *statsdDogStatsDFlag,
+			counters:  make(map[string]float64),
+		}, nil
+	})
+}
+
+// statsdNameRe matches the characters left out of a StatsD name component
+var statsdNameRe = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// statsdTagRe matches the characters left out of a DogStatsD tag
+var statsdTagRe = regexp.MustCompile(`[,|#\s]`)
+
+// statsdSink sends the snapshots of a subset of the metrics to a StatsD or DogStatsD agent: gauges as gauges, and
+// counters as counts of their increase since the previous snapshot
+type statsdSink struct {
+	address   string
+	prefix    string
+	metrics   map[string]bool
+	dogstatsd bool
+	conn      net.Conn
+	// counters are the last values of the counter series, by name and labels, to send their increase
+	counters map[string]float64
+}
+
+func (s *statsdSink) Name() string {
+	return "statsd"
+}
+
+// Start opens the UDP socket the metrics are sent over
+func (s *statsdSink) Start(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Emit sends the selected metrics of a snapshot event, several lines per datagram, skipping the other events. A
+// counter is first sent on the snapshot following the one it appeared in, its increase being unknown until then.
+func (s *statsdSink) Emit(ctx context.Context, event Event) error {
+	if len(event.Metrics) == 0 {
+		return nil
+	}
+	var selected []*dto.MetricFamily
+	for _, family := range event.Metrics {
+		if s.metrics[family.GetName()] {
+			selected = append(selected, family)
+		}
+	}
+	var packet []byte
+	for _, line := range s.lines(familySamples(selected, event.Time)) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			if _, err := s.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		if _, err := s.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines renders samples as StatsD lines, name:value|type, followed by |#label:value,... tags with DogStatsD
+func (s *statsdSink) lines(samples []sinkSample) []string {
+	var lines []string
+	for _, sample := range samples {
+		name := s.prefix + sample.Name
+		var tags []string
+		for _, l := range sample.Labels {
+			if s.dogstatsd {
+				tags = append(tags, l.GetName()+":"+statsdTagRe.ReplaceAllString(l.GetValue(), "_"))
+				continue
+			}
+			name += "." + statsdNameRe.ReplaceAllString(l.GetValue(), "_")
+		}
+		value, kind := sample.Value, "g"
+		if sample.Type == dto.MetricType_COUNTER {
+			key := name + "|" + strings.Join(tags, ",")
+			last, seen := s.counters[key]
+			s.counters[key] = sample.Value
+			if !seen {
+				continue
+			}
+			// A counter going down was reset by a restart, it increased by its whole value since
+			if value = sample.Value - last; value < 0 {
+				value = sample.Value
+			}
+			kind = "c"
+		}
+		line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (s *statsdSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStatsdSink(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_up"}, []string{"impala_server"})
+	up.WithLabelValues("coord.example.com:25000").Set(1)
+	retried := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "impala_retried_queries_total"}, []string{"impala_server"})
+	retried.WithLabelValues("coord.example.com:25000").Add(3)
+	ignored := prometheus.NewGauge(prometheus.GaugeOpts{Name: "impala_ignored"})
+	registry.MustRegister(up, retried, ignored)
+
+	s := &statsdSink{
+		address:   listener.LocalAddr().String(),
+		prefix:    "dc1.",
+		metrics:   map[string]bool{"impala_up": true, "impala_retried_queries_total": true},
+		dogstatsd: true,
+		counters:  make(map[string]float64),
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	read := func() string {
+		t.Helper()
+		buf := make([]byte, statsdMaxPacket)
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+	emit := func() {
+		t.Helper()
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Emit(context.Background(), Event{Time: time.Now(), Metrics: families}); err != nil {
+			t.Fatalf("Emit() error = %v", err)
+		}
+	}
+
+	// The counter is left out until its increase is known
+	emit()
+	if got, want := read(), "dc1.impala_up:1|g|#impala_server:coord.example.com:25000"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	retried.WithLabelValues("coord.example.com:25000").Add(2)
+	emit()
+	want := "dc1.impala_retried_queries_total:2|c|#impala_server:coord.example.com:25000\n" +
+		"dc1.impala_up:1|g|#impala_server:coord.example.com:25000"
+	if got := read(); got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestStatsdSinkLines(t *testing.T) {
+	s := &statsdSink{counters: make(map[string]float64)}
+	registry := prometheus.NewRegistry()
+	slow := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "impala_slow_queries"}, []string{"impala_server", "threshold"})
+	slow.WithLabelValues("coord.example.com:25000", "60").Set(4)
+	registry.MustRegister(slow)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(s.lines(familySamples(families, time.Now())), "\n")
+	if want := "impala_slow_queries.coord_example_com_25000.60:4|g"; got != want {
+		t.Errorf("lines() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ScrapeStatus is the outcome of the last scrape of a server
+type ScrapeStatus struct {
+	Time            time.Time `json:"time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// Up is the value of impala_up: whether every collector required for the role of the server succeeded
+	Up bool `json:"up"`
+	// Error lists the collectors that failed with their errors, including those not required for Up
+	Error string `json:"error,omitempty"`
+}
+
+// scrapeStatusTracker holds the last scrape status of every server, by name
+type scrapeStatusTracker struct {
+	mu      sync.Mutex
+	servers map[string]ScrapeStatus
+}
+
+// record stores the outcome of a scrape of server
+func (t *scrapeStatusTracker) record(server string, status ScrapeStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.servers == nil {
+		t.servers = make(map[string]ScrapeStatus)
+	}
+	t.servers[server] = status
+}
+
+// get returns the last scrape status of server, reporting false when it was never scraped
+func (t *scrapeStatusTracker) get(server string) (ScrapeStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.servers[server]
+	return status, ok
+}
+
+// TargetStatus is a configured server and the outcome of its last scrape, as served on /api/v1/targets
+type TargetStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Role    string `json:"role"`
+	Cluster string `json:"cluster,omitempty"`
+	// LastScrape is null until the server has been scraped
+	LastScrape *ScrapeStatus `json:"last_scrape"`
+}
+
+// ExporterStatus is the state of the exporter as a whole, as served on /api/v1/status
+type ExporterStatus struct {
+	Version       string    `json:"version"`
+	StartTime     time.Time `json:"start_time"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	// Scraped is set once any server has been scraped successfully, see -web.ready-after-first-scrape
+	Scraped bool `json:"scraped"`
+	Targets int  `json:"targets"`
+	// TargetsUp counts the targets whose last scrape succeeded
+	TargetsUp int `json:"targets_up"`
+	// LastScrape is when a server was last scraped, null until then
+	LastScrape *time.Time `json:"last_scrape"`
+	Collectors []string   `json:"collectors"`
+}
+
+// targetStatuses returns the configured targets with the outcome of their last scrape
+func (e *Exporter) targetStatuses() []TargetStatus {
+	targets := e.Targets()
+	statuses := make([]TargetStatus, 0, len(targets))
+	for _, target := range targets {
+		status := TargetStatus{Name: target.Name, Address: target.Address, Role: target.Role, Cluster: target.Cluster}
+		if scrape, ok := e.scrapeStatus.get(target.Name); ok {
+			status.LastScrape = &scrape
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// status returns the state of the exporter, started at start
+func (e *Exporter) status(start time.Time) ExporterStatus {
+	status := ExporterStatus{
+		Version:       versionString(),
+		StartTime:     start.UTC(),
+		UptimeSeconds: time.Since(start).Seconds(),
+		Scraped:       e.Scraped(),
+		Collectors:    []string{},
+	}
+	for _, target := range e.targetStatuses() {
+		status.Targets++
+		if target.LastScrape == nil {
+			continue
+		}
+		if target.LastScrape.Up {
+			status.TargetsUp++
+		}
+		if status.LastScrape == nil || target.LastScrape.Time.After(*status.LastScrape) {
+			status.LastScrape = &target.LastScrape.Time
+		}
+	}
+	for _, c := range e.collectors {
+		if e.collectorEnabled(c.name) {
+			status.Collectors = append(status.Collectors, c.name)
+		}
+	}
+	return status
+}
+
+// registerStatusAPI mounts the read-only status API on the given mux. It serves the state tracked by the scrapes
+// only and never contacts the servers itself.
+func registerStatusAPI(mux *http.ServeMux, exporter *Exporter, start time.Time) {
+	mux.Handle("GET /api/v1/targets", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, exporter.targetStatuses())
+	}))
+	mux.Handle("GET /api/v1/status", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, exporter.status(start))
+	}))
+}
+
+// writeJSON serves v as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error writing JSON response", "err", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestStatusAPI(t *testing.T) {
+	impala := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sessions" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer impala.Close()
+	address := strings.TrimPrefix(impala.URL, "http://")
+
+	e := NewExporter([]string{"coord=" + address, "never=127.0.0.1:1"}, ExporterOptions{Collectors: map[string]bool{"queries": true, "sessions": true}})
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	e.collectTarget(context.Background(), ch, newTarget("coord="+address, ""))
+	close(ch)
+
+	mux := http.NewServeMux()
+	start := time.Now().Add(-time.Minute)
+	registerStatusAPI(mux, e, start)
+	get := func(path string, v any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("GET %s = %d %s, want JSON", path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+
+	var targets []TargetStatus
+	get("/api/v1/targets", &targets)
+	if len(targets) != 2 || targets[0].Name != "coord" || targets[0].Address != address || targets[1].Name != "never" {
+		t.Fatalf("targets = %+v, want coord and never", targets)
+	}
+	scrape := targets[0].LastScrape
+	if scrape == nil || scrape.Up || !strings.HasPrefix(scrape.Error, "sessions: ") || scrape.Time.IsZero() {
+		t.Errorf("coord last scrape = %+v, want a failed scrape with the sessions error", scrape)
+	}
+	if targets[1].LastScrape != nil {
+		t.Errorf("never last scrape = %+v, want none", targets[1].LastScrape)
+	}
+
+	var status ExporterStatus
+	get("/api/v1/status", &status)
+	if status.Targets != 2 || status.TargetsUp != 0 || status.LastScrape == nil || !status.StartTime.Equal(start) {
+		t.Errorf("status = %+v, want 2 targets, none up, scraped once", status)
+	}
+	if status.UptimeSeconds < 60 || strings.Join(status.Collectors, ",") != "sessions,queries" {
+		t.Errorf("status uptime %v and collectors %q, want over a minute and sessions,queries", status.UptimeSeconds, status.Collectors)
+	}
+}
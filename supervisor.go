@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	supervisedHeartbeatAge = prometheus.NewDesc(
+		"impala_exporter_supervised_goroutine_heartbeat_age_seconds",
+		"Seconds since a supervised background goroutine last reported progress",
+		[]string{"task"},
+		nil,
+	)
+	supervisedRestarts = prometheus.NewDesc(
+		"impala_exporter_supervised_goroutine_restarts_total",
+		"Number of times a supervised background goroutine was restarted after it stopped heartbeating or exited",
+		[]string{"task"},
+		nil,
+	)
+)
+
+// staleAfter is how long a loop running every interval may go without a heartbeat before it is considered wedged
+func staleAfter(interval time.Duration) time.Duration {
+	return 3*interval + time.Minute
+}
+
+// supervisor runs long-lived background goroutines such as discovery, sink and update loops.
+// Each goroutine calls its heartbeat function whenever it makes progress; one that has not done so
+// within its stale duration is cancelled, and one that exits is started anew.
+// At most one instance of a task runs at a time: a cancelled instance is only replaced once it has returned.
+type supervisor struct {
+	checkInterval time.Duration
+
+	mu    sync.Mutex
+	tasks []*supervisedTask
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// supervisedTask is a goroutine managed by a supervisor
+type supervisedTask struct {
+	name       string
+	staleAfter time.Duration
+	run        func(ctx context.Context, heartbeat func())
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	restarts int
+	stopped  bool
+	// cancelled is set once the current instance was cancelled for not heartbeating
+	cancelled bool
+	cancelRun context.CancelFunc
+	done      chan struct{}
+}
+
+// newSupervisor starts a supervisor checking its goroutines every checkInterval
+func newSupervisor(checkInterval time.Duration) *supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &supervisor{checkInterval: checkInterval, cancel: cancel}
+	s.wg.Add(1)
+	go s.watch(ctx)
+	return s
+}
+
+// Close stops watching the goroutines; the goroutines themselves are stopped by their owners through Stop
+func (s *supervisor) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Go starts run under supervision. The context passed to run is cancelled when the task is stopped or restarted.
+func (s *supervisor) Go(name string, staleAfter time.Duration, run func(ctx context.Context, heartbeat func())) *supervisedTask {
+	t := &supervisedTask{name: name, staleAfter: staleAfter, run: run}
+	t.mu.Lock()
+	t.startLocked()
+	t.mu.Unlock()
+	s.mu.Lock()
+	s.tasks = append(s.tasks, t)
+	s.mu.Unlock()
+	return t
+}
+
+// watch restarts wedged or exited goroutines every checkInterval until ctx is done
+func (s *supervisor) watch(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		tasks := append([]*supervisedTask(nil), s.tasks...)
+		s.mu.Unlock()
+		for _, t := range tasks {
+			t.check()
+		}
+	}
+}
+
+// Describe sends the descriptors of the supervisor metrics over to the provided channel
+func (s *supervisor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- supervisedHeartbeatAge
+	ch <- supervisedRestarts
+}
+
+// Collect sends the heartbeat age and restart count of every running task over to the provided channel
+func (s *supervisor) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	tasks := append([]*supervisedTask(nil), s.tasks...)
+	s.mu.Unlock()
+	for _, t := range tasks {
+		t.mu.Lock()
+		age, restarts, stopped := time.Since(t.lastBeat).Seconds(), t.restarts, t.stopped
+		t.mu.Unlock()
+		if stopped {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(supervisedHeartbeatAge, prometheus.GaugeValue, age, t.name)
+		ch <- prometheus.MustNewConstMetric(supervisedRestarts, prometheus.CounterValue, float64(restarts), t.name)
+	}
+}
+
+// startLocked runs a new instance of the task; t.mu must be held
+func (t *supervisedTask) startLocked() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.cancelRun, t.done, t.lastBeat, t.cancelled = cancel, done, time.Now(), false
+	// Each instance heartbeats for itself only, so a late beat of a former instance cannot hide a stuck one
+	heartbeat := func() {
+		t.mu.Lock()
+		if t.done == done {
+			t.lastBeat = time.Now()
+		}
+		t.mu.Unlock()
+	}
+
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("Supervised goroutine panicked", "task", t.name, "panic", r)
+			}
+		}()
+		t.run(ctx, heartbeat)
+	}()
+}
+
+// check restarts the task if it exited, and cancels it if it has not heartbeated within its stale duration.
+// A wedged instance cannot be killed: it is only restarted once it honours the cancellation and returns,
+// so that a task such as a sink worker never runs twice concurrently.
+func (t *supervisedTask) check() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	defer t.mu.Unlock()
+	select {
+	case <-t.done:
+		reason := "exited"
+		if t.cancelled {
+			reason = "stopped heartbeating"
+		}
+		slog.Warn("Restarting supervised goroutine", "task", t.name, "reason", reason)
+		t.restarts++
+		t.startLocked()
+	default:
+		if age := time.Since(t.lastBeat); !t.cancelled && age > t.staleAfter {
+			slog.Warn("Cancelling supervised goroutine, it is restarted once it returns", "task", t.name, "reason", "stopped heartbeating", "heartbeat_age", age)
+			t.cancelled = true
+			t.cancelRun()
+		}
+	}
+}
+
+// Stop cancels the task and waits for its current instance to return
+func (t *supervisedTask) Stop() {
+	t.mu.Lock()
+	t.stopped = true
+	t.cancelRun()
+	done := t.done
+	t.mu.Unlock()
+	<-done
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestSupervisorRestartsWedgedTask(t *testing.T) {
+	sup := newSupervisor(5 * time.Millisecond)
+	defer sup.Close()
+
+	var starts atomic.Int32
+	task := sup.Go("wedged", 20*time.Millisecond, func(ctx context.Context, heartbeat func()) {
+		starts.Add(1)
+		// Never heartbeats again, but honours cancellation
+		<-ctx.Done()
+	})
+	if !waitFor(t, func() bool { return starts.Load() >= 2 }) {
+		t.Fatalf("task started %d times, want a restart", starts.Load())
+	}
+	task.Stop()
+
+	stopped := starts.Load()
+	time.Sleep(50 * time.Millisecond)
+	if starts.Load() != stopped {
+		t.Errorf("task restarted after Stop")
+	}
+}
+
+func TestSupervisorRestartsExitedTask(t *testing.T) {
+	sup := newSupervisor(5 * time.Millisecond)
+	defer sup.Close()
+
+	var starts atomic.Int32
+	task := sup.Go("exiting", time.Hour, func(ctx context.Context, heartbeat func()) {
+		if starts.Add(1) == 1 {
+			panic("first run fails")
+		}
+		<-ctx.Done()
+	})
+	defer task.Stop()
+	if !waitFor(t, func() bool { return starts.Load() >= 2 }) {
+		t.Fatalf("task started %d times, want a restart after it exited", starts.Load())
+	}
+}
+
+func TestSupervisorKeepsHealthyTask(t *testing.T) {
+	sup := newSupervisor(5 * time.Millisecond)
+	defer sup.Close()
+
+	var starts atomic.Int32
+	task := sup.Go("healthy", 20*time.Millisecond, func(ctx context.Context, heartbeat func()) {
+		starts.Add(1)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				heartbeat()
+			}
+		}
+	})
+	time.Sleep(100 * time.Millisecond)
+	task.Stop()
+	if n := starts.Load(); n != 1 {
+		t.Errorf("healthy task started %d times, want 1", n)
+	}
+}
+
+func TestSupervisorWaitsForCancelledTask(t *testing.T) {
+	sup := newSupervisor(5 * time.Millisecond)
+	defer sup.Close()
+
+	var starts, running, overlaps atomic.Int32
+	release := make(chan struct{})
+	task := sup.Go("slow-to-cancel", 20*time.Millisecond, func(ctx context.Context, heartbeat func()) {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer running.Add(-1)
+		if starts.Add(1) == 1 {
+			// Ignores cancellation until released, like a sink stuck in Emit
+			<-release
+			return
+		}
+		<-ctx.Done()
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	if n := starts.Load(); n != 1 {
+		t.Fatalf("task started %d times while its first instance was still running, want 1", n)
+	}
+	close(release)
+	if !waitFor(t, func() bool { return starts.Load() == 2 }) {
+		t.Fatalf("task started %d times, want a restart once the first instance returned", starts.Load())
+	}
+	task.Stop()
+	if n := overlaps.Load(); n != 0 {
+		t.Errorf("%d instances ran concurrently", n)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultWebPortRoles maps the default web UI port of each Impala daemon to its role
+var defaultWebPortRoles = map[string]string{
+	"25000": "impalad",
+	"25010": "statestored",
+	"25020": "catalogd",
+}
+
+// Target is an Impala server to scrape
+type Target struct {
+	// Name is the value of the impala_server label: the alias of the server if given, its address otherwise
+	Name string
+	// Address is the host:port of the server's web UI
+	Address string
+	Role    string
+	Cluster string
+	Scheme  string
+	Port    string
+}
+
+// newTarget describes the server configured as entry, either an address or alias=address, and a member of
+// cluster (empty when not part of a named cluster).
+// The role is inferred from the web UI port, since Impala daemons listen on well-known ports by default.
+func newTarget(entry, cluster string) Target {
+	t := Target{Name: entry, Address: entry, Role: "unknown", Cluster: cluster, Scheme: "http"}
+	if alias, address, ok := strings.Cut(entry, "="); ok {
+		t.Name, t.Address = cmp.Or(strings.TrimSpace(alias), address), address
+	}
+	if _, port, err := net.SplitHostPort(t.Address); err == nil {
+		t.Port = port
+		if role, ok := defaultWebPortRoles[port]; ok {
+			t.Role = role
+		}
+	}
+	return t
+}
+
+// SetClusters records the named cluster of each server, used as the cluster label of impala_target_info.
+// A server listed in several clusters is attributed to the first.
+func (e *Exporter) SetClusters(clusters []Cluster) {
+	e.sourcesMu.Lock()
+	defer e.sourcesMu.Unlock()
+	e.clusterOf = make(map[string]string)
+	for _, cluster := range clusters {
+		for _, server := range cluster.Servers {
+			if _, ok := e.clusterOf[server]; !ok {
+				e.clusterOf[server] = cluster.Name
+			}
+		}
+	}
+}
+
+// checkTargetNames reports an error when two of the given server entries name different addresses with the same
+// impala_server label, e.g. x=hostA:25000 and x=hostB:25000, since only one of them could be reported
+func checkTargetNames(entries []string) error {
+	addressOf := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		t := newTarget(entry, "")
+		if address, ok := addressOf[t.Name]; ok && address != t.Address {
+			return fmt.Errorf("server name %q is used for both %s and %s", t.Name, address, t.Address)
+		}
+		addressOf[t.Name] = t.Address
+	}
+	return nil
+}
+
+// Targets returns the servers to scrape, as returned by Servers, along with their metadata.
+// A server is scraped once even if listed under several entries, e.g. with an alias and as discovered, keeping the
+// first entry; a later entry reusing the name of another server is skipped.
+func (e *Exporter) Targets() []Target {
+	servers := e.Servers()
+
+	e.sourcesMu.RLock()
+	defer e.sourcesMu.RUnlock()
+	targets := make([]Target, 0, len(servers))
+	addresses := make(map[string]bool, len(servers))
+	names := make(map[string]string, len(servers))
+	for _, server := range servers {
+		t := newTarget(server, e.clusterOf[server])
+		for _, roles := range e.sourceRoles {
+			if role, ok := roles[t.Address]; ok {
+				t.Role = role
+			}
+		}
+		if addresses[t.Address] {
+			continue
+		}
+		if address, ok := names[t.Name]; ok {
+			slog.Warn("Skipping server whose name is already used by another server", "target", t.Name, "address", t.Address, "other_address", address)
+			continue
+		}
+		addresses[t.Address] = true
+		names[t.Name] = t.Address
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// collectTargetInfo sends the metadata of a server over to the provided channel
+func (e *Exporter) collectTargetInfo(ch chan<- prometheus.Metric, t Target) {
+	ch <- prometheus.MustNewConstMetric(e.targetInfo, prometheus.GaugeValue, 1, t.Name, t.Role, t.Cluster, t.Scheme, t.Port)
+}
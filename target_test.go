@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewTarget(t *testing.T) {
+	tests := []struct {
+		entry   string
+		cluster string
+		want    Target
+	}{
+		{"10.11.18.16:25000", "prod", Target{Name: "10.11.18.16:25000", Address: "10.11.18.16:25000", Role: "impalad", Cluster: "prod", Scheme: "http", Port: "25000"}},
+		{"catalog:25020", "", Target{Name: "catalog:25020", Address: "catalog:25020", Role: "catalogd", Scheme: "http", Port: "25020"}},
+		{"statestore:25010", "", Target{Name: "statestore:25010", Address: "statestore:25010", Role: "statestored", Scheme: "http", Port: "25010"}},
+		{"coord:8080", "", Target{Name: "coord:8080", Address: "coord:8080", Role: "unknown", Scheme: "http", Port: "8080"}},
+		{"coord", "", Target{Name: "coord", Address: "coord", Role: "unknown", Scheme: "http"}},
+		{"coord-1=10.11.18.16:25000", "prod", Target{Name: "coord-1", Address: "10.11.18.16:25000", Role: "impalad", Cluster: "prod", Scheme: "http", Port: "25000"}},
+		{" coord-2 =10.11.18.17:25000", "", Target{Name: "coord-2", Address: "10.11.18.17:25000", Role: "impalad", Scheme: "http", Port: "25000"}},
+		{"=10.11.18.18:25000", "", Target{Name: "10.11.18.18:25000", Address: "10.11.18.18:25000", Role: "impalad", Scheme: "http", Port: "25000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			if got := newTarget(tt.entry, tt.cluster); got != tt.want {
+				t.Errorf("newTarget(%q, %q) = %+v, want %+v", tt.entry, tt.cluster, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTargetNames(t *testing.T) {
+	if err := checkTargetNames([]string{"x=hostA:25000", "hostA:25000", "x=hostA:25000", "y=hostB:25000"}); err != nil {
+		t.Errorf("checkTargetNames() = %v, want no error", err)
+	}
+	if err := checkTargetNames([]string{"x=hostA:25000", "x=hostB:25000"}); err == nil {
+		t.Error("checkTargetNames() accepted one name for two addresses")
+	}
+}
+
+func TestTargetsDedupe(t *testing.T) {
+	e := NewExporter([]string{"coord-1=h1:25000", "x=h2:25000"}, ExporterOptions{})
+	e.SetSourceServers("discovery/test", []string{"h1:25000", "x=h3:25000", "h4:25000"})
+
+	var got []string
+	for _, target := range e.Targets() {
+		got = append(got, target.Name+"="+target.Address)
+	}
+	want := []string{"coord-1=h1:25000", "x=h2:25000", "h4:25000=h4:25000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Targets() = %q, want %q", got, want)
+	}
+}
+
+func TestCollectTargetDaemonRoles(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer catalog.Close()
+
+	address := strings.TrimPrefix(catalog.URL, "http://")
+	e := NewExporter(nil, ExporterOptions{})
+	e.SetSourceServers("discovery/test", []string{address})
+	e.SetSourceRoles("discovery/test", map[string]string{address: "catalogd"})
+	targets := e.Targets()
+	if len(targets) != 1 || targets[0].Role != "catalogd" {
+		t.Fatalf("Targets() = %+v, want one catalogd target", targets)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	complete := e.collectTarget(context.Background(), ch, targets[0])
+	close(ch)
+	if !complete {
+		t.Errorf("collectTarget() = false, want a complete scrape")
+	}
+	for _, path := range paths {
+		if path != "/" && path != "/metrics" {
+			t.Errorf("requested %s from a catalogd, want only / and /metrics", path)
+		}
+	}
+}
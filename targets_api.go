@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// apiTargetSource is the name under which targets managed through the API are registered with the Exporter
+const apiTargetSource = "api"
+
+// errTargetNameConflict is returned by TargetStore.Add for a target named like another server with a different address
+var errTargetNameConflict = errors.New("target name is already used by another server")
+
+// TargetStore holds the targets added through the targets API and persists them to the state file
+type TargetStore struct {
+	mu       sync.Mutex
+	path     string
+	cipher   *atRestCipher
+	targets  []string
+	exporter *Exporter
+}
+
+// NewTargetStore creates a TargetStore, restoring previously added targets from the state file if one is configured.
+// The state file is encrypted with c unless it is nil.
+func NewTargetStore(path string, c *atRestCipher, exporter *Exporter) (*TargetStore, error) {
+	s := &TargetStore{path: path, cipher: c, exporter: exporter}
+	if path != "" {
+		state, err := loadState(path, c)
+		if err != nil {
+			return nil, fmt.Errorf("loading state file %s: %w", path, err)
+		}
+		s.targets = dedupeServers(state.Targets)
+		// Rewrite the state right away, so that a file migrated from plaintext is encrypted
+		if c != nil && c.allowPlaintext {
+			if err := s.save(s.targets); err != nil {
+				return nil, fmt.Errorf("writing state file %s: %w", path, err)
+			}
+		}
+	}
+	exporter.SetSourceServers(apiTargetSource, s.targets)
+	return s, nil
+}
+
+// Add adds a target, reporting false if it was already present
+func (s *TargetStore) Add(target string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.Contains(s.targets, target) {
+		return false, nil
+	}
+	t := newTarget(target, "")
+	for _, existing := range s.exporter.Targets() {
+		if existing.Name == t.Name && existing.Address != t.Address {
+			return false, fmt.Errorf("%w: %s is %s", errTargetNameConflict, t.Name, existing.Address)
+		}
+	}
+	targets := append(slices.Clone(s.targets), target)
+	if err := s.save(targets); err != nil {
+		return false, err
+	}
+	s.targets = targets
+	s.exporter.SetSourceServers(apiTargetSource, s.targets)
+	return true, nil
+}
+
+// Remove removes a target, reporting false if it was not present
+func (s *TargetStore) Remove(target string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.Index(s.targets, target)
+	if i < 0 {
+		return false, nil
+	}
+	targets := slices.Delete(slices.Clone(s.targets), i, i+1)
+	if err := s.save(targets); err != nil {
+		return false, err
+	}
+	s.targets = targets
+	s.exporter.SetSourceServers(apiTargetSource, s.targets)
+	return true, nil
+}
+
+// save persists the given targets to the state file, if one is configured
+func (s *TargetStore) save(targets []string) error {
+	if s.path == "" {
+		return nil
+	}
+	return saveState(s.path, State{Targets: targets}, s.cipher)
+}
+
+// targetRequest is the body of POST /api/v1/targets
+type targetRequest struct {
+	Target string `json:"target"`
+}
+
+// validateTarget checks that a target is a host:port address
+func validateTarget(target string) error {
+	if strings.TrimSpace(target) != target || target == "" {
+		return fmt.Errorf("invalid target %q", target)
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	return nil
+}
+
+// registerTargetsAPI mounts the targets API on the given mux.
+// Every request must carry the token as an "Authorization: Bearer <token>" header.
+func registerTargetsAPI(mux *http.ServeMux, store *TargetStore, token string) {
+	mux.Handle("POST /api/v1/targets", requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req targetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateTarget(req.Target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		added, err := store.Add(req.Target)
+		if errors.Is(err, errTargetNameConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("persisting target: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if added {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	})))
+
+	mux.Handle("DELETE /api/v1/targets/{target}", requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		removed, err := store.Remove(r.PathValue("target"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("persisting target: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+}
+
+// requireToken rejects requests that do not carry the expected bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
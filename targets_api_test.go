@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTargetsAPI(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	exporter := NewExporter(nil, ExporterOptions{})
+	store, err := NewTargetStore(statePath, nil, exporter)
+	if err != nil {
+		t.Fatalf("NewTargetStore: %v", err)
+	}
+	mux := http.NewServeMux()
+	registerTargetsAPI(mux, store, "secret")
+
+	do := func(method, path, token, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"missing token", http.MethodPost, "/api/v1/targets", "", `{"target": "h1:25000"}`, http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/api/v1/targets", "nope", `{"target": "h1:25000"}`, http.StatusUnauthorized},
+		{"wrong token on delete", http.MethodDelete, "/api/v1/targets/h1:25000", "nope", "", http.StatusUnauthorized},
+		{"invalid body", http.MethodPost, "/api/v1/targets", "secret", `{"target":`, http.StatusBadRequest},
+		{"invalid target", http.MethodPost, "/api/v1/targets", "secret", `{"target": "no-port"}`, http.StatusBadRequest},
+		{"add", http.MethodPost, "/api/v1/targets", "secret", `{"target": "h1:25000"}`, http.StatusCreated},
+		{"add duplicate", http.MethodPost, "/api/v1/targets", "secret", `{"target": "h1:25000"}`, http.StatusOK},
+		{"add aliased", http.MethodPost, "/api/v1/targets", "secret", `{"target": "coord=h2:25000"}`, http.StatusCreated},
+		{"add conflicting alias", http.MethodPost, "/api/v1/targets", "secret", `{"target": "coord=h3:25000"}`, http.StatusConflict},
+		{"delete", http.MethodDelete, "/api/v1/targets/h1:25000", "secret", "", http.StatusNoContent},
+		{"delete again", http.MethodDelete, "/api/v1/targets/h1:25000", "secret", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.path, tt.token, tt.body); got != tt.want {
+			t.Errorf("%s: %s %s returned %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if got, want := exporter.Servers(), []string{"coord=h2:25000"}; !slices.Equal(got, want) {
+		t.Errorf("exporter servers = %q, want %q", got, want)
+	}
+
+	// A new store restores the targets from the state file
+	restored := NewExporter(nil, ExporterOptions{})
+	if _, err := NewTargetStore(statePath, nil, restored); err != nil {
+		t.Fatalf("NewTargetStore: %v", err)
+	}
+	if got, want := restored.Servers(), []string{"coord=h2:25000"}; !slices.Equal(got, want) {
+		t.Errorf("restored servers = %q, want %q", got, want)
+	}
+}
+
+func TestTargetStorePlaintextMigration(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(statePath, []byte(`{"targets": ["h1:25000"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCipher(t, strings.Repeat("ab", 32))
+	if _, err := NewTargetStore(statePath, c, NewExporter(nil, ExporterOptions{})); err == nil {
+		t.Fatal("NewTargetStore accepted a plaintext state file with an encryption key")
+	}
+
+	c.allowPlaintext = true
+	if _, err := NewTargetStore(statePath, c, NewExporter(nil, ExporterOptions{})); err != nil {
+		t.Fatalf("NewTargetStore while migrating: %v", err)
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		t.Errorf("state file still unencrypted after migrating: %q", data)
+	}
+}
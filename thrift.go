@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// Type IDs of the Thrift binary protocol
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+// Thrift message types
+const (
+	thriftCall      = 1
+	thriftReply     = 2
+	thriftException = 3
+)
+
+// thriftVersion1 marks a message header of the strict binary protocol
+const thriftVersion1 = 0x80010000
+
+// thriftMaxSize bounds the strings and containers a Thrift message may declare, so that a garbled response cannot
+// make the decoder allocate gigabytes
+const thriftMaxSize = 16 << 20
+
+// thriftFields is a Thrift struct, its fields by ID. Only the handful of calls the exporter makes are encoded and
+// decoded, so structs are handled generically rather than through generated code: a struct received from the
+// server, such as a session handle, can be sent back as is.
+type thriftFields map[int16]thriftValue
+
+// thriftValue is a typed Thrift value: bool, int8, int16, int32, int64, float64, []byte for strings and binaries,
+// thriftFields for structs, []thriftValue for lists and sets, and [][2]thriftValue for maps
+type thriftValue struct {
+	typ byte
+	// elem holds the element type of a list or set, and the key and value types of a map
+	elem [2]byte
+	v    any
+}
+
+// Constructors of the thriftValues sent by the exporter
+func thriftStringValue(s string) thriftValue { return thriftValue{typ: thriftString, v: []byte(s)} }
+func thriftI32Value(v int32) thriftValue     { return thriftValue{typ: thriftI32, v: v} }
+func thriftI64Value(v int64) thriftValue     { return thriftValue{typ: thriftI64, v: v} }
+func thriftBoolValue(v bool) thriftValue     { return thriftValue{typ: thriftBool, v: v} }
+
+func thriftStructValue(f thriftFields) thriftValue { return thriftValue{typ: thriftStruct, v: f} }
+
+// structField returns the struct field id, nil when absent or of another type
+func (f thriftFields) structField(id int16) thriftFields {
+	s, _ := f[id].v.(thriftFields)
+	return s
+}
+
+// i32 returns the i32 field id, reporting false when absent or of another type
+func (f thriftFields) i32(id int16) (int32, bool) {
+	v, ok := f[id].v.(int32)
+	return v, ok
+}
+
+// str returns the string field id, empty when absent or of another type
+func (f thriftFields) str(id int16) string {
+	b, _ := f[id].v.([]byte)
+	return string(b)
+}
+
+// boolField returns the bool field id, false when absent or of another type
+func (f thriftFields) boolField(id int16) bool {
+	b, _ := f[id].v.(bool)
+	return b
+}
+
+// appendThriftMessage appends a call of method with the given arguments struct
+func appendThriftMessage(b []byte, method string, seq int32, args thriftFields) []byte {
+	b = binary.BigEndian.AppendUint32(b, thriftVersion1|thriftCall)
+	b = appendThriftString(b, []byte(method))
+	b = binary.BigEndian.AppendUint32(b, uint32(seq))
+	return appendThriftStruct(b, args)
+}
+
+func appendThriftString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendThriftStruct appends the fields in ID order followed by the stop marker
+func appendThriftStruct(b []byte, f thriftFields) []byte {
+	ids := make([]int16, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		b = append(b, f[id].typ)
+		b = binary.BigEndian.AppendUint16(b, uint16(id))
+		b = appendThriftValue(b, f[id])
+	}
+	return append(b, thriftStop)
+}
+
+func appendThriftValue(b []byte, v thriftValue) []byte {
+	switch x := v.v.(type) {
+	case bool:
+		if x {
+			return append(b, 1)
+		}
+		return append(b, 0)
+	case int8:
+		return append(b, byte(x))
+	case int16:
+		return binary.BigEndian.AppendUint16(b, uint16(x))
+	case int32:
+		return binary.BigEndian.AppendUint32(b, uint32(x))
+	case int64:
+		return binary.BigEndian.AppendUint64(b, uint64(x))
+	case float64:
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(x))
+	case []byte:
+		return appendThriftString(b, x)
+	case thriftFields:
+		return appendThriftStruct(b, x)
+	case []thriftValue:
+		b = append(b, v.elem[0])
+		b = binary.BigEndian.AppendUint32(b, uint32(len(x)))
+		for _, item := range x {
+			b = appendThriftValue(b, item)
+		}
+		return b
+	case [][2]thriftValue:
+		b = append(b, v.elem[0], v.elem[1])
+		b = binary.BigEndian.AppendUint32(b, uint32(len(x)))
+		for _, entry := range x {
+			b = appendThriftValue(appendThriftValue(b, entry[0]), entry[1])
+		}
+		return b
+	}
+	panic(fmt.Sprintf("unsupported Thrift value %T", v.v))
+}
+
+// thriftApplicationError is a TApplicationException the server replied with, e.g. for an unknown method
+type thriftApplicationError struct {
+	method  string
+	message string
+}
+
+func (e *thriftApplicationError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.method, e.message)
+}
+
+// thriftReader decodes the Thrift binary protocol
+type thriftReader struct {
+	r   io.Reader
+	buf [8]byte
+}
+
+func (t *thriftReader) read(n int) ([]byte, error) {
+	_, err := io.ReadFull(t.r, t.buf[:n])
+	return t.buf[:n], err
+}
+
+func (t *thriftReader) readByte() (byte, error) {
+	b, err := t.read(1)
+	return b[0], err
+}
+
+func (t *thriftReader) readI32() (int32, error) {
+	b, err := t.read(4)
+	return int32(binary.BigEndian.Uint32(b)), err
+}
+
+// readSize reads a string or container size, rejecting negative and oversized ones
+func (t *thriftReader) readSize() (int, error) {
+	n, err := t.readI32()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > thriftMaxSize {
+		return 0, fmt.Errorf("invalid Thrift size %d", n)
+	}
+	return int(n), nil
+}
+
+func (t *thriftReader) readBytes() ([]byte, error) {
+	n, err := t.readSize()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(t.r, b)
+	return b, err
+}
+
+// readMessage reads a reply to method with sequence number seq and returns its result struct. An exception
+// message is returned as an error.
+func (t *thriftReader) readMessage(method string, seq int32) (thriftFields, error) {
+	header, err := t.readI32()
+	if err != nil {
+		return nil, err
+	}
+	if uint32(header)&0xffff0000 != thriftVersion1 {
+		return nil, fmt.Errorf("invalid Thrift message header %#x", uint32(header))
+	}
+	name, err := t.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	gotSeq, err := t.readI32()
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.readStruct()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case header&0xff == thriftException:
+		return nil, &thriftApplicationError{method: method, message: result.str(1)}
+	case header&0xff != thriftReply || string(name) != method || gotSeq != seq:
+		return nil, fmt.Errorf("unexpected Thrift message %q (type %d, seq %d) in reply to %s", name, header&0xff, gotSeq, method)
+	}
+	return result, nil
+}
+
+func (t *thriftReader) readStruct() (thriftFields, error) {
+	f := make(thriftFields)
+	for {
+		typ, err := t.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if typ == thriftStop {
+			return f, nil
+		}
+		b, err := t.read(2)
+		if err != nil {
+			return nil, err
+		}
+		id := int16(binary.BigEndian.Uint16(b))
+		if f[id], err = t.readValue(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *thriftReader) readValue(typ byte) (thriftValue, error) {
+	v := thriftValue{typ: typ}
+	switch typ {
+	case thriftBool, thriftByte:
+		b, err := t.readByte()
+		if typ == thriftBool {
+			v.v = b != 0
+		} else {
+			v.v = int8(b)
+		}
+		return v, err
+	case thriftI16:
+		b, err := t.read(2)
+		v.v = int16(binary.BigEndian.Uint16(b))
+		return v, err
+	case thriftI32:
+		n, err := t.readI32()
+		v.v = n
+		return v, err
+	case thriftI64, thriftDouble:
+		b, err := t.read(8)
+		if typ == thriftI64 {
+			v.v = int64(binary.BigEndian.Uint64(b))
+		} else {
+			v.v = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+		return v, err
+	case thriftString:
+		b, err := t.readBytes()
+		v.v = b
+		return v, err
+	case thriftStruct:
+		f, err := t.readStruct()
+		v.v = f
+		return v, err
+	case thriftList, thriftSet:
+		elem, err := t.readByte()
+		if err != nil {
+			return v, err
+		}
+		n, err := t.readSize()
+		if err != nil {
+			return v, err
+		}
+		v.elem[0] = elem
+		items := make([]thriftValue, 0, min(n, 1024))
+		for range n {
+			item, err := t.readValue(elem)
+			if err != nil {
+				return v, err
+			}
+			items = append(items, item)
+		}
+		v.v = items
+		return v, nil
+	case thriftMap:
+		types, err := t.read(2)
+		if err != nil {
+			return v, err
+		}
+		v.elem = [2]byte{types[0], types[1]}
+		n, err := t.readSize()
+		if err != nil {
+			return v, err
+		}
+		entries := make([][2]thriftValue, 0, min(n, 1024))
+		for range n {
+			key, err := t.readValue(v.elem[0])
+			if err != nil {
+				return v, err
+			}
+			value, err := t.readValue(v.elem[1])
+			if err != nil {
+				return v, err
+			}
+			entries = append(entries, [2]thriftValue{key, value})
+		}
+		v.v = entries
+		return v, nil
+	}
+	return v, fmt.Errorf("invalid Thrift type %d", typ)
+}
+
+// errThriftFrameTooLarge is returned for a frame larger than thriftMaxSize
+var errThriftFrameTooLarge = errors.New("frame exceeds the maximum Thrift message size")
+
+// readThriftFrame reads a frame of the framed and SASL transports: a big-endian length followed by the payload
+func readThriftFrame(r io.Reader) (*bytes.Reader, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > thriftMaxSize {
+		return nil, errThriftFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(frame), nil
+}
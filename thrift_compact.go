@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Type IDs of the Thrift compact protocol, indexing compactTypes
+const (
+	compactBoolTrue  = 1
+	compactBoolFalse = 2
+	compactList      = 9
+	compactSet       = 10
+	compactMap       = 11
+	compactStruct    = 12
+)
+
+// compactTypes maps the type IDs of the compact protocol to those of the binary protocol, so that decoded values
+// are the same thriftValues
+var compactTypes = [...]byte{
+	compactBoolTrue:  thriftBool,
+	compactBoolFalse: thriftBool,
+	3:                thriftByte,
+	4:                thriftI16,
+	5:                thriftI32,
+	6:                thriftI64,
+	7:                thriftDouble,
+	8:                thriftString,
+	compactList:      thriftList,
+	compactSet:       thriftSet,
+	compactMap:       thriftMap,
+	compactStruct:    thriftStruct,
+}
+
+// compactReader decodes the Thrift compact protocol, in which Impala serializes archived query profiles
+type compactReader struct {
+	r io.ByteReader
+}
+
+func newCompactReader(r io.Reader) *compactReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return &compactReader{r: br}
+	}
+	return &compactReader{r: bufio.NewReader(r)}
+}
+
+// thriftType returns the binary protocol type of the compact type ID
+func (c *compactReader) thriftType(id byte) (byte, error) {
+	if id == 0 || int(id) >= len(compactTypes) {
+		return 0, fmt.Errorf("invalid Thrift compact type %d", id)
+	}
+	return compactTypes[id], nil
+}
+
+func (c *compactReader) readVarint() (int64, error) {
+	u, err := binary.ReadUvarint(c.r)
+	// Integers are zigzag encoded
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+// readSize reads a string or container size, rejecting oversized ones
+func (c *compactReader) readSize() (int, error) {
+	n, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return 0, err
+	}
+	if n > thriftMaxSize {
+		return 0, fmt.Errorf("invalid Thrift size %d", n)
+	}
+	return int(n), nil
+}
+
+func (c *compactReader) readStruct() (thriftFields, error) {
+	f := make(thriftFields)
+	var id int16
+	for {
+		header, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == thriftStop {
+			return f, nil
+		}
+		// The field ID is a delta from the previous one in the high nibble, or follows the header when 0
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			n, err := c.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(n)
+		}
+		// A bool field carries its value in its type
+		if compact := header & 0x0f; compact == compactBoolTrue || compact == compactBoolFalse {
+			f[id] = thriftBoolValue(compact == compactBoolTrue)
+			continue
+		}
+		typ, err := c.thriftType(header & 0x0f)
+		if err != nil {
+			return nil, err
+		}
+		if f[id], err = c.readValue(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *compactReader) readValue(typ byte) (thriftValue, error) {
+	v := thriftValue{typ: typ}
+	switch typ {
+	case thriftBool:
+		b, err := c.r.ReadByte()
+		v.v = b == compactBoolTrue
+		return v, err
+	case thriftByte:
+		b, err := c.r.ReadByte()
+		v.v = int8(b)
+		return v, err
+	case thriftI16, thriftI32, thriftI64:
+		n, err := c.readVarint()
+		switch typ {
+		case thriftI16:
+			v.v = int16(n)
+		case thriftI32:
+			v.v = int32(n)
+		default:
+			v.v = n
+		}
+		return v, err
+	case thriftDouble:
+		var b [8]byte
+		for i := range b {
+			var err error
+			if b[i], err = c.r.ReadByte(); err != nil {
+				return v, err
+			}
+		}
+		v.v = math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+		return v, nil
+	case thriftString:
+		n, err := c.readSize()
+		if err != nil {
+			return v, err
+		}
+		b := make([]byte, n)
+		for i := range b {
+			if b[i], err = c.r.ReadByte(); err != nil {
+				return v, err
+			}
+		}
+		v.v = b
+		return v, nil
+	case thriftStruct:
+		f, err := c.readStruct()
+		v.v = f
+		return v, err
+	case thriftList, thriftSet:
+		header, err := c.r.ReadByte()
+		if err != nil {
+			return v, err
+		}
+		if v.elem[0], err = c.thriftType(header & 0x0f); err != nil {
+			return v, err
+		}
+		// Short sizes are held in the high nibble of the header, longer ones follow it
+		n := int(header >> 4)
+		if n == 15 {
+			if n, err = c.readSize(); err != nil {
+				return v, err
+			}
+		}
+		items := make([]thriftValue, 0, min(n, 1024))
+		for range n {
+			item, err := c.readValue(v.elem[0])
+			if err != nil {
+				return v, err
+			}
+			items = append(items, item)
+		}
+		v.v = items
+		return v, nil
+	case thriftMap:
+		n, err := c.readSize()
+		if err != nil {
+			return v, err
+		}
+		entries := make([][2]thriftValue, 0, min(n, 1024))
+		if n > 0 {
+			types, err := c.r.ReadByte()
+			if err != nil {
+				return v, err
+			}
+			if v.elem[0], err = c.thriftType(types >> 4); err != nil {
+				return v, err
+			}
+			if v.elem[1], err = c.thriftType(types & 0x0f); err != nil {
+				return v, err
+			}
+		}
+		for range n {
+			key, err := c.readValue(v.elem[0])
+			if err != nil {
+				return v, err
+			}
+			value, err := c.readValue(v.elem[1])
+			if err != nil {
+				return v, err
+			}
+			entries = append(entries, [2]thriftValue{key, value})
+		}
+		v.v = entries
+		return v, nil
+	}
+	return v, fmt.Errorf("invalid Thrift type %d", typ)
+}
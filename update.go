@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	updateAvailable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "impala_exporter_update_available",
+		Help: "1 if the release manifest lists a newer impala_exporter version than the running one, 0 otherwise",
+	})
+	latestVersionInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "impala_exporter_latest_version_info",
+			Help: "Latest impala_exporter version listed in the release manifest, always 1",
+		},
+		[]string{"version"},
+	)
+)
+
+// ReleaseManifest represents the JSON document served at -update.manifest-url
+type ReleaseManifest struct {
+	Version string `json:"version"`
+}
+
+// compareVersions compares two dotted versions such as "1.4.0" or "v1.10.2", ignoring a leading v and any
+// pre-release or build suffix. It reports false when either version is not made up of numeric components.
+func compareVersions(a, b string) (int, bool) {
+	pa, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	pb, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// parseVersion splits a version into its numeric components
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// updateChecker periodically fetches the release manifest and updates the update metrics
+type updateChecker struct {
+	url    string
+	client *http.Client
+	task   *supervisedTask
+}
+
+// startUpdateChecker checks url immediately and then every interval, under supervision
+func startUpdateChecker(sup *supervisor, url string, interval time.Duration) *updateChecker {
+	u := &updateChecker{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+	u.task = sup.Go("update-check", staleAfter(interval), func(ctx context.Context, heartbeat func()) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := u.check(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Error checking for exporter updates", "url", u.url, "err", err)
+			}
+			heartbeat()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	return u
+}
+
+// Close stops the periodic check
+func (u *updateChecker) Close() {
+	u.task.Stop()
+}
+
+// check fetches the manifest once and compares its version with the running one
+func (u *updateChecker) check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var manifest ReleaseManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return fmt.Errorf("decoding release manifest: %w", err)
+	}
+	if manifest.Version == "" {
+		return fmt.Errorf("release manifest has no version")
+	}
+
+	latestVersionInfo.Reset()
+	latestVersionInfo.WithLabelValues(manifest.Version).Set(1)
+	// A development build cannot be compared, so it is never reported as outdated
+	if c, ok := compareVersions(version, manifest.Version); ok && c < 0 {
+		updateAvailable.Set(1)
+	} else {
+		updateAvailable.Set(0)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{"1.4.0", "1.4.0", 0, true},
+		{"v1.4.0", "1.4.0", 0, true},
+		{"1.4.0", "1.10.0", -1, true},
+		{"1.4", "1.4.1", -1, true},
+		{"2.0.0", "1.99.99", 1, true},
+		{"1.4.0-rc.1", "1.4.0", 0, true},
+		{"dev", "1.4.0", 0, false},
+		{"1.4.0", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			got, ok := compareVersions(tt.a, tt.b)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("compareVersions(%q, %q) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherUsersLabel is the user label value under which sessions of users outside the top N are summed up
+const otherUsersLabel = "__other__"
+
+// ImpalaSession represents a single session in the JSON response from Impala for /sessions
+type ImpalaSession struct {
+	User           string `json:"user"`
+	NetworkAddress string `json:"network_address"`
+	StartTime      string `json:"start_time"`
+	LastAccessed   string `json:"last_accessed"`
+	Expired        bool   `json:"expired"`
+	Closed         bool   `json:"closed"`
+}
+
+// userSessionCount is the number of active sessions held by a user
+type userSessionCount struct {
+	User  string
+	Count int
+}
+
+// topUserSessions counts the active (neither expired nor closed) sessions per user and returns the n users
+// holding the most, ordered by count and then user name, along with the number of active sessions of all other users.
+func topUserSessions(sessions []ImpalaSession, n int) (top []userSessionCount, other int) {
+	counts := make(map[string]int)
+	for _, session := range sessions {
+		if session.Expired || session.Closed {
+			continue
+		}
+		counts[session.User]++
+	}
+
+	for user, count := range counts {
+		top = append(top, userSessionCount{User: user, Count: count})
+	}
+	slices.SortFunc(top, func(a, b userSessionCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.User, b.User)
+	})
+	if len(top) > n {
+		for _, u := range top[n:] {
+			other += u.Count
+		}
+		top = top[:n]
+	}
+	return top, other
+}
+
+// collectUserSessions sends the active session count of the top users of a server over to the provided channel
+func (e *Exporter) collectUserSessions(ch chan<- prometheus.Metric, server string, sessions []ImpalaSession) {
+	if e.options.TopUsers <= 0 {
+		return
+	}
+	top, other := topUserSessions(sessions, e.options.TopUsers)
+	for _, u := range top {
+		ch <- prometheus.MustNewConstMetric(e.userActiveSessions, prometheus.GaugeValue, float64(u.Count), server, u.User)
+	}
+	if other > 0 {
+		ch <- prometheus.MustNewConstMetric(e.userActiveSessions, prometheus.GaugeValue, float64(other), server, otherUsersLabel)
+	}
+}
+
+// collectExpiredSessions sends the number of sessions of a server that expired but were not closed by their client
+// over to the provided channel
+func (e *Exporter) collectExpiredSessions(ch chan<- prometheus.Metric, server string, sessions []ImpalaSession) {
+	var expired float64
+	for _, session := range sessions {
+		if session.Expired && !session.Closed {
+			expired++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.expiredSessions, prometheus.GaugeValue, expired, server)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTopUserSessions(t *testing.T) {
+	sessions := []ImpalaSession{
+		{User: "etl"}, {User: "etl"}, {User: "etl"},
+		{User: "bi"}, {User: "bi"},
+		{User: "alice"}, {User: "bob"},
+		{User: "carol", Expired: true},
+		{User: "dave", Closed: true},
+	}
+	tests := []struct {
+		name      string
+		n         int
+		wantTop   []userSessionCount
+		wantOther int
+	}{
+		{"all users", 10, []userSessionCount{{"etl", 3}, {"bi", 2}, {"alice", 1}, {"bob", 1}}, 0},
+		{"capped", 2, []userSessionCount{{"etl", 3}, {"bi", 2}}, 2},
+		{"tie broken by name", 3, []userSessionCount{{"etl", 3}, {"bi", 2}, {"alice", 1}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top, other := topUserSessions(sessions, tt.n)
+			if !reflect.DeepEqual(top, tt.wantTop) || other != tt.wantOther {
+				t.Errorf("topUserSessions(n=%d) = %v, %d, want %v, %d", tt.n, top, other, tt.wantTop, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestCollectExpiredSessions(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	got := collectValues(t, e, func(ch chan<- prometheus.Metric) {
+		e.collectExpiredSessions(ch, "coord", []ImpalaSession{
+			{User: "etl"},
+			{User: "etl", Expired: true},
+			{User: "bi", Expired: true},
+			// Closing a session clears it from the count
+			{User: "bi", Expired: true, Closed: true},
+			{User: "bi", Closed: true},
+		})
+	})
+	if want := map[string]float64{"impala_expired_sessions": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collectExpiredSessions() = %v, want %v", got, want)
+	}
+}
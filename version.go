@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Build information, set at build time via
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// versionString returns a human readable description of the exporter build
+func versionString() string {
+	return fmt.Sprintf("impala_exporter version %s (commit %s, built %s, %s)", version, commit, buildDate, runtime.Version())
+}
+
+// newBuildInfoCollector returns a collector exposing the exporter build information
+func newBuildInfoCollector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "impala_exporter_build_info",
+			Help: "Version, commit and build date of the running impala_exporter, always 1",
+			ConstLabels: prometheus.Labels{
+				"version":    version,
+				"commit":     commit,
+				"build_date": buildDate,
+				"goversion":  runtime.Version(),
+			},
+		},
+		func() float64 { return 1 },
+	)
+}
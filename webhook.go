@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	webhookURLFlag    = flag.String("sink.webhook.url", "", "URL POSTed to for every in-flight query crossing -sink.query-threshold, such as a Slack incoming webhook or the /api/v2/alerts endpoint of Alertmanager; enables the webhook sink")
+	webhookFormatFlag = flag.String("sink.webhook.format", "slack", "Payload POSTed to -sink.webhook.url: slack ({\"text\": ...}) or alertmanager (a list of alerts)")
+)
+
+func init() {
+	RegisterSink("webhook", func() (Sink, error) {
+		if *webhookURLFlag == "" {
+			return nil, nil
+		}
+		if *webhookFormatFlag != "slack" && *webhookFormatFlag != "alertmanager" {
+			return nil, fmt.Errorf("invalid format %q, want slack or alertmanager", *webhookFormatFlag)
+		}
+		return &webhookSink{
+			url:    *webhookURLFlag,
+			format: *webhookFormatFlag,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	})
+}
+
+// webhookSink POSTs a notification about every long-running query to a webhook
+type webhookSink struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Start(ctx context.Context) error {
+	return nil
+}
+
+// Emit POSTs the payload of a long-running query event, skipping the other events
+func (s *webhookSink) Emit(ctx context.Context, event Event) error {
+	if event.LongQuery == nil {
+		return nil
+	}
+	body, err := s.payload(*event.LongQuery)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// alertmanagerAlert is an alert as accepted by POST /api/v2/alerts of Alertmanager
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+// payload renders query in the format of the sink
+func (s *webhookSink) payload(query slowQueryEntry) ([]byte, error) {
+	running := time.Duration(query.DurationSeconds * float64(time.Second)).Round(time.Second)
+	summary := fmt.Sprintf("Query %s of user %s in pool %s on %s has been running for %s", query.QueryID, query.User, query.Pool, query.ImpalaServer, running)
+	if s.format == "alertmanager" {
+		return json.Marshal([]alertmanagerAlert{{
+			Labels: map[string]string{
+				"alertname":     "ImpalaLongRunningQuery",
+				"impala_server": query.ImpalaServer,
+				"query_id":      query.QueryID,
+				"user":          query.User,
+				"pool":          query.Pool,
+			},
+			Annotations: map[string]string{"summary": summary, "statement": query.Statement},
+			StartsAt:    query.Time.Add(-running),
+		}})
+	}
+	text := summary
+	if query.Statement != "" {
+		text += "\n```" + query.Statement + "```"
+	}
+	return json.Marshal(map[string]string{"text": text})
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookSinkPayloads(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	query := slowQueryEntry{Time: time.Now(), ImpalaServer: "coord", QueryID: "q1", User: "alice", Pool: "root.etl", DurationSeconds: 754, Statement: "select ?"}
+	for _, format := range []string{"slack", "alertmanager"} {
+		s := &webhookSink{url: hook.URL, format: format, client: hook.Client()}
+		if err := s.Emit(context.Background(), Event{Time: time.Now()}); err != nil {
+			t.Errorf("%s: Emit() of a metrics event error = %v", format, err)
+		}
+		if err := s.Emit(context.Background(), Event{Time: time.Now(), LongQuery: &query}); err != nil {
+			t.Errorf("%s: Emit() error = %v", format, err)
+		}
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests, want one per format", len(bodies))
+	}
+
+	var slack struct{ Text string }
+	if err := json.Unmarshal([]byte(bodies[0]), &slack); err != nil || !strings.Contains(slack.Text, "Query q1 of user alice in pool root.etl on coord has been running for 12m34s") {
+		t.Errorf("slack payload = %s (%v)", bodies[0], err)
+	}
+	var alerts []alertmanagerAlert
+	if err := json.Unmarshal([]byte(bodies[1]), &alerts); err != nil || len(alerts) != 1 {
+		t.Fatalf("alertmanager payload = %s (%v)", bodies[1], err)
+	}
+	if alerts[0].Labels["query_id"] != "q1" || alerts[0].Labels["user"] != "alice" || alerts[0].Annotations["statement"] != "select ?" {
+		t.Errorf("alert = %+v", alerts[0])
+	}
+}
+
+func TestWebhookSinkError(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer hook.Close()
+	s := &webhookSink{url: hook.URL, format: "slack", client: hook.Client()}
+	if err := s.Emit(context.Background(), Event{LongQuery: &slowQueryEntry{QueryID: "q1"}}); err == nil {
+		t.Error("Emit() succeeded on a 400 response")
+	}
+}
+
+func TestPublishLongQueries(t *testing.T) {
+	e := NewExporter(nil, ExporterOptions{})
+	// Nothing is tracked until a publisher is set
+	e.publishLongQueries("coord", []slowQueryEntry{{QueryID: "q1"}})
+
+	var published []string
+	e.SetEventPublisher(func(event Event) { published = append(published, event.LongQuery.QueryID) })
+	e.publishLongQueries("coord", []slowQueryEntry{{QueryID: "q1"}, {QueryID: "q2"}})
+	e.publishLongQueries("coord", []slowQueryEntry{{QueryID: "q1"}, {QueryID: "q2"}, {QueryID: "q3"}})
+	if got := strings.Join(published, ","); got != "q1,q2,q3" {
+		t.Errorf("published %s, want q1,q2,q3", got)
+	}
+}